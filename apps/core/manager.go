@@ -2,6 +2,8 @@ package core
 
 import (
 	"encoding/json"
+	"sort"
+	"sync"
 
 	"github.com/cognitedata/edge-extractor/apps/lib"
 	"github.com/cognitedata/edge-extractor/internal"
@@ -18,12 +20,15 @@ type AppManager struct {
 	Apps           map[string]lib.AppInstance
 	Integrations   map[string]interface{}
 	ConfigObserver *internal.CdfConfigObserver
+	appNames       map[string]string // app name by instance ID
+	mux            sync.RWMutex
 }
 
 func NewAppManager(configObserver *internal.CdfConfigObserver) *AppManager {
 	appManager := &AppManager{
 		Apps:           make(map[string]lib.AppInstance),
 		Integrations:   make(map[string]interface{}),
+		appNames:       make(map[string]string),
 		ConfigObserver: configObserver,
 	}
 	return appManager
@@ -69,7 +74,10 @@ func (am *AppManager) LoadAppsFromRawConfig(configs json.RawMessage) error {
 				log.Errorf("Integration %s not configured for app %s", integrationName, appConfig.AppName)
 			}
 		}
+		am.mux.Lock()
 		am.Apps[appConfig.InstanceID] = appInstance
+		am.appNames[appConfig.InstanceID] = appConfig.AppName
+		am.mux.Unlock()
 		log.Infof("App %s loaded", appConfig.AppName)
 	}
 
@@ -92,3 +100,15 @@ func (am *AppManager) StopApps() {
 		app.Stop()
 	}
 }
+
+// GetAppsStatus returns the list of loaded micro-apps sorted by instance ID.
+func (am *AppManager) GetAppsStatus() []internal.AppStatus {
+	am.mux.RLock()
+	defer am.mux.RUnlock()
+	apps := make([]internal.AppStatus, 0, len(am.Apps))
+	for instanceID := range am.Apps {
+		apps = append(apps, internal.AppStatus{InstanceID: instanceID, AppName: am.appNames[instanceID]})
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].InstanceID < apps[j].InstanceID })
+	return apps
+}
@@ -9,10 +9,14 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/cognitedata/edge-extractor/apps/core"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
 	"github.com/cognitedata/edge-extractor/integrations/ip_cams_to_cdf"
+	"github.com/cognitedata/edge-extractor/integrations/local_files_to_cdf"
 	"github.com/cognitedata/edge-extractor/internal"
 	"github.com/cskr/pubsub/v2"
 	"github.com/kardianos/service"
@@ -32,6 +36,7 @@ type Integration interface {
 
 var integrReg map[string]Integration
 var appManager *core.AppManager
+var statusServer *internal.StatusServer
 
 type program struct{}
 
@@ -174,6 +179,8 @@ func loadStaticConfigFromEnv() internal.StaticConfig {
 	config.EnabledIntegrations = []string{os.Getenv("EDGE_EXT_ENABLED_INTEGRATIONS")}
 	config.LogLevel = os.Getenv("EDGE_EXT_LOG_LEVEL")
 	config.LogDir = os.Getenv("EDGE_EXT_LOG_DIR")
+	config.MetricsListenAddress = os.Getenv("EDGE_EXT_METRICS_LISTEN_ADDRESS")
+	config.StatusListenAddress = os.Getenv("EDGE_EXT_STATUS_LISTEN_ADDRESS")
 	return config
 }
 
@@ -201,6 +208,15 @@ func startEdgeExtractor(mainConfigPath string) {
 
 	configureLogger(config.LogDir, config.LogLevel)
 
+	if config.StatusListenAddress != "" {
+		// metrics are served by status server if both share the same address
+		statusServer = internal.NewStatusServer(config.ExtractorID, Version)
+		statusServer.Start(config.StatusListenAddress, config.MetricsListenAddress == config.StatusListenAddress)
+	}
+	if config.MetricsListenAddress != "" && config.MetricsListenAddress != config.StatusListenAddress {
+		internal.StartMetricsServer(config.MetricsListenAddress)
+	}
+
 	log.Info("Starting edge-extractor service. Version : ", Version)
 	secretManager := internal.NewSecretManager(EncryptionKey)
 	secretManager.LoadEncryptedSecrets(config.Secrets)
@@ -218,6 +234,10 @@ func startEdgeExtractor(mainConfigPath string) {
 	systemEventBus := pubsub.New[string, internal.SystemEvent](20)
 
 	appManager = core.NewAppManager(configObserver)
+	if statusServer != nil {
+		statusServer.SetConfigObserver(configObserver)
+		statusServer.SetAppsProvider(appManager.GetAppsStatus)
+	}
 
 	integrReg = make(map[string]Integration)
 
@@ -238,7 +258,17 @@ func startEdgeExtractor(mainConfigPath string) {
 			}
 
 		case "local_files_to_cdf":
-			log.Info(" local_files_to_cdf integration not implemented yet")
+			intgr := local_files_to_cdf.NewLocalFilesToCdf(cdfCLient, config.ExtractorID, configObserver)
+			if config.RemoteConfigSource == internal.ConfigSourceLocal {
+				intgr.LoadConfigFromJson(config.Integrations["local_files_to_cdf"])
+			}
+			err = intgr.Start()
+			if err != nil {
+				log.Errorf(" %s integration can't be started . Error : %s", integrName, err.Error())
+			} else {
+				integrReg["local_files_to_cdf"] = intgr
+				appManager.SetIntegration("local_files_to_cdf", intgr)
+			}
 		}
 	}
 
@@ -254,10 +284,16 @@ func startEdgeExtractor(mainConfigPath string) {
 		log.Info("Starting apps remote configuration handler")
 		appManager.StartConfigHandler()
 	}
+	if statusServer != nil {
+		statusServer.SetReady(true)
+	}
 
 }
 
 func stopExtractor() {
+	if statusServer != nil {
+		statusServer.SetReady(false)
+	}
 	for _, intgr := range integrReg {
 		intgr.Stop()
 	}
@@ -269,7 +305,7 @@ func main() {
 	mainConfigPath := flag.String("config", "config.json", "Full path to main configuration file")
 
 	base64encodedConfig := flag.String("bconfig", "", "Base64 encoded config")
-	op := flag.String("op", "", "Supported operations : 'gen_config,install,uninstall,run' ")
+	op := flag.String("op", "", "Supported operations : 'gen_config,install,uninstall,run,list_drivers' ")
 	textToEncrypt := flag.String("secret", "", "Secret to encrypt")
 	encryptionKey := flag.String("key", "", "Encryption key")
 	flag.Parse()
@@ -321,6 +357,9 @@ func main() {
 	case "version":
 		fmt.Println(Version)
 
+	case "list_drivers":
+		printCameraDrivers()
+
 	case "encrypt_config":
 		if EncryptionKey == "" {
 			fmt.Println("Please provide encryption key")
@@ -409,3 +448,44 @@ func main() {
 	}
 
 }
+
+// printCameraDrivers prints all registered camera drivers with their capabilities , required config fields and options.
+func printCameraDrivers() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tCAPABILITIES\tREQUIRED FIELDS\tDESCRIPTION")
+	for _, info := range camera.ListDrivers() {
+		var capabilities []string
+		if info.Capabilities.Events {
+			capabilities = append(capabilities, "events")
+		}
+		if info.Capabilities.Metadata {
+			capabilities = append(capabilities, "metadata")
+		}
+		if info.Capabilities.Measurements {
+			capabilities = append(capabilities, "measurements")
+		}
+		if info.Capabilities.Manifests {
+			capabilities = append(capabilities, "manifests")
+		}
+		if info.Capabilities.PTZ {
+			capabilities = append(capabilities, "ptz")
+		}
+		if info.Capabilities.Commit {
+			capabilities = append(capabilities, "commit")
+		}
+		if len(capabilities) == 0 {
+			capabilities = append(capabilities, "-")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, strings.Join(capabilities, ","), strings.Join(info.RequiredFields, ","), info.Description)
+	}
+	w.Flush()
+	for _, info := range camera.ListDrivers() {
+		if len(info.Options) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s driver options (DriverOptions):\n", info.Name)
+		for _, option := range info.Options {
+			fmt.Printf("  %s - %s (default : %s)\n", option.Name, option.Description, option.Default)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package inputs
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/cognitedata/edge-extractor/drivers/camera"
+	log "github.com/sirupsen/logrus"
 )
 
 type IpCamera struct {
@@ -14,64 +16,137 @@ type IpCamera struct {
 	username string
 	password string
 	cType    string
+	options  map[string]string
 	driver   camera.Driver
 }
 
-func NewIpCamera(ID uint64, name, model, address, cType, username, password string) *IpCamera {
-	driverCon := map[string]camera.DriverConstructor{
-		"fscam":     camera.NewFileSystemCameraDriver,
-		"axis":      camera.NewAxisCameraDriver,
-		"hikvision": camera.NewHikvisionCameraDriver,
-		"reolink":   camera.NewReolinkCameraDriver,
-		"urlcam":    camera.NewUrlCameraDriver,
-		"flir_ax8":  camera.NewFlirAx8CameraDriver,
-		"dahua":     camera.NewDahuaCameraDriver,
+// NewIpCamera creates camera input using driver registered for the model. Returns error if the model is unknown.
+func NewIpCamera(ID uint64, name, model, address, cType, username, password string, options map[string]string) (*IpCamera, error) {
+	driver, err := camera.NewDriver(model)
+	if err != nil {
+		return nil, err
 	}
 
-	driver := driverCon[model]
-
-	if driver == nil {
-		return nil
+	c := IpCamera{ID: ID, Name: name, model: model, address: address, cType: cType, driver: driver, username: username, password: password, options: options}
+	if err := c.Configure(); err != nil {
+		log.Errorf("Failed to configure camera %s . Err : %s", name, err.Error())
 	}
+	return &c, nil
+}
 
-	c := IpCamera{ID: ID, Name: name, model: model, address: address, cType: cType, driver: driver(), username: username, password: password}
-	c.Configure()
-	return &c
+// GetDriverInfo returns metadata of the camera driver.
+func (cam *IpCamera) GetDriverInfo() camera.DriverInfo {
+	info, _ := camera.GetDriverInfo(cam.model)
+	return info
 }
 
+// Configure configures the driver with address , credentials and driver specific options.
 func (cam *IpCamera) Configure() error {
 	if cam.driver == nil {
 		return fmt.Errorf("unknown driver")
 	}
-	return cam.driver.Configure(cam.address, cam.username, cam.password)
+	err := cam.driver.Configure(cam.address, cam.username, cam.password)
+	if err != nil {
+		return err
+	}
+	if optionsConfigurer, ok := cam.driver.(camera.OptionsConfigurer); ok {
+		return optionsConfigurer.ConfigureOptions(cam.options)
+	} else if len(cam.options) > 0 {
+		log.Warnf("Camera model %s doesn't support driver options , options are ignored", cam.model)
+	}
+	return nil
 }
 
-func (cam *IpCamera) ExtractImage() (*camera.Image, error) {
+func (cam *IpCamera) ExtractImage(ctx context.Context) (*camera.Image, error) {
 	if cam.driver == nil {
 		return nil, fmt.Errorf("unknown driver")
 	}
-	return cam.driver.ExtractImage()
+	return cam.driver.ExtractImage(ctx)
 }
 
-func (cam *IpCamera) SubscribeToEventsStream(eventFilters []camera.EventFilter) (chan camera.CameraEvent, error) {
-	if cam.driver == nil {
-		return nil, fmt.Errorf("unknown driver")
+// SupportsEvents returns true if the driver can stream camera events.
+func (cam *IpCamera) SupportsEvents() bool {
+	_, ok := cam.driver.(camera.EventStreamer)
+	return ok
+}
+
+// SupportsMetadata returns true if the driver can extract camera metadata.
+func (cam *IpCamera) SupportsMetadata() bool {
+	_, ok := cam.driver.(camera.MetadataExtractor)
+	return ok
+}
+
+// SupportsMeasurements returns true if the driver can extract structured measurements and alarm states.
+func (cam *IpCamera) SupportsMeasurements() bool {
+	_, ok := cam.driver.(camera.MeasurementsExtractor)
+	return ok
+}
+
+// SupportsPtz returns true if the driver can move the camera to presets.
+func (cam *IpCamera) SupportsPtz() bool {
+	_, ok := cam.driver.(camera.PtzController)
+	return ok
+}
+
+// SupportsCommit returns true if the driver must be notified about delivered images.
+func (cam *IpCamera) SupportsCommit() bool {
+	_, ok := cam.driver.(camera.Committer)
+	return ok
+}
+
+// SupportsManifests returns true if the driver can export camera capabilities manifests.
+func (cam *IpCamera) SupportsManifests() bool {
+	_, ok := cam.driver.(camera.ManifestProvider)
+	return ok
+}
+
+// SubscribeToEventsStream subscribes to camera events , the stream is closed when ctx is cancelled.
+func (cam *IpCamera) SubscribeToEventsStream(ctx context.Context, eventFilters []camera.EventFilter) (chan camera.CameraEvent, error) {
+	streamer, ok := cam.driver.(camera.EventStreamer)
+	if !ok {
+		return nil, fmt.Errorf("camera model %s doesn't support event streams", cam.model)
 	}
-	return cam.driver.SubscribeToEventsStream(eventFilters)
+	return streamer.SubscribeToEventsStream(ctx, eventFilters)
 }
 
-func (cam *IpCamera) ExtractMetadata() ([]byte, error) {
-	if cam.driver == nil {
-		return nil, fmt.Errorf("unknown driver")
+func (cam *IpCamera) ExtractMetadata(ctx context.Context) ([]byte, error) {
+	extractor, ok := cam.driver.(camera.MetadataExtractor)
+	if !ok {
+		return nil, fmt.Errorf("camera model %s doesn't support metadata extraction", cam.model)
+	}
+	return extractor.ExtractMetadata(ctx)
+}
+
+func (cam *IpCamera) ExtractMeasurements(ctx context.Context) (*camera.Measurements, error) {
+	extractor, ok := cam.driver.(camera.MeasurementsExtractor)
+	if !ok {
+		return nil, fmt.Errorf("camera model %s doesn't support measurements extraction", cam.model)
+	}
+	return extractor.ExtractMeasurements(ctx)
+}
+
+func (cam *IpCamera) GotoPreset(ctx context.Context, preset string) error {
+	controller, ok := cam.driver.(camera.PtzController)
+	if !ok {
+		return fmt.Errorf("camera model %s doesn't support ptz", cam.model)
 	}
-	return cam.driver.ExtractMetadata()
+	return controller.GotoPreset(ctx, preset)
 }
 
+// Commit notifies the driver that the image has been delivered. It's no-op for drivers that don't implement camera.Committer.
 func (cam *IpCamera) Commit(transactionId string) error {
-	if cam.driver == nil {
-		return fmt.Errorf("unknown driver")
+	committer, ok := cam.driver.(camera.Committer)
+	if !ok {
+		return nil
+	}
+	return committer.Commit(transactionId)
+}
+
+// Rollback notifies the driver that the image hasn't been delivered. It's no-op for drivers that don't implement camera.Committer.
+func (cam *IpCamera) Rollback(transactionId string) {
+	if committer, ok := cam.driver.(camera.Committer); ok {
+		committer.Rollback(transactionId)
 	}
-	return cam.driver.Commit(transactionId)
 }
 
 func (cam *IpCamera) GetDriver() camera.Driver {
@@ -84,9 +159,11 @@ func (cam *IpCamera) Close() {
 	}
 }
 
+// GetCameraCapabilitiesManifest returns nil if the driver doesn't implement camera.ManifestProvider.
 func (cam *IpCamera) GetCameraCapabilitiesManifest(componentName string) ([]camera.CameraCapabilitiesManifest, error) {
-	if cam.driver == nil {
-		return nil, fmt.Errorf("unknown driver")
+	provider, ok := cam.driver.(camera.ManifestProvider)
+	if !ok {
+		return nil, nil
 	}
-	return cam.driver.GetCameraCapabilitiesManifest(componentName)
+	return provider.GetCameraCapabilitiesManifest(componentName)
 }
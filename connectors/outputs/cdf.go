@@ -1 +1,49 @@
 package outputs
+
+import (
+	"context"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/internal"
+)
+
+// CdfOutput uploads files and events to CDF.
+type CdfOutput struct {
+	client *internal.CdfClient
+}
+
+func NewCdfOutput(client *internal.CdfClient) *CdfOutput {
+	return &CdfOutput{client: client}
+}
+
+func (out *CdfOutput) Type() string {
+	return OutputTypeCdf
+}
+
+func (out *CdfOutput) UploadFile(ctx context.Context, file File) error {
+	if file.Path != "" {
+		return out.client.UploadLocalFile(ctx, file.Path, file.ExternalId, file.Name, file.MimeType, file.AssetId, file.Metadata)
+	}
+	return out.client.UploadInMemoryFile(ctx, file.Body, file.ExternalId, file.Name, file.MimeType, file.AssetId, file.Metadata)
+}
+
+func (out *CdfOutput) CreateEvents(ctx context.Context, events []Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cdfEvents := make(core.EventList, len(events))
+	for i, event := range events {
+		cdfEvents[i] = core.Event{
+			ExternalID:  event.ExternalId,
+			StartTime:   event.StartTime,
+			EndTime:     event.EndTime,
+			Type:        event.Type,
+			Subtype:     event.Subtype,
+			Description: event.Description,
+			Metadata:    event.Metadata,
+			Source:      event.Source,
+		}
+	}
+	_, err := out.client.Client().Events.Create(cdfEvents)
+	return err
+}
@@ -0,0 +1,194 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/internal"
+)
+
+// FileSystemOutput writes files and events into date-partitioned directory tree :
+//
+//	<dir>/<source>/<YYYY>/<MM>/<DD>/<externalId>.<ext>  - file body
+//	<dir>/<source>/<YYYY>/<MM>/<DD>/<externalId>.json   - file metadata (sidecar)
+//	<dir>/events/<YYYY>/<MM>/<DD>/events.jsonl          - events , one JSON document per line
+//
+// Partitions are based on UTC time of writing.
+type FileSystemOutput struct {
+	dir       string
+	eventsMux sync.Mutex
+}
+
+// FileSidecar is the content of JSON sidecar file written next to each file.
+type FileSidecar struct {
+	Source     string
+	ExternalId string
+	Name       string
+	MimeType   string
+	AssetId    uint64            `json:",omitempty"`
+	Metadata   map[string]string `json:",omitempty"`
+	Size       int
+	CreatedAt  int64 // unix timestamp in milliseconds
+}
+
+// NewFileSystemOutput creates output that writes data into dir. The directory is created if it doesn't exist.
+func NewFileSystemOutput(dir string) (*FileSystemOutput, error) {
+	if dir == "" {
+		dir = filepath.Join(internal.GetBinaryDir(), "output")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s : %w", dir, err)
+	}
+	return &FileSystemOutput{dir: dir}, nil
+}
+
+func (out *FileSystemOutput) Type() string {
+	return OutputTypeFileSystem
+}
+
+// Dir returns root directory of the output.
+func (out *FileSystemOutput) Dir() string {
+	return out.dir
+}
+
+// UploadFile writes file body and sidecar metadata. Returns ErrDuplicate if file with the same external id already exists in the partition.
+func (out *FileSystemOutput) UploadFile(ctx context.Context, file File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	source := file.Source
+	if source == "" {
+		source = "default"
+	}
+	partitionDir := filepath.Join(out.dir, sanitizeFileName(source), now.Format("2006"), now.Format("01"), now.Format("02"))
+	// names are sanitized , the check is the last line of defence against writing outside of the output directory
+	if !isInDir(out.dir, partitionDir) {
+		return fmt.Errorf("file %s : source %s resolves outside of output directory", file.ExternalId, file.Source)
+	}
+	if err := os.MkdirAll(partitionDir, 0755); err != nil {
+		return err
+	}
+	baseName := sanitizeFileName(file.ExternalId)
+	if baseName == "" {
+		baseName = fmt.Sprintf("%d", now.UnixNano())
+	}
+	sidecarPath := filepath.Join(partitionDir, baseName+".json")
+	if _, err := os.Stat(sidecarPath); err == nil {
+		return fmt.Errorf("file %s : %w", file.ExternalId, ErrDuplicate)
+	}
+	size, err := file.Size()
+	if err != nil {
+		return err
+	}
+	sidecar, err := json.MarshalIndent(FileSidecar{
+		Source:     file.Source,
+		ExternalId: file.ExternalId,
+		Name:       file.Name,
+		MimeType:   file.MimeType,
+		AssetId:    file.AssetId,
+		Metadata:   file.Metadata,
+		Size:       int(size),
+		CreatedAt:  now.UnixMilli(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// body is written first , so the sidecar always points to complete file
+	bodyPath := filepath.Join(partitionDir, baseName+fileExtension(file))
+	if file.Path != "" {
+		src, err := os.Open(file.Path)
+		if err != nil {
+			return err
+		}
+		err = internal.CopyFileAtomic(bodyPath, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	} else if err := internal.WriteFileAtomic(bodyPath, file.Body); err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(sidecarPath, sidecar)
+}
+
+// CreateEvents appends events to daily events file.
+func (out *FileSystemOutput) CreateEvents(ctx context.Context, events []Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	partitionDir := filepath.Join(out.dir, "events", now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(partitionDir, 0755); err != nil {
+		return err
+	}
+	var lines []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	out.eventsMux.Lock()
+	defer out.eventsMux.Unlock()
+	f, err := os.OpenFile(filepath.Join(partitionDir, "events.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fileExtension returns extension of the file name or extension derived from mime type if the name has no extension.
+func fileExtension(file File) string {
+	if ext := filepath.Ext(file.Name); len(ext) > 1 && len(ext) <= 6 && !strings.ContainsAny(ext, " :") {
+		return sanitizeFileName(ext)
+	}
+	if file.MimeType == "image/jpeg" {
+		return ".jpeg"
+	}
+	if file.MimeType != "" {
+		if exts, err := mime.ExtensionsByType(file.MimeType); err == nil && len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ".bin"
+}
+
+// sanitizeFileName replaces characters that are not safe in file names. Dot-only names ("." , "..") are replaced too ,
+// otherwise they would refer to the current or parent directory.
+func sanitizeFileName(name string) string {
+	if name != "" && strings.Trim(name, ".") == "" {
+		return strings.Repeat("_", len(name))
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// isInDir returns true if cleaned path is dir itself or located inside of dir.
+func isInDir(dir, path string) bool {
+	dir, path = filepath.Clean(dir), filepath.Clean(path)
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
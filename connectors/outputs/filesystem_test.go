@@ -0,0 +1,86 @@
+package outputs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "gate-camera", expected: "gate-camera"},
+		{name: "", expected: ""},
+		{name: ".", expected: "_"},
+		{name: "..", expected: "__"},
+		{name: "...", expected: "___"},
+		{name: "../etc", expected: ".._etc"},
+		{name: "a/../../b", expected: "a_.._.._b"},
+		{name: `C:\cam 1`, expected: "C__cam_1"},
+		{name: ".hidden", expected: ".hidden"},
+	}
+	for _, tt := range tests {
+		if result := sanitizeFileName(tt.name); result != tt.expected {
+			t.Errorf("%q : expected %q , got %q", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestIsInDir(t *testing.T) {
+	tests := []struct {
+		dir, path string
+		isInDir   bool
+	}{
+		{dir: "/data/output", path: "/data/output", isInDir: true},
+		{dir: "/data/output", path: "/data/output/cam/2024", isInDir: true},
+		{dir: "/data/output/", path: "/data/output/cam", isInDir: true},
+		{dir: "/data/output", path: "/data/output/../cam"},
+		{dir: "/data/output", path: "/data/output2/cam"},
+		{dir: "/data/output", path: "/data"},
+		{dir: "/", path: "/data", isInDir: true},
+	}
+	for _, tt := range tests {
+		if isInDir := isInDir(tt.dir, tt.path); isInDir != tt.isInDir {
+			t.Errorf("%s in %s : expected %v , got %v", tt.path, tt.dir, tt.isInDir, isInDir)
+		}
+	}
+}
+
+func TestFileSystemOutputSourceStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "output")
+	out, err := NewFileSystemOutput(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, source := range []string{".", "..", "../..", "../outside", "./"} {
+		file := File{Source: source, ExternalId: "img" + string(rune('a'+i)), Name: "img.jpg", Body: []byte("jpeg")}
+		if err := out.UploadFile(context.Background(), file); err != nil {
+			t.Fatalf("source %q : %s", source, err)
+		}
+	}
+	// nothing is written next to the output directory
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "output" {
+		t.Fatalf("files are written outside of output directory : %v", entries)
+	}
+	// each source has own directory , dot-only sources don't write into the output root
+	sources, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range sources {
+		names = append(names, entry.Name())
+	}
+	if expected := ".._..,.._outside,._,_,__"; strings.Join(names, ",") != expected {
+		t.Fatalf("expected source directories %s , got %s", expected, strings.Join(names, ","))
+	}
+}
@@ -0,0 +1,238 @@
+package outputs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/internal"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	OutputTypeMqtt = "mqtt"
+
+	MqttImageModeNone      = "none"      // images are not published
+	MqttImageModePayload   = "payload"   // image body is published as message payload
+	MqttImageModeReference = "reference" // JSON document with file metadata is published , image body is written to the main output
+
+	DefaultMqttEventTopic = "edge-extractor/{cameraName}/events/{topic}"
+	DefaultMqttImageTopic = "edge-extractor/{cameraName}/images"
+
+	mqttPublishTimeout = 30 * time.Second
+	mqttConnectTimeout = 5 * time.Second
+)
+
+type MqttTLSConfig struct {
+	Enabled            bool
+	CACertFile         string // PEM encoded CA certificate , system CAs are used if empty
+	CertFile           string // PEM encoded client certificate (mutual TLS)
+	KeyFile            string // PEM encoded client key (mutual TLS)
+	InsecureSkipVerify bool
+}
+
+// MqttConfig configures MQTT output. Topics are templates , variables in curly brackets are replaced with event or file values ,
+// for example {cameraName} , {cameraId} , {topic} , {type} , {externalId} or any metadata key.
+type MqttConfig struct {
+	Enabled    bool
+	Broker     string // broker URI , for example tcp://localhost:1883 , ssl://broker:8883 or ws://broker:80/mqtt
+	ClientID   string // default edge-extractor-<hostname>
+	Username   string
+	Password   string
+	QoS        byte // 0 , 1 or 2
+	Retained   bool
+	TLS        MqttTLSConfig
+	EventTopic string // default edge-extractor/{cameraName}/events/{topic}
+	ImageTopic string // default edge-extractor/{cameraName}/images
+	ImageMode  string // none (default) , payload , reference
+}
+
+// MqttImageReference is the payload of image message in reference mode.
+type MqttImageReference struct {
+	Source     string
+	ExternalId string
+	Name       string
+	MimeType   string
+	AssetId    uint64            `json:",omitempty"`
+	Metadata   map[string]string `json:",omitempty"`
+	Size       int
+}
+
+// MqttOutput publishes events as JSON documents and optionally images to MQTT broker.
+// Connection is established in background and restored automatically if lost.
+type MqttOutput struct {
+	config MqttConfig
+	client mqtt.Client
+}
+
+// NewMqttOutput creates MQTT output and connects to the broker. It waits up to 5 seconds for the initial connection , then the connection is retried in background.
+func NewMqttOutput(config MqttConfig) (*MqttOutput, error) {
+	if config.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker address is not set")
+	}
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("invalid mqtt QoS %d , supported values : 0 , 1 , 2", config.QoS)
+	}
+	switch config.ImageMode {
+	case "":
+		config.ImageMode = MqttImageModeNone
+	case MqttImageModeNone, MqttImageModePayload, MqttImageModeReference:
+	default:
+		return nil, fmt.Errorf("unknown mqtt image mode %q , supported modes : none , payload , reference", config.ImageMode)
+	}
+	if config.EventTopic == "" {
+		config.EventTopic = DefaultMqttEventTopic
+	}
+	if config.ImageTopic == "" {
+		config.ImageTopic = DefaultMqttImageTopic
+	}
+	if config.ClientID == "" {
+		hostname, _ := os.Hostname()
+		config.ClientID = "edge-extractor-" + hostname
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			log.Infof("Connected to MQTT broker %s", config.Broker)
+		}).
+		SetConnectionLostHandler(func(c mqtt.Client, err error) {
+			log.Warnf("Connection to MQTT broker %s has been lost. Err : %s", config.Broker, err.Error())
+		})
+	if config.TLS.Enabled {
+		tlsConfig, err := newMqttTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	out := &MqttOutput{config: config, client: mqtt.NewClient(opts)}
+	// with ConnectRetry the token completes only after successful connection , the client keeps retrying in background
+	if !out.client.Connect().WaitTimeout(mqttConnectTimeout) {
+		log.Warnf("MQTT broker %s is not reachable yet , connection will be retried in background", config.Broker)
+	}
+	return out, nil
+}
+
+func newMqttTLSConfig(config MqttTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CACertFile != "" {
+		caCert, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mqtt CA certificate : %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("invalid mqtt CA certificate %s", config.CACertFile)
+		}
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mqtt client certificate : %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func (out *MqttOutput) Type() string {
+	return OutputTypeMqtt
+}
+
+// UploadFile publishes image body or image reference depending on image mode. The operation is no-op if image mode is none.
+func (out *MqttOutput) UploadFile(ctx context.Context, file File) error {
+	vars := map[string]string{"cameraName": file.Source, "source": file.Source, "externalId": file.ExternalId, "name": file.Name, "mimeType": file.MimeType}
+	var payload []byte
+	switch out.config.ImageMode {
+	case MqttImageModePayload:
+		payload = file.Body
+		if file.Path != "" {
+			var err error
+			if payload, err = os.ReadFile(file.Path); err != nil {
+				return err
+			}
+		}
+	case MqttImageModeReference:
+		size, err := file.Size()
+		if err != nil {
+			return err
+		}
+		payload, err = json.Marshal(MqttImageReference{
+			Source:     file.Source,
+			ExternalId: file.ExternalId,
+			Name:       file.Name,
+			MimeType:   file.MimeType,
+			AssetId:    file.AssetId,
+			Metadata:   file.Metadata,
+			Size:       int(size),
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	return out.publish(ctx, ExpandTopicTemplate(out.config.ImageTopic, vars, file.Metadata), payload)
+}
+
+// CreateEvents publishes each event as JSON document to event topic.
+func (out *MqttOutput) CreateEvents(ctx context.Context, events []Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		vars := map[string]string{"type": event.Type, "subtype": event.Subtype, "source": event.Source}
+		if err := out.publish(ctx, ExpandTopicTemplate(out.config.EventTopic, vars, event.Metadata), payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the broker , pending messages are given up to 1 second to be sent.
+func (out *MqttOutput) Close() {
+	out.client.Disconnect(1000)
+}
+
+// publish sends the message and waits for delivery confirmation (QoS 1 and 2). Messages are not queued while the broker is not connected.
+func (out *MqttOutput) publish(ctx context.Context, topic string, payload []byte) error {
+	if !out.client.IsConnectionOpen() {
+		return fmt.Errorf("mqtt broker %s is not connected", out.config.Broker)
+	}
+	token := out.client.Publish(topic, out.config.QoS, out.config.Retained, payload)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(mqttPublishTimeout):
+		return fmt.Errorf("timeout while publishing message to mqtt topic %s", topic)
+	}
+}
+
+// ExpandTopicTemplate replaces {name} placeholders in template with values from vars and metadata (vars take precedence).
+// MQTT wildcards are removed from values. Unknown placeholders are replaced with empty string.
+func ExpandTopicTemplate(template string, vars map[string]string, metadata map[string]string) string {
+	wildcardsReplacer := strings.NewReplacer("+", "_", "#", "_")
+	return internal.ExpandTemplate(template, func(name string) string {
+		value, ok := vars[name]
+		if !ok || value == "" {
+			value = metadata[name]
+		}
+		return wildcardsReplacer.Replace(value)
+	})
+}
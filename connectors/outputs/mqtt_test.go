@@ -0,0 +1,254 @@
+package outputs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mqttTestMessage is PUBLISH packet received by mqttTestBroker.
+type mqttTestMessage struct {
+	Topic    string
+	QoS      byte
+	Retained bool
+	Payload  []byte
+}
+
+// mqttTestBroker is minimal in-process MQTT 3.1.1 broker. It accepts any client and records published messages ,
+// subscriptions aren't supported. QoS 1 messages are acknowledged with PUBACK.
+type mqttTestBroker struct {
+	listener net.Listener
+	messages chan mqttTestMessage
+}
+
+func newMqttTestBroker(t *testing.T) *mqttTestBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &mqttTestBroker{listener: listener, messages: make(chan mqttTestMessage, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	return broker
+}
+
+func (b *mqttTestBroker) address() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *mqttTestBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := binary.ReadUvarint(reader) // MQTT remaining length uses the same encoding
+		if err != nil {
+			return
+		}
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			return
+		}
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			qos := header >> 1 & 0x03
+			topicLength := int(binary.BigEndian.Uint16(packet))
+			message := mqttTestMessage{Topic: string(packet[2 : 2+topicLength]), QoS: qos, Retained: header&0x01 != 0}
+			payload := packet[2+topicLength:]
+			if qos > 0 {
+				conn.Write([]byte{0x40, 0x02, payload[0], payload[1]})
+				payload = payload[2:]
+			}
+			message.Payload = payload
+			b.messages <- message
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+// nextMessage returns the next published message , the test fails if nothing is published within 5 seconds.
+func (b *mqttTestBroker) nextMessage(t *testing.T) mqttTestMessage {
+	t.Helper()
+	select {
+	case message := <-b.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("message wasn't published")
+	}
+	return mqttTestMessage{}
+}
+
+func (b *mqttTestBroker) expectNoMessage(t *testing.T) {
+	t.Helper()
+	select {
+	case message := <-b.messages:
+		t.Fatalf("unexpected message on topic %s", message.Topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func newTestMqttOutput(t *testing.T, config MqttConfig) *MqttOutput {
+	out, err := NewMqttOutput(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(out.Close)
+	return out
+}
+
+func TestMqttOutputCreateEvents(t *testing.T) {
+	broker := newMqttTestBroker(t)
+	out := newTestMqttOutput(t, MqttConfig{Broker: broker.address(), ClientID: "test", QoS: 1, Retained: true})
+	event := Event{
+		Source:    "edge-extractor:camera",
+		StartTime: 1714644930000,
+		EndTime:   1714644935000,
+		Type:      "motion",
+		Subtype:   "notification",
+		Metadata:  map[string]string{"cameraName": "gate", "cameraId": "42", "topic": "tns1:VideoSource/MotionAlarm"},
+	}
+	if err := out.CreateEvents(context.Background(), []Event{event, event}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		message := broker.nextMessage(t)
+		if message.Topic != "edge-extractor/gate/events/tns1:VideoSource/MotionAlarm" {
+			t.Errorf("unexpected topic %s", message.Topic)
+		}
+		if message.QoS != 1 || !message.Retained {
+			t.Errorf("expected QoS 1 retained message , got QoS %d retained %v", message.QoS, message.Retained)
+		}
+		var published Event
+		if err := json.Unmarshal(message.Payload, &published); err != nil {
+			t.Fatal(err)
+		}
+		if published.Type != event.Type || published.StartTime != event.StartTime || published.EndTime != event.EndTime || published.Metadata["cameraId"] != "42" {
+			t.Errorf("unexpected payload %s", message.Payload)
+		}
+	}
+}
+
+func TestMqttOutputUploadFile(t *testing.T) {
+	broker := newMqttTestBroker(t)
+	path := filepath.Join(t.TempDir(), "image.jpg")
+	if err := os.WriteFile(path, []byte("image from disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := File{Source: "gate", ExternalId: "gate_1714644930", Name: "gate.jpg", MimeType: "image/jpeg", AssetId: 7, Metadata: map[string]string{"ptzPreset": "door"}, Body: []byte("image in memory")}
+	fileOnDisk := file
+	fileOnDisk.Body = nil
+	fileOnDisk.Path = path
+
+	t.Run("payload", func(t *testing.T) {
+		out := newTestMqttOutput(t, MqttConfig{Broker: broker.address(), ClientID: "payload", QoS: 1, ImageMode: MqttImageModePayload, ImageTopic: "cams/{cameraName}/{ptzPreset}/{name}"})
+		for _, f := range []File{file, fileOnDisk} {
+			if err := out.UploadFile(context.Background(), f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, expected := range []string{"image in memory", "image from disk"} {
+			message := broker.nextMessage(t)
+			if message.Topic != "cams/gate/door/gate.jpg" || string(message.Payload) != expected {
+				t.Errorf("unexpected message %s : %q", message.Topic, message.Payload)
+			}
+		}
+	})
+
+	t.Run("reference", func(t *testing.T) {
+		out := newTestMqttOutput(t, MqttConfig{Broker: broker.address(), ClientID: "reference", QoS: 1, ImageMode: MqttImageModeReference})
+		if err := out.UploadFile(context.Background(), fileOnDisk); err != nil {
+			t.Fatal(err)
+		}
+		message := broker.nextMessage(t)
+		if message.Topic != "edge-extractor/gate/images" {
+			t.Errorf("unexpected topic %s", message.Topic)
+		}
+		var reference MqttImageReference
+		if err := json.Unmarshal(message.Payload, &reference); err != nil {
+			t.Fatal(err)
+		}
+		expected := MqttImageReference{Source: "gate", ExternalId: "gate_1714644930", Name: "gate.jpg", MimeType: "image/jpeg", AssetId: 7, Size: len("image from disk")}
+		if reference.Source != expected.Source || reference.ExternalId != expected.ExternalId || reference.Name != expected.Name ||
+			reference.MimeType != expected.MimeType || reference.AssetId != expected.AssetId || reference.Size != expected.Size || reference.Metadata["ptzPreset"] != "door" {
+			t.Errorf("expected %+v , got %+v", expected, reference)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		out := newTestMqttOutput(t, MqttConfig{Broker: broker.address(), ClientID: "none", QoS: 1})
+		if err := out.UploadFile(context.Background(), file); err != nil {
+			t.Fatal(err)
+		}
+		broker.expectNoMessage(t)
+	})
+}
+
+func TestNewMqttOutputValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MqttConfig
+	}{
+		{name: "missing broker", config: MqttConfig{}},
+		{name: "invalid QoS", config: MqttConfig{Broker: "tcp://localhost:1883", QoS: 3}},
+		{name: "unknown image mode", config: MqttConfig{Broker: "tcp://localhost:1883", ImageMode: "thumbnail"}},
+		{name: "missing CA certificate", config: MqttConfig{Broker: "ssl://localhost:8883", TLS: MqttTLSConfig{Enabled: true, CACertFile: "/nonexistent/ca.pem"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMqttOutput(tt.config); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestExpandTopicTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		metadata map[string]string
+		expected string
+	}{
+		{name: "default event topic", template: DefaultMqttEventTopic, metadata: map[string]string{"cameraName": "gate", "topic": "VMD/1"}, expected: "edge-extractor/gate/events/VMD/1"},
+		{name: "default image topic", template: DefaultMqttImageTopic, vars: map[string]string{"cameraName": "gate"}, expected: "edge-extractor/gate/images"},
+		{name: "vars take precedence", template: "{type}", vars: map[string]string{"type": "motion"}, metadata: map[string]string{"type": "other"}, expected: "motion"},
+		{name: "empty var falls back to metadata", template: "{type}", vars: map[string]string{"type": ""}, metadata: map[string]string{"type": "other"}, expected: "other"},
+		{name: "unknown placeholder", template: "cams/{unknown}/images", expected: "cams//images"},
+		{name: "wildcards are removed", template: "cams/{cameraName}", vars: map[string]string{"cameraName": "gate+#1"}, expected: "cams/gate__1"},
+		{name: "wildcards in template are kept", template: "cams/+/{cameraName}/#", vars: map[string]string{"cameraName": "gate"}, expected: "cams/+/gate/#"},
+		{name: "no placeholders", template: "cams/events", expected: "cams/events"},
+		{name: "unclosed placeholder", template: "cams/{cameraName", vars: map[string]string{"cameraName": "gate"}, expected: "cams/{cameraName"},
+		{name: "adjacent placeholders", template: "{cameraName}{cameraId}", metadata: map[string]string{"cameraName": "gate", "cameraId": "42"}, expected: "gate42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if topic := ExpandTopicTemplate(tt.template, tt.vars, tt.metadata); topic != tt.expected {
+				t.Errorf("expected %q , got %q", tt.expected, topic)
+			}
+		})
+	}
+}
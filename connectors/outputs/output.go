@@ -0,0 +1,112 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	dto_error "github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto"
+	"github.com/cognitedata/edge-extractor/internal"
+)
+
+const (
+	OutputTypeCdf        = "cdf"
+	OutputTypeFileSystem = "filesystem"
+)
+
+// ErrDuplicate is returned by outputs if the file with the same external id already exists.
+var ErrDuplicate = errors.New("duplicate external id")
+
+// File is a binary file (image , manifest , etc.) with its metadata.
+type File struct {
+	Source     string // producer of the file , for example camera name
+	ExternalId string
+	Name       string
+	MimeType   string
+	AssetId    uint64
+	Metadata   map[string]string
+	Body       []byte
+	Path       string // if set , the body is streamed from the local file and Body is ignored
+}
+
+// Size returns size of the file body.
+func (f *File) Size() (int64, error) {
+	if f.Path == "" {
+		return int64(len(f.Body)), nil
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Event is a time-bound event , for example camera motion detection event.
+type Event struct {
+	Source      string
+	ExternalId  string
+	StartTime   int64 // unix timestamp in milliseconds
+	EndTime     int64
+	Type        string
+	Subtype     string
+	Description string
+	Metadata    map[string]string
+}
+
+// Output is a destination of extracted data.
+type Output interface {
+	// UploadFile writes file to the output. Returns ErrDuplicate (or error that satisfies IsDuplicateError) if the file already exists.
+	UploadFile(ctx context.Context, file File) error
+	// CreateEvents writes events to the output.
+	CreateEvents(ctx context.Context, events []Event) error
+	// Type returns output type , for example "cdf" or "filesystem".
+	Type() string
+}
+
+// Config configures output of an integration. CDF is used by default.
+type Config struct {
+	Type string // cdf (default) , filesystem
+	Dir  string // root directory of filesystem output
+}
+
+// NewOutput creates output from config. cdfClient is used by CDF output only.
+func NewOutput(config Config, cdfClient *internal.CdfClient) (Output, error) {
+	switch config.Type {
+	case "", OutputTypeCdf:
+		if cdfClient == nil {
+			return nil, fmt.Errorf("cdf output requires cdf client")
+		}
+		return NewCdfOutput(cdfClient), nil
+	case OutputTypeFileSystem:
+		return NewFileSystemOutput(config.Dir)
+	default:
+		return nil, fmt.Errorf("unknown output type %q , supported types : %s, %s", config.Type, OutputTypeCdf, OutputTypeFileSystem)
+	}
+}
+
+// IsPermanentError returns true if retrying the request can't succeed , for example invalid asset id or too large file.
+// Client errors (4xx) are permanent except conflicts (409) , timeouts (408) and rate limiting (429).
+func IsPermanentError(err error) bool {
+	var statusCode int
+	var apiErr *dto_error.APIError
+	var httpErr *internal.HTTPStatusError
+	switch {
+	case errors.As(err, &apiErr):
+		statusCode = apiErr.Code
+	case errors.As(err, &httpErr):
+		statusCode = httpErr.StatusCode
+	default:
+		return false
+	}
+	return statusCode >= 400 && statusCode < 500 && statusCode != 408 && statusCode != 409 && statusCode != 429
+}
+
+// IsDuplicateError returns true if the file or event already exists in the output.
+func IsDuplicateError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrDuplicate) || strings.Contains(err.Error(), "Duplicate external ids")
+}
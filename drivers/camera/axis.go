@@ -1,22 +1,23 @@
 package camera
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	edgedac "github.com/cognitedata/edge-extractor/internal/auth/digest"
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
-	dac "github.com/xinsnake/go-http-digest-auth-client"
 )
 
 type AxisCameraDriver struct {
 	httpClient      http.Client
-	digestTransport *dac.DigestTransport
+	digestTransport *edgedac.DigestTransport
 	address         string
 	username        string
 	password        string
@@ -61,6 +62,13 @@ type AxisEvent struct {
 	Params     AxisEventParams `json:"params"`
 }
 
+func init() {
+	Register("axis", NewAxisCameraDriver, DriverInfo{
+		Description:    "Axis cameras (VAPIX)",
+		RequiredFields: []string{"Address", "Username", "Password"},
+	})
+}
+
 func NewAxisCameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
@@ -75,15 +83,15 @@ func (cam *AxisCameraDriver) Configure(address, username, password string) error
 	return nil
 }
 
-func (cam *AxisCameraDriver) ExtractImage() (*Image, error) {
+func (cam *AxisCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
 	address := cam.address + "/axis-cgi/jpg/image.cgi"
 	if cam.digestTransport == nil {
-		t := dac.NewTransport(cam.username, cam.password)
+		t := edgedac.NewTransport(cam.username, cam.password)
 		cam.digestTransport = &t
 		cam.digestTransport.HTTPClient = &cam.httpClient
 	}
 
-	req, err := http.NewRequest("GET", address, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -116,22 +124,42 @@ func (cam *AxisCameraDriver) ExtractImage() (*Image, error) {
 	return &img, nil
 }
 
-func (cam *AxisCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
+// GotoPreset moves the camera to server preset using VAPIX PTZ API. Preset is a name of server preset.
+func (cam *AxisCameraDriver) GotoPreset(ctx context.Context, preset string) error {
+	if cam.digestTransport == nil {
+		t := edgedac.NewTransport(cam.username, cam.password)
+		cam.digestTransport = &t
+		cam.digestTransport.HTTPClient = &cam.httpClient
+	}
+	address := cam.address + "/axis-cgi/com/ptz.cgi?camera=1&gotoserverpresetname=" + url.QueryEscape(preset)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cam.digestTransport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("camera ptz api returned error code %s", resp.Status)
+	}
+	// VAPIX returns 200 with error text in the body , for example if preset doesn't exist
+	if strings.Contains(strings.ToLower(string(body)), "error") {
+		return fmt.Errorf("camera ptz api returned error : %s", strings.TrimSpace(string(body)))
+	}
+	return nil
 }
 
 func (cam *AxisCameraDriver) Ping(address string) bool {
 	return true
 }
 
-func (cam *AxisCameraDriver) Commit(transactionId string) error {
-	return nil
-}
-
 func (cam *AxisCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
 	address := cam.address + "/vapix/services"
 	if cam.digestTransport == nil {
-		t := dac.NewTransport(cam.username, cam.password)
+		t := edgedac.NewTransport(cam.username, cam.password)
 		cam.digestTransport = &t
 		cam.digestTransport.HTTPClient = &cam.httpClient
 	}
@@ -176,8 +204,9 @@ func (cam *AxisCameraDriver) GetCameraCapabilitiesManifest(component string) ([]
 	return manifests, nil
 }
 
-// Connect to Axis WebSocket API and subscribe to events from the camera , for example motion detection
-func (cam *AxisCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (stream chan CameraEvent, err error) {
+// Connect to Axis WebSocket API and subscribe to events from the camera , for example motion detection.
+// The connection is closed when ctx is cancelled.
+func (cam *AxisCameraDriver) SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (stream chan CameraEvent, err error) {
 	// convert the address to a websocket address
 	digestAddress := cam.address + "/vapix/ws-data-stream?sources=events"
 	digestRequest := edgedac.NewRequest(cam.username, cam.password, "GET", digestAddress, "")
@@ -186,7 +215,7 @@ func (cam *AxisCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter)
 	log.Info("Connecting to Axis camera event stream over WS. Address : ", address)
 	var authHeader string
 	var resp *http.Response
-	cam.wsConnection, resp, err = websocket.DefaultDialer.Dial(address, nil)
+	cam.wsConnection, resp, err = websocket.DefaultDialer.DialContext(ctx, address, nil)
 	if resp != nil && resp.StatusCode == 401 {
 		authHeader, err = digestRequest.GetNewDigestAuthHeaderFromResponse(resp)
 		if err != nil {
@@ -195,7 +224,7 @@ func (cam *AxisCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter)
 		}
 		header := http.Header{"Authorization": []string{authHeader}}
 		log.Debug("Using auth header ", header)
-		cam.wsConnection, resp, err = websocket.DefaultDialer.Dial(address, header)
+		cam.wsConnection, resp, err = websocket.DefaultDialer.DialContext(ctx, address, header)
 	}
 
 	if err != nil {
@@ -215,9 +244,20 @@ func (cam *AxisCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter)
 	}
 
 	messages := make(chan CameraEvent, 10)
+	wsConnection := cam.wsConnection
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		cam.wsMessageHandler(axisEventFilterList, messages)
 	}()
+	go func() {
+		// closing the connection unblocks ReadMessage in the message handler
+		select {
+		case <-ctx.Done():
+			wsConnection.Close()
+		case <-done:
+		}
+	}()
 	return messages, nil
 }
 
@@ -257,13 +297,7 @@ func (cam *AxisCameraDriver) wsMessageHandler(axisEventFilterList []AxisEventFil
 		if exisEvent.Method == "events:configure" {
 			continue
 		}
-		cameraEvent := CameraEvent{
-			CoreType:  "notification",
-			Type:      "CamMotionDetected",
-			Source:    "cam:axis:name",
-			Topic:     exisEvent.Params.Notification.Topic,
-			Timestamp: exisEvent.Params.Notification.Timestamp,
-			RawData:   message}
+		cameraEvent := axisNotificationToCameraEvent(exisEvent.Params.Notification, "cam:axis:"+cam.address, message)
 		select {
 		case messages <- cameraEvent:
 
@@ -275,6 +309,36 @@ func (cam *AxisCameraDriver) wsMessageHandler(axisEventFilterList []AxisEventFil
 	log.Info("Disconnected from camera websocket.")
 }
 
+// axisNotificationToCameraEvent maps Axis notification onto CameraEvent. Type is the last segment of the topic without namespace ,
+// for example MotionAlarm for topic tns1:VideoSource/tnsaxis:MotionAlarm . State is set for stateful events (data field active , state , etc.).
+func axisNotificationToCameraEvent(notification AxisNotification, source string, rawData []byte) CameraEvent {
+	eventType := notification.Topic
+	if i := strings.LastIndex(eventType, "/"); i >= 0 {
+		eventType = eventType[i+1:]
+	}
+	if i := strings.LastIndex(eventType, ":"); i >= 0 {
+		eventType = eventType[i+1:]
+	}
+	timestamp := notification.Timestamp
+	if timestamp == 0 {
+		timestamp = time.Now().UnixMilli()
+	}
+	return CameraEvent{
+		CoreType:  "notification",
+		Type:      eventType,
+		Source:    source,
+		Topic:     notification.Topic,
+		Timestamp: timestamp,
+		RawData:   rawData,
+		State:     EventStateFromData(notification.Message.Data),
+		Message: EventMessage{
+			Source: notification.Message.Source,
+			Key:    notification.Message.Key,
+			Data:   notification.Message.Data,
+		},
+	}
+}
+
 func (cam *AxisCameraDriver) Close() {
 	log.Info("Stopping Axis camera driver")
 	if cam.wsConnection != nil {
@@ -1,24 +1,35 @@
 package camera
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strings"
 	"time"
 
+	edgedac "github.com/cognitedata/edge-extractor/internal/auth/digest"
 	log "github.com/sirupsen/logrus"
-	dac "github.com/xinsnake/go-http-digest-auth-client"
 )
 
 type DahuaCameraDriver struct {
 	httpClient      http.Client
-	digestTransport *dac.DigestTransport
+	digestTransport *edgedac.DigestTransport
 	address         string
 	username        string
 	password        string
 }
 
+func init() {
+	Register("dahua", NewDahuaCameraDriver, DriverInfo{
+		Description:    "Dahua cameras (HTTP API)",
+		RequiredFields: []string{"Address", "Username", "Password"},
+	})
+}
+
 func NewDahuaCameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
@@ -33,18 +44,18 @@ func (cam *DahuaCameraDriver) Configure(address, username, password string) erro
 	return nil
 }
 
-func (cam *DahuaCameraDriver) ExtractImage() (*Image, error) {
+func (cam *DahuaCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
 	// http://10.22.15.61/cgi-bin/snapshot.cgi
 
 	address := cam.address + "/cgi-bin/snapshot.cgi"
 
 	if cam.digestTransport == nil {
-		t := dac.NewTransport(cam.username, cam.password)
+		t := edgedac.NewTransport(cam.username, cam.password)
 		cam.digestTransport = &t
 		cam.digestTransport.HTTPClient = &cam.httpClient
 	}
 
-	req, err := http.NewRequest("GET", address, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -76,25 +87,172 @@ func (cam *DahuaCameraDriver) ExtractImage() (*Image, error) {
 	return &img, nil
 }
 
-func (cam *DahuaCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
+// dahuaEventStreamIdleTimeout is the maximum time without any data on event stream , heartbeat is requested every dahuaEventStreamHeartbeat seconds.
+const (
+	dahuaEventStreamIdleTimeout = 60 * time.Second
+	dahuaEventStreamHeartbeat   = 10
+)
+
+// SubscribeToEventsStream attaches to eventManager.cgi event stream and starts reading events in background.
+// TopicFilter is mapped to Dahua event codes (for example VideoMotion , CrossLineDetection , CrossRegionDetection) , all events are requested if
+// at least one filter doesn't have a topic. Event topic is <code>/<index> , for example VideoMotion/0 . Type is the event code and State is
+// active (Start) , inactive (Stop) or pulse (Pulse). ContentFilter is matched as a substring of the raw event data.
+// The channel is closed when the stream is lost or ctx is cancelled.
+func (cam *DahuaCameraDriver) SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (chan CameraEvent, error) {
+	// event stream is a long living request , the default client timeout would terminate it
+	transport := edgedac.NewTransport(cam.username, cam.password)
+	transport.HTTPClient = &http.Client{}
+
+	address := fmt.Sprintf("%s/cgi-bin/eventManager.cgi?action=attach&codes=[%s]&heartbeat=%d", cam.address, dahuaEventCodes(eventFilters), dahuaEventStreamHeartbeat)
+	streamCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(streamCtx, "GET", address, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	watchdog := time.AfterFunc(dahuaEventStreamIdleTimeout, cancel)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		watchdog.Stop()
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		watchdog.Stop()
+		cancel()
+		return nil, fmt.Errorf("camera event stream returned error code %s", resp.Status)
+	}
+	boundary := "myboundary"
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		boundary = params["boundary"]
+	}
+	log.Info("Subscribed to Dahua camera event stream. Camera address : ", cam.address)
+
+	messages := make(chan CameraEvent, 10)
+	go cam.readEventStream(resp.Body, boundary, eventFilters, messages, watchdog, cancel)
+	return messages, nil
 }
 
-func (cam *DahuaCameraDriver) Ping(address string) bool {
-	return true
+func (cam *DahuaCameraDriver) readEventStream(body io.ReadCloser, boundary string, eventFilters []EventFilter, messages chan CameraEvent, watchdog *time.Timer, cancel context.CancelFunc) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Info("Recovered from panic:", r)
+		}
+		watchdog.Stop()
+		cancel()
+		body.Close()
+		close(messages)
+		log.Info("Disconnected from Dahua camera event stream.")
+	}()
+	source := "cam:dahua:" + cam.address
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			log.Debug("Dahua event stream has been terminated : ", err)
+			return
+		}
+		watchdog.Reset(dahuaEventStreamIdleTimeout)
+		rawData, err := io.ReadAll(part)
+		if err != nil {
+			log.Debug("Failed to read Dahua event stream part : ", err)
+			return
+		}
+		rawData = []byte(strings.TrimSpace(string(rawData)))
+		if len(rawData) == 0 || strings.EqualFold(string(rawData), "heartbeat") {
+			continue
+		}
+		event, err := parseDahuaEvent(string(rawData), source)
+		if err != nil {
+			log.Info("Error parsing Dahua event : ", err)
+			continue
+		}
+		if !MatchEventFilters(eventFilters, event.Topic, rawData) {
+			continue
+		}
+		select {
+		case messages <- event:
+		default:
+			log.Info("Channel is full, message not sent")
+		}
+	}
 }
 
-func (cam *DahuaCameraDriver) Commit(transactionId string) error {
-	return nil
+// dahuaEventCodes converts event filters into comma separated list of event codes. Topic filter VideoMotion/0 is mapped to code VideoMotion ,
+// All is returned if any filter doesn't have a topic or the topic is a glob pattern.
+func dahuaEventCodes(eventFilters []EventFilter) string {
+	var codes []string
+	isAdded := map[string]bool{}
+	for _, f := range eventFilters {
+		code, _, _ := strings.Cut(strings.TrimSpace(f.TopicFilter), "/")
+		if code == "" || strings.ContainsAny(code, "*?[") {
+			return "All"
+		}
+		if !isAdded[code] {
+			isAdded[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "All"
+	}
+	return strings.Join(codes, ",")
 }
 
-func (cam *DahuaCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, fmt.Errorf("DaHua camera does not support event streaming")
+// dahuaEventActions maps Dahua event actions onto event states
+var dahuaEventActions = map[string]string{"Start": EventStateActive, "Stop": EventStateInactive, "Pulse": "pulse"}
+
+// parseDahuaEvent converts event stream message into CameraEvent. The message has format
+// Code=VideoMotion;action=Start;index=0 , optionally followed by ;data={json} .
+// Event time is taken from UTC field of data if present.
+func parseDahuaEvent(message string, source string) (CameraEvent, error) {
+	rawData := []byte(message)
+	var data string
+	if i := strings.Index(message, ";data="); i >= 0 {
+		message, data = message[:i], message[i+len(";data="):]
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(message, ";") {
+		if key, value, isFound := strings.Cut(strings.TrimSpace(field), "="); isFound {
+			fields[key] = value
+		}
+	}
+	code := fields["Code"]
+	if code == "" {
+		return CameraEvent{}, fmt.Errorf("event code is missing")
+	}
+	topic := code
+	if fields["index"] != "" {
+		topic = code + "/" + fields["index"]
+	}
+	state, isKnown := dahuaEventActions[fields["action"]]
+	if !isKnown {
+		state = strings.ToLower(fields["action"])
+	}
+	timestamp := time.Now().UnixMilli()
+	if data != "" {
+		var eventData struct {
+			UTC float64 `json:"UTC"`
+		}
+		if err := json.Unmarshal([]byte(data), &eventData); err == nil && eventData.UTC > 0 {
+			timestamp = int64(eventData.UTC * 1000)
+		}
+	}
+	return CameraEvent{
+		CoreType:  "notification",
+		Type:      code,
+		Topic:     topic,
+		Source:    source,
+		Timestamp: timestamp,
+		RawData:   rawData,
+		State:     state,
+	}, nil
 }
 
-func (cam *DahuaCameraDriver) Close() {
+func (cam *DahuaCameraDriver) Ping(address string) bool {
+	return true
 }
 
-func (cam *DahuaCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
+func (cam *DahuaCameraDriver) Close() {
 }
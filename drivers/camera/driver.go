@@ -1,19 +1,99 @@
 package camera
 
+import (
+	"context"
+	"path"
+	"strings"
+)
+
 type Image struct {
 	Body          []byte
 	Format        string
 	TransactionId string
 	ExternalId    string
+	Metadata      map[string]string // driver specific metadata added to the file metadata
+	Attachments   []ImageAttachment // companion files captured together with the image
+}
+
+// ImageAttachment is a companion file captured together with the image , for example radiometric data of thermal image.
+// Attachments are uploaded as separate files next to the image.
+type ImageAttachment struct {
+	Name     string // name suffix , for example radiometric.csv
+	Body     []byte
+	Format   string // MIME type
+	Metadata map[string]string
 }
 
 type CameraEvent struct {
-	CoreType  string
-	Type      string
-	Topic     string
-	Source    string
-	Timestamp int64
-	RawData   []byte
+	CoreType   string
+	Type       string
+	Topic      string
+	Source     string
+	Timestamp  int64
+	RawData    []byte
+	State      string       // event state reported by the camera , for example active or inactive. Empty if the camera doesn't report states
+	Message    EventMessage // structured message fields , empty if the camera doesn't provide them
+	CameraID   uint64       // set by integration before the event is published to event bus
+	CameraName string       // set by integration before the event is published to event bus
+}
+
+// EventMessage contains structured fields of event message , for example Axis or ONVIF source , key and data items.
+type EventMessage struct {
+	Source map[string]string
+	Key    map[string]string
+	Data   map[string]string
+}
+
+// Event states
+const (
+	EventStateActive   = "active"
+	EventStateInactive = "inactive"
+)
+
+// eventStateFields are data fields that carry state of stateful events , in order of precedence
+var eventStateFields = []string{"active", "state", "LogicalState", "IsMotion", "IsTamper", "IsInside", "triggered"}
+
+// EventStateFromData returns state of stateful event based on message data , empty string is returned if data doesn't contain state.
+func EventStateFromData(data map[string]string) string {
+	for _, field := range eventStateFields {
+		if value, ok := data[field]; ok {
+			return EventStateFromValue(value)
+		}
+	}
+	return ""
+}
+
+// EventStateFromValue converts state value (1/0 , true/false , active/inactive , start/stop) to EventStateActive or EventStateInactive.
+func EventStateFromValue(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "active", "start", "on":
+		return EventStateActive
+	default:
+		return EventStateInactive
+	}
+}
+
+// Measurement is a structured reading of camera measurement function , for example spot temperature of thermal camera.
+type Measurement struct {
+	Type   string             // measurement function type , for example spot , box , delta
+	ID     int                // measurement function ID
+	Name   string             // name of measurement function configured in the camera , if available
+	Unit   string             // unit of values , if reported by the camera
+	Values map[string]float64 // named values , for example value for spot or min , max , avg for box
+}
+
+// Alarm is a state of camera alarm.
+type Alarm struct {
+	ID     int
+	Name   string
+	Active bool
+}
+
+// Measurements is a result of single measurements extraction.
+type Measurements struct {
+	Timestamp    int64 // unix timestamp in milliseconds
+	Measurements []Measurement
+	Alarms       []Alarm
 }
 
 type EventFilter struct {
@@ -21,6 +101,30 @@ type EventFilter struct {
 	ContentFilter string
 }
 
+// MatchTopic returns true if topic matches TopicFilter. Empty filter matches all topics. The filter is either exact topic ,
+// topic prefix (filter VMD matches VMD/1) or glob pattern where * matches any characters except / (for example */1).
+func (f EventFilter) MatchTopic(topic string) bool {
+	if f.TopicFilter == "" || f.TopicFilter == topic || strings.HasPrefix(topic, strings.TrimSuffix(f.TopicFilter, "/")+"/") {
+		return true
+	}
+	isMatched, err := path.Match(f.TopicFilter, topic)
+	return err == nil && isMatched
+}
+
+// MatchEventFilters returns true if event matches at least one filter. Events match if the list of filters is empty.
+// ContentFilter is matched as a substring of the raw event data , drivers with native content filtering (for example Axis) apply filters on the camera instead.
+func MatchEventFilters(filters []EventFilter, topic string, rawData []byte) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if filter.MatchTopic(topic) && (filter.ContentFilter == "" || strings.Contains(string(rawData), filter.ContentFilter)) {
+			return true
+		}
+	}
+	return false
+}
+
 type CameraManifest struct {
 	Make                         string
 	Model                        string
@@ -37,13 +141,50 @@ type CameraCapabilitiesManifest struct {
 
 type DriverConstructor func() Driver
 
+// Driver is the core contract every camera driver must implement. Optional features are exposed through
+// capability interfaces below (EventStreamer, MetadataExtractor, MeasurementsExtractor, PtzController, Committer, ManifestProvider) and must be detected using type assertions.
 type Driver interface {
 	Configure(address, username, password string) error
-	ExtractImage() (*Image, error)
-	ExtractMetadata() ([]byte, error)
+	ExtractImage(ctx context.Context) (*Image, error)
 	Ping(address string) bool
+	Close()
+}
+
+// EventStreamer is implemented by drivers that can stream camera events. The channel must be closed when ctx is cancelled
+// or the stream is lost.
+type EventStreamer interface {
+	SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (chan CameraEvent, error)
+}
+
+// MetadataExtractor is implemented by drivers that can extract camera metadata , for example thermal measurements.
+type MetadataExtractor interface {
+	ExtractMetadata(ctx context.Context) ([]byte, error)
+}
+
+// MeasurementsExtractor is implemented by drivers that provide structured sensor readings and alarm states , for example thermal cameras.
+type MeasurementsExtractor interface {
+	ExtractMeasurements(ctx context.Context) (*Measurements, error)
+}
+
+// PtzController is implemented by drivers that can move PTZ cameras to presets. Preset is identified by name or number ,
+// depending on the camera API.
+type PtzController interface {
+	GotoPreset(ctx context.Context, preset string) error
+}
+
+// Committer is implemented by drivers that must be notified once the image has been successfully delivered , for example to remove source file.
+// Rollback is called if the image hasn't been delivered , the driver should return the image in one of the next extractions.
+type Committer interface {
 	Commit(transactionId string) error
-	SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error)
+	Rollback(transactionId string)
+}
+
+// ManifestProvider is implemented by drivers that can export camera capabilities manifests (services , events , etc.)
+type ManifestProvider interface {
 	GetCameraCapabilitiesManifest(componentName string) ([]CameraCapabilitiesManifest, error)
-	Close()
+}
+
+// OptionsConfigurer is implemented by drivers that accept model specific options (CameraConfig.DriverOptions) on top of address and credentials.
+type OptionsConfigurer interface {
+	ConfigureOptions(options map[string]string) error
 }
@@ -1,32 +1,72 @@
 package camera
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	FlirMeasurementSpot  = "spot"
+	FlirMeasurementBox   = "box"
+	FlirMeasurementDelta = "delta"
+
+	FlirRadiometricNone  = "none"  // radiometric data isn't captured
+	FlirRadiometricRjpeg = "rjpeg" // radiometric JPEG as provided by the camera
+	FlirRadiometricCsv   = "csv"   // temperature matrix in degrees Celsius
+	FlirRadiometricTiff  = "tiff"  // 16 bit TIFF , temperatures in centikelvin
+
+	flirAlarmTriggeredResource = ".image.sysimg.alarms.measfunc.%d.trigged"
+	flirRadiometricImagePath   = "/api/image/current?imgformat=JPEG"
+)
+
 type FlirAx8CameraDriver struct {
 	httpClient http.Client
 	address    string
 	username   string
 	password   string
+	spots      []int // IDs of spot measurement functions
+	boxes      []int // IDs of box measurement functions
+	deltas     []int // IDs of delta measurement functions
+	alarms     []int // IDs of measurement function alarms
+
+	radiometric     string // format of radiometric companion file
+	radiometricPath string // API path of radiometric JPEG
 }
 
 // docs : https://flir.custhelp.com/app/answers/detail/a_id/3602/~/getting-started-using-rest-api-with-automation-cameras
 
 // http://10.28.0.60/snapshot.jpg
 
+func init() {
+	Register("flir_ax8", NewFlirAx8CameraDriver, DriverInfo{
+		Description:    "FLIR AX8 thermal cameras",
+		RequiredFields: []string{"Address"},
+		Options: []DriverOptionInfo{
+			{Name: "spots", Description: "comma separated IDs of spot measurements", Default: "1"},
+			{Name: "boxes", Description: "comma separated IDs of box measurements", Default: ""},
+			{Name: "deltas", Description: "comma separated IDs of delta measurements", Default: ""},
+			{Name: "alarms", Description: "comma separated IDs of measurement alarms", Default: ""},
+			{Name: "radiometric", Description: "radiometric companion file format , none , rjpeg , csv or tiff", Default: FlirRadiometricNone},
+			{Name: "radiometricPath", Description: "API path of radiometric JPEG", Default: flirRadiometricImagePath},
+		},
+	})
+}
+
 func NewFlirAx8CameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
 	}
-	return &FlirAx8CameraDriver{httpClient: httpClient}
+	return &FlirAx8CameraDriver{httpClient: httpClient, spots: []int{1}, radiometric: FlirRadiometricNone, radiometricPath: flirRadiometricImagePath}
 }
 
 func (cam *FlirAx8CameraDriver) Configure(address, username, password string) error {
@@ -36,15 +76,60 @@ func (cam *FlirAx8CameraDriver) Configure(address, username, password string) er
 	return nil
 }
 
-func (cam *FlirAx8CameraDriver) ExtractImage() (*Image, error) {
+func (cam *FlirAx8CameraDriver) ConfigureOptions(options map[string]string) error {
+	for name, ids := range map[string]*[]int{"spots": &cam.spots, "boxes": &cam.boxes, "deltas": &cam.deltas, "alarms": &cam.alarms} {
+		value, ok := options[name]
+		if !ok {
+			continue
+		}
+		parsedIds, err := parseIdList(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %s : %w", name, value, err)
+		}
+		*ids = parsedIds
+	}
+	if radiometric, ok := options["radiometric"]; ok {
+		switch radiometric {
+		case FlirRadiometricNone, FlirRadiometricRjpeg, FlirRadiometricCsv, FlirRadiometricTiff:
+			cam.radiometric = radiometric
+		default:
+			return fmt.Errorf("unsupported radiometric value %s , supported values : none, rjpeg, csv, tiff", radiometric)
+		}
+	}
+	if radiometricPath, ok := options["radiometricPath"]; ok {
+		cam.radiometricPath = radiometricPath
+	}
+	return nil
+}
+
+// parseIdList parses comma separated list of integer IDs , empty value returns empty list.
+func parseIdList(value string) ([]int, error) {
+	var ids []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (cam *FlirAx8CameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
 	address := cam.address + "/snapshot.jpg"
 
-	resp, err := cam.httpClient.Get(address)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cam.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
@@ -65,19 +150,145 @@ func (cam *FlirAx8CameraDriver) ExtractImage() (*Image, error) {
 
 	img := Image{Body: body, Format: "image/jpeg"}
 
+	if cam.radiometric != FlirRadiometricNone {
+		// snapshot is uploaded even if radiometric data can't be captured
+		if err := cam.attachRadiometricData(ctx, &img); err != nil {
+			log.Errorf("Failed to capture radiometric data from FLIR camera %s . Err : %s", cam.address, err.Error())
+		}
+	}
+
 	return &img, nil
 }
 
-func (cam *FlirAx8CameraDriver) ExtractMetadata() ([]byte, error) {
-	address := cam.address + "/res.php"
+// attachRadiometricData downloads radiometric JPEG and attaches it to the image in configured format.
+// Object parameters (emissivity , distance , reflected temperature , etc.) are added to metadata of both files.
+func (cam *FlirAx8CameraDriver) attachRadiometricData(ctx context.Context, img *Image) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", cam.address+cam.radiometricPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cam.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("camera api returned error code %s", resp.Status)
+	}
+	rjpeg, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	thermal, err := ParseFlirRadiometricJpeg(rjpeg)
+	if err != nil {
+		return err
+	}
+	metadata := thermal.Params.Metadata()
+	metadata["width"] = strconv.Itoa(thermal.Width)
+	metadata["height"] = strconv.Itoa(thermal.Height)
+	attachment := ImageAttachment{Metadata: metadata}
+	switch cam.radiometric {
+	case FlirRadiometricRjpeg:
+		attachment.Name = "radiometric.jpg"
+		attachment.Body = rjpeg
+		attachment.Format = "image/jpeg"
+	case FlirRadiometricCsv:
+		attachment.Name = "radiometric.csv"
+		attachment.Body = EncodeTemperaturesCsv(thermal.Width, thermal.Height, thermal.Temperatures())
+		attachment.Format = "text/csv"
+		metadata["unit"] = "degC"
+	case FlirRadiometricTiff:
+		attachment.Name = "radiometric.tiff"
+		attachment.Body = EncodeTemperaturesTiff(thermal.Width, thermal.Height, thermal.Temperatures())
+		attachment.Format = "image/tiff"
+		metadata["unit"] = "cK"
+	}
+	img.Metadata = thermal.Params.Metadata()
+	img.Attachments = append(img.Attachments, attachment)
+	return nil
+}
+
+// ExtractMetadata returns JSON encoded measurements (see ExtractMeasurements).
+func (cam *FlirAx8CameraDriver) ExtractMetadata(ctx context.Context) ([]byte, error) {
+	measurements, err := cam.ExtractMeasurements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(measurements)
+}
+
+// ExtractMeasurements reads configured spot , box and delta measurement functions and alarm states.
+// The operation fails only if none of measurements or alarms can be read.
+func (cam *FlirAx8CameraDriver) ExtractMeasurements(ctx context.Context) (*Measurements, error) {
+	result := &Measurements{Timestamp: time.Now().UnixMilli()}
+	var errs []error
+	for _, measurementFunc := range []struct {
+		funcType string
+		ids      []int
+	}{{FlirMeasurementSpot, cam.spots}, {FlirMeasurementBox, cam.boxes}, {FlirMeasurementDelta, cam.deltas}} {
+		for _, id := range measurementFunc.ids {
+			measurement, err := cam.readMeasurement(ctx, measurementFunc.funcType, id)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %d : %w", measurementFunc.funcType, id, err))
+				continue
+			}
+			result.Measurements = append(result.Measurements, *measurement)
+		}
+	}
+	for _, id := range cam.alarms {
+		alarm, err := cam.readAlarm(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alarm %d : %w", id, err))
+			continue
+		}
+		result.Alarms = append(result.Alarms, *alarm)
+	}
+	if len(errs) > 0 {
+		if len(result.Measurements) == 0 && len(result.Alarms) == 0 {
+			return nil, errors.Join(errs...)
+		}
+		log.Warnf("Some FLIR AX8 measurements can't be read. Err : %s", errors.Join(errs...).Error())
+	}
+	return result, nil
+}
 
-	data := url.Values{
+// readMeasurement reads single measurement function. Numeric fields of the response are returned as measurement values.
+func (cam *FlirAx8CameraDriver) readMeasurement(ctx context.Context, funcType string, id int) (*Measurement, error) {
+	body, err := cam.callResApi(ctx, url.Values{
 		"action": {"measurement"},
-		"type":   {"spot"},
-		"id":     {"1"},
+		"type":   {funcType},
+		"id":     {strconv.Itoa(id)},
+	})
+	if err != nil {
+		return nil, err
 	}
+	return parseFlirMeasurement(funcType, id, body)
+}
 
-	resp, err := http.PostForm(address, data)
+// readAlarm reads triggered state of measurement function alarm.
+func (cam *FlirAx8CameraDriver) readAlarm(ctx context.Context, id int) (*Alarm, error) {
+	body, err := cam.callResApi(ctx, url.Values{
+		"action":   {"get"},
+		"resource": {fmt.Sprintf(flirAlarmTriggeredResource, id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	value := strings.Trim(strings.TrimSpace(string(body)), `"`)
+	active, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected alarm state %q", value)
+	}
+	return &Alarm{ID: id, Name: fmt.Sprintf("alarm %d", id), Active: active}, nil
+}
+
+func (cam *FlirAx8CameraDriver) callResApi(ctx context.Context, data url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", cam.address+"/res.php", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := cam.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -87,30 +298,55 @@ func (cam *FlirAx8CameraDriver) ExtractMetadata() ([]byte, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("camera metadata api returned error code %s", resp.Status)
 	}
-	body, err := io.ReadAll(resp.Body)
+	return io.ReadAll(resp.Body)
+}
 
-	if err != nil {
-		return nil, err
+// parseFlirMeasurement parses response of res.php measurement action. The response is either JSON object
+// (numeric and numeric string fields become values , name and unit fields are used as measurement name and unit) or a single number.
+func parseFlirMeasurement(funcType string, id int, body []byte) (*Measurement, error) {
+	measurement := &Measurement{Type: funcType, ID: id, Values: make(map[string]float64)}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		value, err := parseFlirNumber(strings.TrimSpace(string(body)))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected measurement response %q", string(body))
+		}
+		measurement.Values["value"] = value
+		return measurement, nil
+	}
+	for key, rawValue := range fields {
+		switch key {
+		case "id", "type":
+			continue
+		case "name", "label":
+			measurement.Name = fmt.Sprint(rawValue)
+			continue
+		case "unit":
+			measurement.Unit = fmt.Sprint(rawValue)
+			continue
+		}
+		switch v := rawValue.(type) {
+		case float64:
+			measurement.Values[key] = v
+		case string:
+			if value, err := parseFlirNumber(v); err == nil {
+				measurement.Values[key] = value
+			}
+		}
 	}
+	if len(measurement.Values) == 0 {
+		return nil, fmt.Errorf("measurement response doesn't contain values")
+	}
+	return measurement, nil
+}
 
-	return body, nil
+func parseFlirNumber(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Trim(value, `"`), 64)
 }
 
 func (cam *FlirAx8CameraDriver) Ping(address string) bool {
 	return true
 }
 
-func (cam *FlirAx8CameraDriver) Commit(transactionId string) error {
-	return nil
-}
-
-func (cam *FlirAx8CameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, fmt.Errorf("FlirAx8 camera does not support event streaming")
-}
-
 func (cam *FlirAx8CameraDriver) Close() {
 }
-
-func (cam *FlirAx8CameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
-}
@@ -0,0 +1,325 @@
+package camera
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FLIR radiometric JPEG parsing. Radiometric data is stored in FFF container split into JPEG APP1 segments with "FLIR" header.
+// The container has a directory of records , RawData record holds raw 16 bit sensor values and CameraInfo record holds
+// object parameters and Planck constants required to convert raw values into temperatures.
+// Record layout follows FLIR FFF format as documented by ExifTool (FLIR tags).
+
+const (
+	fffRecordRawData    = 1
+	fffRecordCameraInfo = 32
+)
+
+// FlirThermalParams are object parameters and calibration constants of radiometric image.
+type FlirThermalParams struct {
+	Emissivity             float64
+	ObjectDistance         float64 // m
+	ReflectedTemperature   float64 // K
+	AtmosphericTemperature float64 // K
+	IRWindowTemperature    float64 // K
+	IRWindowTransmission   float64
+	RelativeHumidity       float64 // 0..1
+	PlanckR1               float64
+	PlanckR2               float64
+	PlanckB                float64
+	PlanckF                float64
+	PlanckO                float64
+	AtmTransAlpha1         float64
+	AtmTransAlpha2         float64
+	AtmTransBeta1          float64
+	AtmTransBeta2          float64
+	AtmTransX              float64
+}
+
+// FlirThermalImage is parsed radiometric image.
+type FlirThermalImage struct {
+	Width  int
+	Height int
+	Raw    []uint16 // raw sensor values , row by row
+	Params FlirThermalParams
+}
+
+// ParseFlirRadiometricJpeg extracts raw thermal data and camera parameters from FLIR radiometric JPEG.
+func ParseFlirRadiometricJpeg(jpeg []byte) (*FlirThermalImage, error) {
+	fff, err := extractFlirFff(jpeg)
+	if err != nil {
+		return nil, err
+	}
+	if len(fff) < 64 || string(fff[0:3]) != "FFF" {
+		return nil, fmt.Errorf("invalid FLIR FFF header")
+	}
+	// header byte order is detected using version field , it's 100 in correct byte order
+	var order binary.ByteOrder = binary.BigEndian
+	if version := order.Uint32(fff[20:24]); version < 100 || version >= 200 {
+		order = binary.LittleEndian
+	}
+	dirOffset := int(order.Uint32(fff[24:28]))
+	dirCount := int(order.Uint32(fff[28:32]))
+	thermal := &FlirThermalImage{}
+	var isRawFound, isCameraInfoFound bool
+	for i := 0; i < dirCount; i++ {
+		entry := dirOffset + i*32
+		if entry+32 > len(fff) {
+			return nil, fmt.Errorf("FLIR record directory is truncated")
+		}
+		recordType := order.Uint16(fff[entry : entry+2])
+		offset := int(order.Uint32(fff[entry+12 : entry+16]))
+		length := int(order.Uint32(fff[entry+16 : entry+20]))
+		if offset < 0 || length < 0 || offset+length > len(fff) {
+			continue
+		}
+		record := fff[offset : offset+length]
+		switch recordType {
+		case fffRecordRawData:
+			if err := parseFffRawData(record, thermal); err != nil {
+				return nil, err
+			}
+			isRawFound = true
+		case fffRecordCameraInfo:
+			if err := parseFffCameraInfo(record, &thermal.Params); err != nil {
+				return nil, err
+			}
+			isCameraInfoFound = true
+		}
+	}
+	if !isRawFound || !isCameraInfoFound {
+		return nil, fmt.Errorf("radiometric data not found , the image is not radiometric JPEG")
+	}
+	return thermal, nil
+}
+
+// extractFlirFff concatenates FLIR APP1 segments of JPEG.
+func extractFlirFff(jpeg []byte) ([]byte, error) {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG image")
+	}
+	var fff []byte
+	pos := 2
+	for pos+4 <= len(jpeg) {
+		if jpeg[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG segment marker at %d", pos)
+		}
+		marker := jpeg[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan , end of image
+			break
+		}
+		segmentLength := int(binary.BigEndian.Uint16(jpeg[pos+2 : pos+4]))
+		if segmentLength < 2 || pos+2+segmentLength > len(jpeg) {
+			return nil, fmt.Errorf("JPEG segment is truncated")
+		}
+		segment := jpeg[pos+4 : pos+2+segmentLength]
+		if marker == 0xE1 && len(segment) > 8 && bytes.HasPrefix(segment, []byte("FLIR\x00")) {
+			fff = append(fff, segment[8:]...)
+		}
+		pos += 2 + segmentLength
+	}
+	if len(fff) == 0 {
+		return nil, fmt.Errorf("FLIR segments not found , the image is not radiometric JPEG")
+	}
+	return fff, nil
+}
+
+// recordByteOrder detects byte order of record , the first field of RawData and CameraInfo records is 2.
+func recordByteOrder(record []byte) binary.ByteOrder {
+	if binary.LittleEndian.Uint16(record[0:2]) == 2 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+func parseFffRawData(record []byte, thermal *FlirThermalImage) error {
+	if len(record) < 32 {
+		return fmt.Errorf("FLIR raw data record is truncated")
+	}
+	order := recordByteOrder(record)
+	width := int(order.Uint16(record[2:4]))
+	height := int(order.Uint16(record[4:6]))
+	data := record[32:]
+	thermal.Width = width
+	thermal.Height = height
+	thermal.Raw = make([]uint16, width*height)
+	if bytes.HasPrefix(data, []byte("\x89PNG")) {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode FLIR raw PNG : %w", err)
+		}
+		gray, ok := img.(*image.Gray16)
+		if !ok {
+			return fmt.Errorf("unsupported FLIR raw PNG format %T", img)
+		}
+		if gray.Rect.Dx() != width || gray.Rect.Dy() != height {
+			return fmt.Errorf("FLIR raw PNG size doesn't match raw data size")
+		}
+		// PNG stores FLIR raw values with swapped bytes
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := gray.Gray16At(x+gray.Rect.Min.X, y+gray.Rect.Min.Y).Y
+				thermal.Raw[y*width+x] = v>>8 | v<<8
+			}
+		}
+		return nil
+	}
+	if len(data) < width*height*2 {
+		return fmt.Errorf("FLIR raw data is truncated")
+	}
+	for i := range thermal.Raw {
+		thermal.Raw[i] = order.Uint16(data[i*2 : i*2+2])
+	}
+	return nil
+}
+
+func parseFffCameraInfo(record []byte, params *FlirThermalParams) error {
+	if len(record) < 0x310 {
+		return fmt.Errorf("FLIR camera info record is truncated")
+	}
+	order := recordByteOrder(record)
+	float := func(offset int) float64 {
+		return float64(math.Float32frombits(order.Uint32(record[offset : offset+4])))
+	}
+	params.Emissivity = float(0x20)
+	params.ObjectDistance = float(0x24)
+	params.ReflectedTemperature = float(0x28)
+	params.AtmosphericTemperature = float(0x2c)
+	params.IRWindowTemperature = float(0x30)
+	params.IRWindowTransmission = float(0x34)
+	params.RelativeHumidity = float(0x3c)
+	if params.RelativeHumidity > 2 {
+		params.RelativeHumidity /= 100
+	}
+	params.PlanckR1 = float(0x58)
+	params.PlanckB = float(0x5c)
+	params.PlanckF = float(0x60)
+	params.AtmTransAlpha1 = float(0x70)
+	params.AtmTransAlpha2 = float(0x74)
+	params.AtmTransBeta1 = float(0x78)
+	params.AtmTransBeta2 = float(0x7c)
+	params.AtmTransX = float(0x80)
+	params.PlanckO = float64(int32(order.Uint32(record[0x308:0x30c])))
+	params.PlanckR2 = float(0x30c)
+	if params.Emissivity <= 0 || params.PlanckR2 == 0 || params.IRWindowTransmission <= 0 {
+		return fmt.Errorf("invalid FLIR calibration parameters")
+	}
+	return nil
+}
+
+// Temperatures converts raw values into object temperatures in degrees Celsius using Planck constants ,
+// emissivity , reflected temperature and atmospheric transmission (standard FLIR conversion).
+func (thermal *FlirThermalImage) Temperatures() []float64 {
+	p := thermal.Params
+	atmC := p.AtmosphericTemperature - 273.15
+	h2o := p.RelativeHumidity * math.Exp(1.5587+0.06939*atmC-0.00027816*atmC*atmC+0.00000068455*atmC*atmC*atmC)
+	transmission := func(distance float64) float64 {
+		d := math.Sqrt(distance)
+		return p.AtmTransX*math.Exp(-d*(p.AtmTransAlpha1+p.AtmTransBeta1*math.Sqrt(h2o))) +
+			(1-p.AtmTransX)*math.Exp(-d*(p.AtmTransAlpha2+p.AtmTransBeta2*math.Sqrt(h2o)))
+	}
+	// IR window is assumed to be in the middle between the camera and the object
+	tau1 := transmission(p.ObjectDistance / 2)
+	tau2 := tau1
+	radiance := func(kelvin float64) float64 {
+		return p.PlanckR1/(p.PlanckR2*(math.Exp(p.PlanckB/kelvin)-p.PlanckF)) - p.PlanckO
+	}
+	e := p.Emissivity
+	irt := p.IRWindowTransmission
+	rawRefl := radiance(p.ReflectedTemperature)
+	rawAtm := radiance(p.AtmosphericTemperature)
+	rawWind := radiance(p.IRWindowTemperature)
+	offset := (1-e)/e*rawRefl +
+		(1-tau1)/e/tau1*rawAtm +
+		(1-irt)/e/tau1/irt*rawWind +
+		(1-tau2)/e/tau1/irt/tau2*rawAtm
+	temperatures := make([]float64, len(thermal.Raw))
+	for i, raw := range thermal.Raw {
+		rawObj := float64(raw)/e/tau1/irt/tau2 - offset
+		temperatures[i] = p.PlanckB/math.Log(p.PlanckR1/(p.PlanckR2*(rawObj+p.PlanckO))+p.PlanckF) - 273.15
+	}
+	return temperatures
+}
+
+// Metadata returns object parameters as file metadata. Temperatures are in degrees Celsius.
+func (params FlirThermalParams) Metadata() map[string]string {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 32)
+	}
+	formatTemperature := func(kelvin float64) string {
+		return strconv.FormatFloat(kelvin-273.15, 'f', 2, 64)
+	}
+	return map[string]string{
+		"emissivity":             format(params.Emissivity),
+		"objectDistance":         format(params.ObjectDistance),
+		"reflectedTemperature":   formatTemperature(params.ReflectedTemperature),
+		"atmosphericTemperature": formatTemperature(params.AtmosphericTemperature),
+		"relativeHumidity":       format(params.RelativeHumidity),
+	}
+}
+
+// EncodeTemperaturesCsv encodes temperature matrix as CSV , one image row per line , values in degrees Celsius with 2 decimals.
+func EncodeTemperaturesCsv(width, height int, temperatures []float64) []byte {
+	var buf bytes.Buffer
+	values := make([]string, width)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			values[x] = strconv.FormatFloat(temperatures[y*width+x], 'f', 2, 64)
+		}
+		buf.WriteString(strings.Join(values, ","))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// EncodeTemperaturesTiff encodes temperature matrix as uncompressed 16 bit grayscale TIFF.
+// Pixel value is temperature in centikelvin , temperature in degrees Celsius = value / 100 - 273.15 .
+func EncodeTemperaturesTiff(width, height int, temperatures []float64) []byte {
+	const headerSize = 8
+	const entryCount = 9
+	ifdSize := 2 + entryCount*12 + 4
+	dataOffset := headerSize + ifdSize
+	dataSize := width * height * 2
+	buf := make([]byte, dataOffset+dataSize)
+	order := binary.LittleEndian
+	copy(buf[0:4], []byte{'I', 'I', 42, 0})
+	order.PutUint32(buf[4:8], headerSize)
+	pos := headerSize
+	order.PutUint16(buf[pos:], entryCount)
+	pos += 2
+	// tags must be sorted in ascending order
+	for _, entry := range [][3]uint32{
+		{256, 4, uint32(width)},      // ImageWidth , LONG
+		{257, 4, uint32(height)},     // ImageLength , LONG
+		{258, 3, 16},                 // BitsPerSample , SHORT
+		{259, 3, 1},                  // Compression , none
+		{262, 3, 1},                  // PhotometricInterpretation , BlackIsZero
+		{273, 4, uint32(dataOffset)}, // StripOffsets , LONG
+		{277, 3, 1},                  // SamplesPerPixel
+		{278, 4, uint32(height)},     // RowsPerStrip , LONG
+		{279, 4, uint32(dataSize)},   // StripByteCounts , LONG
+	} {
+		order.PutUint16(buf[pos:], uint16(entry[0]))
+		order.PutUint16(buf[pos+2:], uint16(entry[1]))
+		order.PutUint32(buf[pos+4:], 1)
+		if entry[1] == 3 {
+			order.PutUint16(buf[pos+8:], uint16(entry[2]))
+		} else {
+			order.PutUint32(buf[pos+8:], entry[2])
+		}
+		pos += 12
+	}
+	order.PutUint32(buf[pos:], 0) // no next IFD
+	for i, t := range temperatures {
+		v := math.Round((t + 273.15) * 100)
+		v = math.Max(0, math.Min(v, math.MaxUint16))
+		order.PutUint16(buf[dataOffset+i*2:], uint16(v))
+	}
+	return buf
+}
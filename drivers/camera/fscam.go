@@ -1,12 +1,33 @@
 package camera
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
+	"mime"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FsCamPostCommitKeep    = "keep"
+	FsCamPostCommitArchive = "archive"
+	FsCamPostCommitDelete  = "delete"
+
+	FsCamCompleteOnStable = "stable" // file is complete when its size and modification time haven't changed for stableSeconds
+	FsCamCompleteOnRename = "rename" // file is complete as soon as it appears in the directory (written elsewhere and renamed into place)
+
+	fsCamMaxWaitTime = 30 * time.Second // max time ExtractImage waits for new file in watch mode
 )
 
 type FileSystemCameraDriver struct {
@@ -16,10 +37,44 @@ type FileSystemCameraDriver struct {
 	address    string
 	username   string
 	password   string
+	// options
+	isWatchMode  bool
+	include      []string
+	stableTime   time.Duration
+	completeOn   string
+	postCommit   string
+	archiveDir   string
+	watcher      *fsnotify.Watcher
+	pendingFiles map[string]pendingFile // files that are being written
+	readyFiles   []string               // complete files waiting for extraction
+	inFlight     map[string]bool        // extracted files waiting for Commit or Rollback
+	readyNotify  chan struct{}
+	watchMux     sync.Mutex
+}
+
+type pendingFile struct {
+	size     int64
+	modTime  time.Time
+	stableAt time.Time // time when size and modification time were observed for the first time
+}
+
+func init() {
+	Register("fscam", NewFileSystemCameraDriver, DriverInfo{
+		Description:    "Virtual camera that reads images from local file or directory",
+		RequiredFields: []string{"Address"},
+		Options: []DriverOptionInfo{
+			{Name: "watch", Description: "event-driven mode , new files are picked up using filesystem notifications (true/false)", Default: "false"},
+			{Name: "include", Description: "comma separated file name glob patterns , for example *.jpg,*.png", Default: "all files"},
+			{Name: "completeOn", Description: "watch mode file completion detection , stable or rename", Default: FsCamCompleteOnStable},
+			{Name: "stableSeconds", Description: "file is complete if its size hasn't changed for this time", Default: "2"},
+			{Name: "postCommit", Description: "action after successful upload , keep , archive or delete", Default: FsCamPostCommitKeep},
+			{Name: "archiveDir", Description: "archive directory", Default: "<Address>/archive"},
+		},
+	})
 }
 
 func NewFileSystemCameraDriver() Driver {
-	return &FileSystemCameraDriver{cursorMux: sync.Mutex{}}
+	return &FileSystemCameraDriver{cursorMux: sync.Mutex{}, stableTime: 2 * time.Second, completeOn: FsCamCompleteOnStable, postCommit: FsCamPostCommitKeep}
 }
 
 func (cam *FileSystemCameraDriver) Configure(address, username, password string) error {
@@ -29,7 +84,59 @@ func (cam *FileSystemCameraDriver) Configure(address, username, password string)
 	return nil
 }
 
-func (cam *FileSystemCameraDriver) ExtractImage() (*Image, error) {
+func (cam *FileSystemCameraDriver) ConfigureOptions(options map[string]string) error {
+	if watch, ok := options["watch"]; ok {
+		v, err := strconv.ParseBool(watch)
+		if err != nil {
+			return fmt.Errorf("invalid watch value %s", watch)
+		}
+		cam.isWatchMode = v
+	}
+	if include, ok := options["include"]; ok {
+		cam.include = nil
+		for _, pattern := range strings.Split(include, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid include pattern %s", pattern)
+			}
+			cam.include = append(cam.include, pattern)
+		}
+	}
+	if completeOn, ok := options["completeOn"]; ok {
+		if completeOn != FsCamCompleteOnStable && completeOn != FsCamCompleteOnRename {
+			return fmt.Errorf("unsupported completeOn value %s , supported values : stable, rename", completeOn)
+		}
+		cam.completeOn = completeOn
+	}
+	if stableSeconds, ok := options["stableSeconds"]; ok {
+		v, err := strconv.ParseFloat(stableSeconds, 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid stableSeconds value %s", stableSeconds)
+		}
+		cam.stableTime = time.Duration(v * float64(time.Second))
+	}
+	if postCommit, ok := options["postCommit"]; ok {
+		if postCommit != FsCamPostCommitKeep && postCommit != FsCamPostCommitArchive && postCommit != FsCamPostCommitDelete {
+			return fmt.Errorf("unsupported postCommit value %s , supported values : keep, archive, delete", postCommit)
+		}
+		cam.postCommit = postCommit
+	}
+	if archiveDir, ok := options["archiveDir"]; ok {
+		cam.archiveDir = archiveDir
+	}
+	return nil
+}
+
+func (cam *FileSystemCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cam.isWatchMode {
+		return cam.extractImageFromWatchedDir(ctx)
+	}
 	return cam.extractImageFromFiles(cam.address, cam.username, cam.password)
 }
 
@@ -47,7 +154,9 @@ func (cam *FileSystemCameraDriver) extractImageFromFiles(address, username, pass
 		} else {
 			fullPath := path.Join(address, cam.dirContent[cam.fileCursor].Name())
 			img, err = cam.processFile(fullPath)
-			img.ExternalId = strconv.Itoa(cam.fileCursor)
+			if err == nil {
+				img.ExternalId = strconv.Itoa(cam.fileCursor)
+			}
 			cam.fileCursor++
 		}
 		cam.cursorMux.Unlock()
@@ -61,10 +170,16 @@ func (cam *FileSystemCameraDriver) extractImageFromFiles(address, username, pass
 		}
 		mode := file.Mode()
 		if mode.IsDir() {
-			cam.dirContent, err = os.ReadDir(address)
+			entries, err := os.ReadDir(address)
 			if err != nil {
 				return nil, err
 			}
+			cam.dirContent = nil
+			for _, entry := range entries {
+				if entry.Type().IsRegular() && cam.isFileNameMatched(entry.Name()) {
+					cam.dirContent = append(cam.dirContent, entry)
+				}
+			}
 			if len(cam.dirContent) == 0 {
 				cam.dirContent = nil
 				cam.fileCursor = 0
@@ -77,39 +192,251 @@ func (cam *FileSystemCameraDriver) extractImageFromFiles(address, username, pass
 	}
 }
 
-// processFile reads the file , reads binary content into memory and returns the image
+// extractImageFromWatchedDir returns the next complete file from the watched directory. If there are no complete files ,
+// it waits for new files up to 30 seconds and returns nil if nothing has arrived.
+func (cam *FileSystemCameraDriver) extractImageFromWatchedDir(ctx context.Context) (*Image, error) {
+	if err := cam.startWatcher(); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(fsCamMaxWaitTime)
+	defer timeout.Stop()
+	for {
+		cam.watchMux.Lock()
+		var filePath string
+		if len(cam.readyFiles) > 0 {
+			filePath = cam.readyFiles[0]
+			cam.readyFiles = cam.readyFiles[1:]
+			cam.inFlight[filePath] = true
+		}
+		cam.watchMux.Unlock()
+		if filePath != "" {
+			img, err := cam.processFile(filePath)
+			if err != nil {
+				cam.watchMux.Lock()
+				delete(cam.inFlight, filePath)
+				if !os.IsNotExist(err) {
+					// the file is checked again by the watcher , for example it can be locked by the writer
+					cam.pendingFiles[filePath] = pendingFile{}
+				}
+				cam.watchMux.Unlock()
+			}
+			if os.IsNotExist(err) {
+				// the file has been removed or renamed before it was read
+				continue
+			}
+			return img, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, nil
+		case <-cam.readyNotify:
+		}
+	}
+}
+
+// startWatcher starts watching the directory , existing files are queued as well. The operation is no-op if the watcher is already running.
+func (cam *FileSystemCameraDriver) startWatcher() error {
+	cam.watchMux.Lock()
+	defer cam.watchMux.Unlock()
+	if cam.watcher != nil {
+		return nil
+	}
+	info, err := os.Stat(cam.address)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("watch mode requires directory , %s is not a directory", cam.address)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(cam.address); err != nil {
+		watcher.Close()
+		return err
+	}
+	cam.watcher = watcher
+	cam.pendingFiles = make(map[string]pendingFile)
+	cam.inFlight = make(map[string]bool)
+	cam.readyNotify = make(chan struct{}, 1)
+	entries, err := os.ReadDir(cam.address)
+	if err != nil {
+		log.Warnf("Failed to read directory %s . Err : %s", cam.address, err.Error())
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && cam.isFileNameMatched(entry.Name()) {
+			cam.pendingFiles[filepath.Join(cam.address, entry.Name())] = pendingFile{}
+		}
+	}
+	log.Infof("Watching directory %s for new files", cam.address)
+	go cam.runWatcher(watcher)
+	return nil
+}
+
+// runWatcher processes filesystem notifications and periodically moves complete files into ready queue.
+func (cam *FileSystemCameraDriver) runWatcher(watcher *fsnotify.Watcher) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !cam.isFileNameMatched(filepath.Base(event.Name)) {
+				continue
+			}
+			cam.watchMux.Lock()
+			switch {
+			case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+				delete(cam.pendingFiles, event.Name)
+			case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+				cam.pendingFiles[event.Name] = pendingFile{}
+			}
+			cam.watchMux.Unlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("Directory watcher %s error : %s", cam.address, err.Error())
+		case <-ticker.C:
+			cam.checkPendingFiles()
+		}
+	}
+}
+
+// checkPendingFiles moves complete files from pending files into ready queue. Files are queued in modification time order.
+func (cam *FileSystemCameraDriver) checkPendingFiles() {
+	cam.watchMux.Lock()
+	defer cam.watchMux.Unlock()
+	now := time.Now()
+	var completeFiles []string
+	completeModTimes := make(map[string]time.Time)
+	for filePath, pending := range cam.pendingFiles {
+		if cam.inFlight[filePath] {
+			// the file is being delivered , it's returned to the queue by Rollback if delivery fails
+			delete(cam.pendingFiles, filePath)
+			continue
+		}
+		info, err := os.Stat(filePath)
+		if err != nil || !info.Mode().IsRegular() {
+			delete(cam.pendingFiles, filePath)
+			continue
+		}
+		isComplete := cam.completeOn == FsCamCompleteOnRename
+		if !isComplete {
+			if pending.stableAt.IsZero() || info.Size() != pending.size || !info.ModTime().Equal(pending.modTime) {
+				cam.pendingFiles[filePath] = pendingFile{size: info.Size(), modTime: info.ModTime(), stableAt: now}
+				continue
+			}
+			isComplete = now.Sub(pending.stableAt) >= cam.stableTime
+		}
+		if isComplete {
+			delete(cam.pendingFiles, filePath)
+			completeFiles = append(completeFiles, filePath)
+			completeModTimes[filePath] = info.ModTime()
+		}
+	}
+	if len(completeFiles) == 0 {
+		return
+	}
+	sort.Slice(completeFiles, func(i, j int) bool {
+		return completeModTimes[completeFiles[i]].Before(completeModTimes[completeFiles[j]])
+	})
+	cam.readyFiles = append(cam.readyFiles, completeFiles...)
+	select {
+	case cam.readyNotify <- struct{}{}:
+	default:
+	}
+}
+
+func (cam *FileSystemCameraDriver) isFileNameMatched(name string) bool {
+	if len(cam.include) == 0 {
+		return true
+	}
+	for _, pattern := range cam.include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// processFile reads the file , reads binary content into memory and returns the image. Mime type is detected from the content.
 func (cam *FileSystemCameraDriver) processFile(filePath string) (*Image, error) {
 	body, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	img := Image{Body: body, Format: "image/jpeg", TransactionId: filePath, ExternalId: "0"}
+	img := Image{Body: body, Format: detectMimeType(filePath, body), TransactionId: filePath, ExternalId: "0"}
 
 	return &img, nil
 }
 
-func (cam *FileSystemCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
+// detectMimeType detects mime type from the content , file extension is used if the content type can't be detected.
+func detectMimeType(filePath string, body []byte) string {
+	mimeType := http.DetectContentType(body)
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		if extMimeType := mime.TypeByExtension(filepath.Ext(filePath)); extMimeType != "" {
+			return extMimeType
+		}
+	}
+	return mimeType
 }
 
 func (cam *FileSystemCameraDriver) Ping(address string) bool {
 	return true
 }
 
-// Commit removes the file from the file system. TransactionId in this case is the file path.
+// Commit applies post commit action (keep , archive or delete) to the file. TransactionId in this case is the file path.
 func (cam *FileSystemCameraDriver) Commit(transactionId string) error {
-	os.Remove(transactionId)
+	cam.watchMux.Lock()
+	delete(cam.inFlight, transactionId)
+	cam.watchMux.Unlock()
+	switch cam.postCommit {
+	case FsCamPostCommitDelete:
+		return os.Remove(transactionId)
+	case FsCamPostCommitArchive:
+		archiveDir := cam.archiveDir
+		if archiveDir == "" {
+			archiveDir = filepath.Join(filepath.Dir(transactionId), "archive")
+			if info, err := os.Stat(cam.address); err == nil && info.IsDir() {
+				archiveDir = filepath.Join(cam.address, "archive")
+			}
+		}
+		if err := os.MkdirAll(archiveDir, 0755); err != nil {
+			return err
+		}
+		return os.Rename(transactionId, filepath.Join(archiveDir, filepath.Base(transactionId)))
+	}
 	return nil
 }
 
-func (cam *FileSystemCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, fmt.Errorf("file system camera driver does not support event streaming")
+// Rollback returns the file to the head of the ready queue in watch mode , so delivery is retried by the next extraction.
+// In cursor mode the file is extracted again when the directory is iterated next time.
+func (cam *FileSystemCameraDriver) Rollback(transactionId string) {
+	cam.watchMux.Lock()
+	defer cam.watchMux.Unlock()
+	if !cam.inFlight[transactionId] {
+		return
+	}
+	delete(cam.inFlight, transactionId)
+	cam.readyFiles = append([]string{transactionId}, cam.readyFiles...)
+	select {
+	case cam.readyNotify <- struct{}{}:
+	default:
+	}
 }
 
 func (cam *FileSystemCameraDriver) Close() {
-}
-
-func (cam *FileSystemCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
+	cam.watchMux.Lock()
+	defer cam.watchMux.Unlock()
+	if cam.watcher != nil {
+		cam.watcher.Close()
+		cam.watcher = nil
+	}
 }
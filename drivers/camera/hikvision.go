@@ -1,24 +1,36 @@
 package camera
 
 import (
+	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	edgedac "github.com/cognitedata/edge-extractor/internal/auth/digest"
 	log "github.com/sirupsen/logrus"
-	dac "github.com/xinsnake/go-http-digest-auth-client"
 )
 
 type HikvisionCameraDriver struct {
 	httpClient      http.Client
-	digestTransport *dac.DigestTransport
+	digestTransport *edgedac.DigestTransport
 	address         string
 	username        string
 	password        string
 }
 
+func init() {
+	Register("hikvision", NewHikvisionCameraDriver, DriverInfo{
+		Description:    "Hikvision cameras (ISAPI)",
+		RequiredFields: []string{"Address", "Username", "Password"},
+	})
+}
+
 func NewHikvisionCameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
@@ -33,18 +45,18 @@ func (cam *HikvisionCameraDriver) Configure(address, username, password string)
 	return nil
 }
 
-func (cam *HikvisionCameraDriver) ExtractImage() (*Image, error) {
+func (cam *HikvisionCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
 	// http://10.22.15.61/ISAPI/Streaming/channels/1/picture
 
 	address := cam.address + "/ISAPI/Streaming/channels/1/picture"
 
 	if cam.digestTransport == nil {
-		t := dac.NewTransport(cam.username, cam.password)
+		t := edgedac.NewTransport(cam.username, cam.password)
 		cam.digestTransport = &t
 		cam.digestTransport.HTTPClient = &cam.httpClient
 	}
 
-	req, err := http.NewRequest("GET", address, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -85,25 +97,214 @@ func (cam *HikvisionCameraDriver) ExtractImage() (*Image, error) {
 	return &img, nil
 }
 
-func (cam *HikvisionCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
+type hikvisionPtzPresetList struct {
+	Presets []struct {
+		ID   int    `xml:"id"`
+		Name string `xml:"presetName"`
+	} `xml:"PTZPreset"`
 }
 
-func (cam *HikvisionCameraDriver) Ping(address string) bool {
-	return true
+// GotoPreset moves the camera to preset using ISAPI PTZCtrl API. Preset is either preset number or preset name.
+func (cam *HikvisionCameraDriver) GotoPreset(ctx context.Context, preset string) error {
+	presetID, err := strconv.Atoi(preset)
+	if err != nil {
+		presetID, err = cam.findPresetID(ctx, preset)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = cam.callIsapi(ctx, "PUT", fmt.Sprintf("/ISAPI/PTZCtrl/channels/1/presets/%d/goto", presetID))
+	return err
 }
 
-func (cam *HikvisionCameraDriver) Commit(transactionId string) error {
-	return nil
+// findPresetID returns ID of preset with given name.
+func (cam *HikvisionCameraDriver) findPresetID(ctx context.Context, name string) (int, error) {
+	body, err := cam.callIsapi(ctx, "GET", "/ISAPI/PTZCtrl/channels/1/presets")
+	if err != nil {
+		return 0, err
+	}
+	var presets hikvisionPtzPresetList
+	if err := xml.Unmarshal(body, &presets); err != nil {
+		return 0, fmt.Errorf("failed to parse ptz presets : %w", err)
+	}
+	for _, preset := range presets.Presets {
+		if preset.Name == name {
+			return preset.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("ptz preset %s not found", name)
 }
 
-func (cam *HikvisionCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, fmt.Errorf("hikvision camera driver does not support event streaming")
+func (cam *HikvisionCameraDriver) callIsapi(ctx context.Context, method, path string) ([]byte, error) {
+	if cam.digestTransport == nil {
+		t := edgedac.NewTransport(cam.username, cam.password)
+		cam.digestTransport = &t
+		cam.digestTransport.HTTPClient = &cam.httpClient
+	}
+	req, err := http.NewRequestWithContext(ctx, method, cam.address+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cam.digestTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("camera api %s returned error code %s", path, resp.Status)
+	}
+	return body, nil
 }
 
-func (cam *HikvisionCameraDriver) Close() {
+// hikvisionAlertStreamIdleTimeout is the maximum time without any data on alert stream , cameras send heartbeat (videoloss) every few seconds.
+const hikvisionAlertStreamIdleTimeout = 60 * time.Second
+
+type hikvisionEventNotificationAlert struct {
+	IPAddress        string `xml:"ipAddress"`
+	ChannelID        string `xml:"channelID"`
+	DynChannelID     string `xml:"dynChannelID"`
+	DateTime         string `xml:"dateTime"`
+	EventType        string `xml:"eventType"`
+	EventState       string `xml:"eventState"`
+	EventDescription string `xml:"eventDescription"`
 }
 
-func (cam *HikvisionCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
+// SubscribeToEventsStream opens ISAPI alert stream (multipart long-poll) and starts reading event notifications in background.
+// Event topic is <eventType>/<channelID> , for example VMD/1 or linedetection/1 . Type is ISAPI event type and State is active or inactive.
+// TopicFilter is matched against the topic as exact topic , prefix (VMD matches VMD/1) or glob pattern (*/1) ,
+// ContentFilter is matched as a substring of the raw EventNotificationAlert XML.
+// The channel is closed when the stream is lost or ctx is cancelled.
+func (cam *HikvisionCameraDriver) SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (chan CameraEvent, error) {
+	// alert stream is a long living request , the default client timeout would terminate it
+	transport := edgedac.NewTransport(cam.username, cam.password)
+	transport.HTTPClient = &http.Client{}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(streamCtx, "GET", cam.address+"/ISAPI/Event/notification/alertStream", nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	watchdog := time.AfterFunc(hikvisionAlertStreamIdleTimeout, cancel)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		watchdog.Stop()
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		watchdog.Stop()
+		cancel()
+		return nil, fmt.Errorf("camera alert stream returned error code %s", resp.Status)
+	}
+	boundary := "boundary"
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		boundary = params["boundary"]
+	}
+	log.Info("Subscribed to Hikvision camera alert stream. Camera address : ", cam.address)
+
+	messages := make(chan CameraEvent, 10)
+	go cam.readAlertStream(resp.Body, boundary, eventFilters, messages, watchdog, cancel)
+	return messages, nil
+}
+
+func (cam *HikvisionCameraDriver) readAlertStream(body io.ReadCloser, boundary string, eventFilters []EventFilter, messages chan CameraEvent, watchdog *time.Timer, cancel context.CancelFunc) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Info("Recovered from panic:", r)
+		}
+		watchdog.Stop()
+		cancel()
+		body.Close()
+		close(messages)
+		log.Info("Disconnected from Hikvision camera alert stream.")
+	}()
+	source := "cam:hikvision:" + cam.address
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			log.Debug("Hikvision alert stream has been terminated : ", err)
+			return
+		}
+		watchdog.Reset(hikvisionAlertStreamIdleTimeout)
+		rawData, err := io.ReadAll(part)
+		if err != nil {
+			log.Debug("Failed to read Hikvision alert stream part : ", err)
+			return
+		}
+		if contentType := part.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "xml") {
+			// some cameras attach snapshots of detected objects
+			continue
+		}
+		event, isHeartbeat, err := parseHikvisionAlert(rawData, source)
+		if err != nil {
+			log.Info("Error parsing Hikvision event notification : ", err)
+			continue
+		}
+		if isHeartbeat || !MatchEventFilters(eventFilters, event.Topic, rawData) {
+			continue
+		}
+		select {
+		case messages <- event:
+		default:
+			log.Info("Channel is full, message not sent")
+		}
+	}
+}
+
+// parseHikvisionAlert converts EventNotificationAlert XML into CameraEvent. isHeartbeat is true for inactive videoloss alerts
+// that cameras send periodically to keep the stream alive.
+func parseHikvisionAlert(rawData []byte, source string) (event CameraEvent, isHeartbeat bool, err error) {
+	var alert hikvisionEventNotificationAlert
+	if err := xml.Unmarshal(rawData, &alert); err != nil {
+		return event, false, err
+	}
+	if alert.EventType == "" {
+		return event, false, fmt.Errorf("event type is missing")
+	}
+	if alert.EventType == "videoloss" && alert.EventState == "inactive" {
+		return event, true, nil
+	}
+	channelID := alert.ChannelID
+	if channelID == "" {
+		channelID = alert.DynChannelID
+	}
+	topic := alert.EventType
+	if channelID != "" {
+		topic = alert.EventType + "/" + channelID
+	}
+	timestamp := time.Now().UnixMilli()
+	if alert.DateTime != "" {
+		if t, err := time.Parse(time.RFC3339, alert.DateTime); err == nil {
+			timestamp = t.UnixMilli()
+		} else if t, err := time.ParseInLocation("2006-01-02T15:04:05", alert.DateTime, time.Local); err == nil {
+			// older firmware reports local time without time zone
+			timestamp = t.UnixMilli()
+		}
+	}
+	if alert.IPAddress != "" {
+		source = "cam:hikvision:" + alert.IPAddress
+	}
+	return CameraEvent{
+		CoreType:  "notification",
+		Type:      alert.EventType,
+		Topic:     topic,
+		Source:    source,
+		Timestamp: timestamp,
+		RawData:   rawData,
+		State:     alert.EventState,
+	}, false, nil
+}
+
+func (cam *HikvisionCameraDriver) Ping(address string) bool {
+	return true
+}
+
+func (cam *HikvisionCameraDriver) Close() {
 }
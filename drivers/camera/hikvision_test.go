@@ -0,0 +1,328 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func hikvisionAlertXML(eventType, state, channelID, dateTime string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<EventNotificationAlert version="2.0" xmlns="http://www.hikvision.com/ver20/XMLSchema">
+<ipAddress>10.0.0.5</ipAddress>
+<channelID>%s</channelID>
+<dateTime>%s</dateTime>
+<eventType>%s</eventType>
+<eventState>%s</eventState>
+<eventDescription>%s alarm</eventDescription>
+</EventNotificationAlert>`, channelID, dateTime, eventType, state, eventType)
+}
+
+// newHikvisionAlertStreamServer returns a server that sends the parts as multipart alert stream. The stream is kept open
+// until the client disconnects if keepOpen is true.
+func newHikvisionAlertStreamServer(t *testing.T, parts []textproto.MIMEHeader, bodies []string, keepOpen bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ISAPI/Event/notification/alertStream" {
+			http.NotFound(w, r)
+			return
+		}
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		for i, header := range parts {
+			part, err := mw.CreatePart(header)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			part.Write([]byte(bodies[i]))
+			w.(http.Flusher).Flush()
+		}
+		if keepOpen {
+			<-r.Context().Done()
+			return
+		}
+		mw.Close()
+	}))
+}
+
+func xmlPart() textproto.MIMEHeader {
+	return textproto.MIMEHeader{"Content-Type": {"application/xml; charset=\"UTF-8\""}}
+}
+
+// readEvents reads events until the channel is closed. The test fails if the channel isn't closed within timeout.
+func readEvents(t *testing.T, stream chan CameraEvent, timeout time.Duration) []CameraEvent {
+	t.Helper()
+	var events []CameraEvent
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-stream:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-deadline:
+			t.Fatalf("events channel wasn't closed within %s , received %d events", timeout, len(events))
+		}
+	}
+}
+
+func TestHikvisionAlertStream(t *testing.T) {
+	parts := []textproto.MIMEHeader{xmlPart(), xmlPart(), {"Content-Type": {"image/jpeg"}}, xmlPart(), xmlPart()}
+	bodies := []string{
+		hikvisionAlertXML("VMD", "active", "1", "2024-05-02T10:15:30+02:00"),
+		hikvisionAlertXML("videoloss", "inactive", "0", "2024-05-02T10:15:31+02:00"),
+		"\xff\xd8\xff\xe0 not really a jpeg",
+		hikvisionAlertXML("linedetection", "active", "2", "2024-05-02T10:15:32+02:00"),
+		hikvisionAlertXML("VMD", "inactive", "1", "2024-05-02T10:15:35+02:00"),
+	}
+	server := newHikvisionAlertStreamServer(t, parts, bodies, false)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		filters []EventFilter
+		topics  []string
+	}{
+		{name: "no filters", topics: []string{"VMD/1", "linedetection/2", "VMD/1"}},
+		{name: "exact topic", filters: []EventFilter{{TopicFilter: "linedetection/2"}}, topics: []string{"linedetection/2"}},
+		{name: "topic prefix", filters: []EventFilter{{TopicFilter: "VMD"}}, topics: []string{"VMD/1", "VMD/1"}},
+		{name: "glob pattern", filters: []EventFilter{{TopicFilter: "*/2"}}, topics: []string{"linedetection/2"}},
+		{name: "content filter", filters: []EventFilter{{ContentFilter: "<eventState>inactive"}}, topics: []string{"VMD/1"}},
+		{name: "no match", filters: []EventFilter{{TopicFilter: "tamperdetection"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := NewHikvisionCameraDriver()
+			driver.Configure(server.URL, "admin", "secret")
+			stream, err := driver.(EventStreamer).SubscribeToEventsStream(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := readEvents(t, stream, 5*time.Second)
+			if len(events) != len(tt.topics) {
+				t.Fatalf("expected %d events , got %d : %+v", len(tt.topics), len(events), events)
+			}
+			for i, event := range events {
+				if event.Topic != tt.topics[i] {
+					t.Errorf("event %d : expected topic %s , got %s", i, tt.topics[i], event.Topic)
+				}
+			}
+		})
+	}
+
+	t.Run("event fields", func(t *testing.T) {
+		driver := NewHikvisionCameraDriver()
+		driver.Configure(server.URL, "admin", "secret")
+		stream, err := driver.(EventStreamer).SubscribeToEventsStream(context.Background(), []EventFilter{{TopicFilter: "VMD"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := readEvents(t, stream, 5*time.Second)
+		if len(events) != 2 {
+			t.Fatalf("expected VMD active and inactive events , got %+v", events)
+		}
+		start := time.Date(2024, 5, 2, 8, 15, 30, 0, time.UTC).UnixMilli()
+		expected := []struct {
+			state     string
+			timestamp int64
+		}{{EventStateActive, start}, {EventStateInactive, start + 5000}}
+		for i, event := range events {
+			if event.Type != "VMD" || event.Topic != "VMD/1" || event.CoreType != "notification" {
+				t.Errorf("event %d : unexpected type %s , topic %s , core type %s", i, event.Type, event.Topic, event.CoreType)
+			}
+			if event.State != expected[i].state || event.Timestamp != expected[i].timestamp {
+				t.Errorf("event %d : expected state %s and timestamp %d , got %s and %d", i, expected[i].state, expected[i].timestamp, event.State, event.Timestamp)
+			}
+			if event.Source != "cam:hikvision:10.0.0.5" {
+				t.Errorf("event %d : unexpected source %s", i, event.Source)
+			}
+		}
+	})
+}
+
+func TestHikvisionAlertStreamClosedOnCancel(t *testing.T) {
+	// part is complete when the next boundary is received , cameras send heartbeats every few seconds
+	bodies := []string{hikvisionAlertXML("VMD", "active", "1", ""), hikvisionAlertXML("videoloss", "inactive", "0", "")}
+	server := newHikvisionAlertStreamServer(t, []textproto.MIMEHeader{xmlPart(), xmlPart()}, bodies, true)
+	defer server.Close()
+	driver := NewHikvisionCameraDriver()
+	driver.Configure(server.URL, "admin", "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := driver.(EventStreamer).SubscribeToEventsStream(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-stream:
+		if event.Topic != "VMD/1" {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event wasn't received")
+	}
+	cancel()
+	if events := readEvents(t, stream, 5*time.Second); len(events) != 0 {
+		t.Fatalf("unexpected events after cancel : %+v", events)
+	}
+}
+
+func TestHikvisionAlertStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+	driver := NewHikvisionCameraDriver()
+	driver.Configure(server.URL, "admin", "secret")
+	if _, err := driver.(EventStreamer).SubscribeToEventsStream(context.Background(), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseHikvisionAlert(t *testing.T) {
+	tests := []struct {
+		name        string
+		rawData     string
+		isHeartbeat bool
+		isError     bool
+		topic       string
+		state       string
+		source      string
+		timestamp   int64
+	}{
+		{
+			name:      "motion with time zone",
+			rawData:   hikvisionAlertXML("VMD", "active", "1", "2024-05-02T10:15:30Z"),
+			topic:     "VMD/1",
+			state:     EventStateActive,
+			source:    "cam:hikvision:10.0.0.5",
+			timestamp: time.Date(2024, 5, 2, 10, 15, 30, 0, time.UTC).UnixMilli(),
+		},
+		{
+			name:      "local time without time zone",
+			rawData:   hikvisionAlertXML("linedetection", "inactive", "3", "2024-05-02T10:15:30"),
+			topic:     "linedetection/3",
+			state:     EventStateInactive,
+			source:    "cam:hikvision:10.0.0.5",
+			timestamp: time.Date(2024, 5, 2, 10, 15, 30, 0, time.Local).UnixMilli(),
+		},
+		{
+			name:    "dynamic channel and no ip address",
+			rawData: `<EventNotificationAlert><dynChannelID>7</dynChannelID><eventType>fielddetection</eventType><eventState>active</eventState></EventNotificationAlert>`,
+			topic:   "fielddetection/7",
+			state:   EventStateActive,
+			source:  "cam:hikvision:http://camera",
+		},
+		{
+			name:    "no channel",
+			rawData: `<EventNotificationAlert><eventType>diskfull</eventType><eventState>active</eventState></EventNotificationAlert>`,
+			topic:   "diskfull",
+			state:   EventStateActive,
+			source:  "cam:hikvision:http://camera",
+		},
+		{
+			name:        "videoloss heartbeat",
+			rawData:     hikvisionAlertXML("videoloss", "inactive", "0", "2024-05-02T10:15:30Z"),
+			isHeartbeat: true,
+		},
+		{
+			name:    "active videoloss",
+			rawData: hikvisionAlertXML("videoloss", "active", "1", "2024-05-02T10:15:30Z"),
+			topic:   "videoloss/1",
+			state:   EventStateActive,
+			source:  "cam:hikvision:10.0.0.5",
+		},
+		{name: "missing event type", rawData: `<EventNotificationAlert><channelID>1</channelID></EventNotificationAlert>`, isError: true},
+		{name: "invalid xml", rawData: `<EventNotificationAlert><eventType>VMD`, isError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, isHeartbeat, err := parseHikvisionAlert([]byte(tt.rawData), "cam:hikvision:http://camera")
+			if tt.isError {
+				if err == nil {
+					t.Fatalf("expected error , got event %+v", event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isHeartbeat != tt.isHeartbeat {
+				t.Fatalf("expected heartbeat %v , got %v", tt.isHeartbeat, isHeartbeat)
+			}
+			if tt.isHeartbeat {
+				return
+			}
+			if event.Topic != tt.topic || event.State != tt.state || event.Source != tt.source {
+				t.Errorf("expected topic %s , state %s , source %s , got %s , %s , %s", tt.topic, tt.state, tt.source, event.Topic, event.State, event.Source)
+			}
+			if tt.timestamp != 0 && event.Timestamp != tt.timestamp {
+				t.Errorf("expected timestamp %d , got %d", tt.timestamp, event.Timestamp)
+			}
+			if string(event.RawData) != tt.rawData {
+				t.Errorf("raw data isn't preserved")
+			}
+		})
+	}
+}
+
+func TestEventFilterMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter    string
+		topic     string
+		isMatched bool
+	}{
+		{"", "VMD/1", true},
+		{"VMD/1", "VMD/1", true},
+		{"VMD/1", "VMD/2", false},
+		{"VMD", "VMD/1", true},
+		{"VMD/", "VMD/1", true},
+		{"VM", "VMD/1", false},
+		{"tns1:VideoSource", "tns1:VideoSource/MotionAlarm", true},
+		{"*/1", "VMD/1", true},
+		{"*/1", "VMD/2", false},
+		{"*/1", "a/b/1", false},
+		{"tns1:RuleEngine/*/Motion", "tns1:RuleEngine/CellMotionDetector/Motion", true},
+		{"VMD/[12]", "VMD/2", true},
+		{"[", "[", true},
+		{"[", "VMD/1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter+" "+tt.topic, func(t *testing.T) {
+			if isMatched := (EventFilter{TopicFilter: tt.filter}).MatchTopic(tt.topic); isMatched != tt.isMatched {
+				t.Errorf("filter %q , topic %q : expected %v , got %v", tt.filter, tt.topic, tt.isMatched, isMatched)
+			}
+		})
+	}
+}
+
+func TestMatchEventFilters(t *testing.T) {
+	rawData := []byte(hikvisionAlertXML("VMD", "active", "1", ""))
+	tests := []struct {
+		name      string
+		filters   []EventFilter
+		topic     string
+		isMatched bool
+	}{
+		{name: "no filters", topic: "VMD/1", isMatched: true},
+		{name: "topic filter", filters: []EventFilter{{TopicFilter: "VMD"}}, topic: "VMD/1", isMatched: true},
+		{name: "topic filter mismatch", filters: []EventFilter{{TopicFilter: "linedetection"}}, topic: "VMD/1", isMatched: false},
+		{name: "content filter", filters: []EventFilter{{ContentFilter: "<eventState>active"}}, topic: "VMD/1", isMatched: true},
+		{name: "content filter mismatch", filters: []EventFilter{{ContentFilter: "<eventState>inactive"}}, topic: "VMD/1", isMatched: false},
+		{name: "topic and content filter", filters: []EventFilter{{TopicFilter: "*/1", ContentFilter: "10.0.0.5"}}, topic: "VMD/1", isMatched: true},
+		{name: "topic matches , content doesn't", filters: []EventFilter{{TopicFilter: "VMD", ContentFilter: "10.0.0.6"}}, topic: "VMD/1", isMatched: false},
+		{name: "any filter matches", filters: []EventFilter{{TopicFilter: "linedetection"}, {TopicFilter: "VMD/1"}}, topic: "VMD/1", isMatched: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if isMatched := MatchEventFilters(tt.filters, tt.topic, rawData); isMatched != tt.isMatched {
+				t.Errorf("expected %v , got %v", tt.isMatched, isMatched)
+			}
+		})
+	}
+}
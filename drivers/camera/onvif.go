@@ -0,0 +1,644 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	edgedac "github.com/cognitedata/edge-extractor/internal/auth/digest"
+	log "github.com/sirupsen/logrus"
+)
+
+// docs : https://www.onvif.org/profiles/specifications/
+// Driver implements ONVIF Profile S (media) and Profile T (events) over SOAP 1.2 with WS-Security UsernameToken auth.
+// Address must point to the camera root , for example http://10.22.15.61 . Device service is expected at /onvif/device_service ,
+// all other services are discovered using GetCapabilities.
+
+const (
+	onvifDeviceServicePath = "/onvif/device_service"
+	onvifPullTimeout       = "PT5S"
+	onvifSubscriptionTTL   = "PT60S"
+	onvifMaxPullMessages   = 10
+	onvifPullRetryDelay    = time.Second // delay after failed PullMessages , doubled after each consecutive failure
+	onvifMaxPullFailures   = 3           // the stream is closed after this many consecutive failures , the caller subscribes again
+)
+
+type OnvifCameraDriver struct {
+	httpClient       http.Client
+	digestTransport  *edgedac.DigestTransport
+	address          string
+	username         string
+	password         string
+	deviceServiceURL string
+	mediaServiceURL  string
+	eventsServiceURL string
+	snapshotURI      string
+	timeOffset       time.Duration // difference between camera clock and local clock , used for WS-Security timestamps
+	subscriptionURL  string
+	isSubscribed     bool
+	mux              sync.Mutex // guards discovered service addresses , snapshot uri , time offset and subscription state
+}
+
+// soapEnvelope is used to parse all SOAP responses. Body content is kept raw and parsed by each operation.
+type soapEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    struct {
+		Fault *struct {
+			Code struct {
+				Value   string `xml:"Value"`
+				Subcode struct {
+					Value string `xml:"Value"`
+				} `xml:"Subcode"`
+			} `xml:"Code"`
+			Reason struct {
+				Text string `xml:"Text"`
+			} `xml:"Reason"`
+		} `xml:"Fault"`
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type onvifSystemDateAndTimeResponse struct {
+	UTCDateTime struct {
+		Date struct {
+			Year  int `xml:"Year"`
+			Month int `xml:"Month"`
+			Day   int `xml:"Day"`
+		} `xml:"Date"`
+		Time struct {
+			Hour   int `xml:"Hour"`
+			Minute int `xml:"Minute"`
+			Second int `xml:"Second"`
+		} `xml:"Time"`
+	} `xml:"GetSystemDateAndTimeResponse>SystemDateAndTime>UTCDateTime"`
+}
+
+type onvifCapabilitiesResponse struct {
+	MediaXAddr  string `xml:"GetCapabilitiesResponse>Capabilities>Media>XAddr"`
+	EventsXAddr string `xml:"GetCapabilitiesResponse>Capabilities>Events>XAddr"`
+}
+
+type onvifProfilesResponse struct {
+	Profiles []struct {
+		Token string `xml:"token,attr"`
+		Name  string `xml:"Name"`
+	} `xml:"GetProfilesResponse>Profiles"`
+}
+
+type onvifSnapshotUriResponse struct {
+	Uri string `xml:"GetSnapshotUriResponse>MediaUri>Uri"`
+}
+
+type onvifCreatePullPointSubscriptionResponse struct {
+	Address string `xml:"CreatePullPointSubscriptionResponse>SubscriptionReference>Address"`
+}
+
+type onvifSimpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type onvifNotificationMessage struct {
+	Raw     []byte `xml:",innerxml"`
+	Topic   string `xml:"Topic"`
+	Message struct {
+		UtcTime           string            `xml:"UtcTime,attr"`
+		PropertyOperation string            `xml:"PropertyOperation,attr"`
+		Source            []onvifSimpleItem `xml:"Source>SimpleItem"`
+		Key               []onvifSimpleItem `xml:"Key>SimpleItem"`
+		Data              []onvifSimpleItem `xml:"Data>SimpleItem"`
+	} `xml:"Message>Message"`
+}
+
+type onvifPullMessagesResponse struct {
+	Messages []onvifNotificationMessage `xml:"PullMessagesResponse>NotificationMessage"`
+}
+
+func init() {
+	Register("onvif", NewOnvifCameraDriver, DriverInfo{
+		Description:    "Generic ONVIF Profile S/T cameras",
+		RequiredFields: []string{"Address", "Username", "Password"},
+	})
+}
+
+func NewOnvifCameraDriver() Driver {
+	httpClient := http.Client{
+		Timeout: 15 * time.Second,
+	}
+	return &OnvifCameraDriver{httpClient: httpClient}
+}
+
+func (cam *OnvifCameraDriver) Configure(address, username, password string) error {
+	cam.address = strings.TrimSuffix(address, "/")
+	cam.username = username
+	cam.password = password
+	cam.deviceServiceURL = cam.address + onvifDeviceServicePath
+	cam.mux.Lock()
+	cam.mediaServiceURL = ""
+	cam.eventsServiceURL = ""
+	cam.snapshotURI = ""
+	cam.mux.Unlock()
+	return nil
+}
+
+// discoverServices syncs clock with the camera and resolves media and events service addresses. The operation is executed only once ,
+// discovered addresses are returned.
+func (cam *OnvifCameraDriver) discoverServices(ctx context.Context) (mediaServiceURL, eventsServiceURL string, err error) {
+	cam.mux.Lock()
+	mediaServiceURL, eventsServiceURL = cam.mediaServiceURL, cam.eventsServiceURL
+	cam.mux.Unlock()
+	if mediaServiceURL != "" {
+		return mediaServiceURL, eventsServiceURL, nil
+	}
+	cam.syncTime(ctx)
+
+	body, err := cam.soapCall(ctx, cam.deviceServiceURL, `<GetCapabilities xmlns="http://www.onvif.org/ver10/device/wsdl"><Category>All</Category></GetCapabilities>`)
+	if err != nil {
+		return "", "", fmt.Errorf("GetCapabilities failed: %w", err)
+	}
+	var capabilities onvifCapabilitiesResponse
+	if err := xml.Unmarshal(wrapBody(body), &capabilities); err != nil {
+		return "", "", err
+	}
+	if capabilities.MediaXAddr == "" {
+		return "", "", fmt.Errorf("camera does not advertise ONVIF media service")
+	}
+	mediaServiceURL = cam.rebaseXAddr(capabilities.MediaXAddr)
+	if capabilities.EventsXAddr != "" {
+		eventsServiceURL = cam.rebaseXAddr(capabilities.EventsXAddr)
+	}
+	cam.mux.Lock()
+	cam.mediaServiceURL, cam.eventsServiceURL = mediaServiceURL, eventsServiceURL
+	cam.mux.Unlock()
+	log.Debugf("ONVIF services discovered. Media : %s , Events : %s", mediaServiceURL, eventsServiceURL)
+	return mediaServiceURL, eventsServiceURL, nil
+}
+
+// syncTime reads camera clock and calculates offset that is applied to WS-Security timestamps. Errors are ignored since
+// the operation doesn't require authentication and is not supported by all devices.
+func (cam *OnvifCameraDriver) syncTime(ctx context.Context) {
+	body, err := cam.soapCallWithAuth(ctx, cam.deviceServiceURL, `<GetSystemDateAndTime xmlns="http://www.onvif.org/ver10/device/wsdl"/>`, "", false)
+	if err != nil {
+		log.Debug("ONVIF GetSystemDateAndTime failed, using local clock. Err:", err.Error())
+		return
+	}
+	var resp onvifSystemDateAndTimeResponse
+	if err := xml.Unmarshal(wrapBody(body), &resp); err != nil || resp.UTCDateTime.Date.Year == 0 {
+		return
+	}
+	d, t := resp.UTCDateTime.Date, resp.UTCDateTime.Time
+	cameraTime := time.Date(d.Year, time.Month(d.Month), d.Day, t.Hour, t.Minute, t.Second, 0, time.UTC)
+	cam.mux.Lock()
+	cam.timeOffset = time.Until(cameraTime)
+	cam.mux.Unlock()
+}
+
+// rebaseXAddr replaces host in service address advertised by the camera with the host from configured address.
+// Cameras behind NAT or port forwarding often advertise their internal address.
+func (cam *OnvifCameraDriver) rebaseXAddr(xaddr string) string {
+	advertised, err := url.Parse(strings.TrimSpace(xaddr))
+	if err != nil {
+		return xaddr
+	}
+	configured, err := url.Parse(cam.address)
+	if err != nil || configured.Host == "" {
+		return advertised.String()
+	}
+	advertised.Scheme = configured.Scheme
+	advertised.Host = configured.Host
+	return advertised.String()
+}
+
+// resolveSnapshotURI returns snapshot uri of the first media profile. The uri is resolved once and cached.
+func (cam *OnvifCameraDriver) resolveSnapshotURI(ctx context.Context) (string, error) {
+	cam.mux.Lock()
+	snapshotURI := cam.snapshotURI
+	cam.mux.Unlock()
+	if snapshotURI != "" {
+		return snapshotURI, nil
+	}
+	mediaServiceURL, _, err := cam.discoverServices(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, err := cam.soapCall(ctx, mediaServiceURL, `<GetProfiles xmlns="http://www.onvif.org/ver10/media/wsdl"/>`)
+	if err != nil {
+		return "", fmt.Errorf("GetProfiles failed: %w", err)
+	}
+	var profiles onvifProfilesResponse
+	if err := xml.Unmarshal(wrapBody(body), &profiles); err != nil {
+		return "", err
+	}
+	if len(profiles.Profiles) == 0 {
+		return "", fmt.Errorf("camera has no media profiles")
+	}
+	// The first profile is usually the main stream with the highest resolution
+	profileToken := profiles.Profiles[0].Token
+	request := fmt.Sprintf(`<GetSnapshotUri xmlns="http://www.onvif.org/ver10/media/wsdl"><ProfileToken>%s</ProfileToken></GetSnapshotUri>`, xmlEscape(profileToken))
+	body, err = cam.soapCall(ctx, mediaServiceURL, request)
+	if err != nil {
+		return "", fmt.Errorf("GetSnapshotUri failed: %w", err)
+	}
+	var snapshot onvifSnapshotUriResponse
+	if err := xml.Unmarshal(wrapBody(body), &snapshot); err != nil {
+		return "", err
+	}
+	if snapshot.Uri == "" {
+		return "", fmt.Errorf("camera returned empty snapshot uri for profile %s", profileToken)
+	}
+	snapshotURI = cam.rebaseXAddr(snapshot.Uri)
+	cam.mux.Lock()
+	cam.snapshotURI = snapshotURI
+	cam.mux.Unlock()
+	log.Infof("ONVIF snapshot uri resolved for profile %s : %s", profileToken, snapshotURI)
+	return snapshotURI, nil
+}
+
+func (cam *OnvifCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
+	snapshotURI, err := cam.resolveSnapshotURI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cam.mux.Lock()
+	if cam.digestTransport == nil {
+		t := edgedac.NewTransport(cam.username, cam.password)
+		cam.digestTransport = &t
+		cam.digestTransport.HTTPClient = &cam.httpClient
+	}
+	digestTransport := cam.digestTransport
+	cam.mux.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", snapshotURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := digestTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Snapshot uri can become stale after camera reconfiguration , it will be resolved again on next run
+		cam.mux.Lock()
+		if cam.snapshotURI == snapshotURI {
+			cam.snapshotURI = ""
+		}
+		cam.mux.Unlock()
+		return nil, fmt.Errorf("camera api returned error code %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+
+	if !strings.Contains(contentType, "image/jpeg") {
+		log.Errorf("Incompatable content type %s from camera API", contentType)
+		return nil, fmt.Errorf("incompatible content type %s", contentType)
+	}
+
+	img := Image{Body: body, Format: "image/jpeg"}
+
+	return &img, nil
+}
+
+func (cam *OnvifCameraDriver) Ping(address string) bool {
+	return true
+}
+
+// GetCameraCapabilitiesManifest returns raw GetCapabilities and GetServices responses. componentName can be "all", "capabilities" or "services".
+func (cam *OnvifCameraDriver) GetCameraCapabilitiesManifest(componentName string) ([]CameraCapabilitiesManifest, error) {
+	requests := []struct {
+		component string
+		name      string
+		body      string
+	}{
+		{"capabilities", "capabilities.xml", `<GetCapabilities xmlns="http://www.onvif.org/ver10/device/wsdl"><Category>All</Category></GetCapabilities>`},
+		{"services", "services.xml", `<GetServices xmlns="http://www.onvif.org/ver10/device/wsdl"><IncludeCapability>true</IncludeCapability></GetServices>`},
+	}
+	ctx := context.Background()
+	cam.syncTime(ctx)
+	var manifests []CameraCapabilitiesManifest
+	for _, r := range requests {
+		if componentName != "all" && componentName != r.component {
+			continue
+		}
+		body, err := cam.soapCall(ctx, cam.deviceServiceURL, r.body)
+		if err != nil {
+			log.Infof("ONVIF manifest component %s can't be retrieved. Err: %s", r.component, err.Error())
+			continue
+		}
+		manifests = append(manifests, CameraCapabilitiesManifest{
+			Name:          r.name,
+			Format:        "soap",
+			ComponentName: r.component,
+			Body:          body,
+			IsRaw:         true,
+		})
+	}
+	if len(manifests) == 0 && componentName == "all" {
+		return nil, fmt.Errorf("camera didn't return any ONVIF capabilities")
+	}
+	return manifests, nil
+}
+
+// SubscribeToEventsStream creates ONVIF PullPoint subscription and starts pulling notifications in background.
+// TopicFilter is an ONVIF ConcreteSet topic expression , for example "tns1:RuleEngine/CellMotionDetector/Motion" or "tns1:VideoSource//." .
+// ContentFilter is an XPath expression applied to the message , for example "boolean(//SimpleItem[@Name=\"IsMotion\" and @Value=\"true\"])" .
+// The channel is closed when subscription is lost , ctx is cancelled or driver is closed.
+func (cam *OnvifCameraDriver) SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (chan CameraEvent, error) {
+	_, eventsServiceURL, err := cam.discoverServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if eventsServiceURL == "" {
+		return nil, fmt.Errorf("camera does not advertise ONVIF events service")
+	}
+
+	request := `<CreatePullPointSubscription xmlns="http://www.onvif.org/ver10/events/wsdl">` +
+		buildOnvifEventFilter(eventFilters) +
+		`<InitialTerminationTime>` + onvifSubscriptionTTL + `</InitialTerminationTime></CreatePullPointSubscription>`
+	body, err := cam.soapCall(ctx, eventsServiceURL, request)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePullPointSubscription failed: %w", err)
+	}
+	var subscription onvifCreatePullPointSubscriptionResponse
+	if err := xml.Unmarshal(wrapBody(body), &subscription); err != nil {
+		return nil, err
+	}
+	if subscription.Address == "" {
+		return nil, fmt.Errorf("camera returned empty subscription address")
+	}
+
+	cam.mux.Lock()
+	cam.subscriptionURL = cam.rebaseXAddr(subscription.Address)
+	cam.isSubscribed = true
+	subscriptionURL := cam.subscriptionURL
+	cam.mux.Unlock()
+	log.Info("Subscribed to ONVIF camera events. Subscription address : ", subscriptionURL)
+
+	messages := make(chan CameraEvent, 10)
+	go cam.pullMessagesLoop(ctx, subscriptionURL, messages)
+	return messages, nil
+}
+
+func (cam *OnvifCameraDriver) pullMessagesLoop(ctx context.Context, subscriptionURL string, messages chan CameraEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Info("Recovered from panic:", r)
+		}
+		close(messages)
+		log.Info("Disconnected from ONVIF camera event stream.")
+	}()
+	source := "cam:onvif:" + cam.address
+	lastRenew := time.Now()
+	request := fmt.Sprintf(`<PullMessages xmlns="http://www.onvif.org/ver10/events/wsdl"><Timeout>%s</Timeout><MessageLimit>%d</MessageLimit></PullMessages>`, onvifPullTimeout, onvifMaxPullMessages)
+	failures := 0
+	for {
+		cam.mux.Lock()
+		isSubscribed := cam.isSubscribed && cam.subscriptionURL == subscriptionURL
+		cam.mux.Unlock()
+		if !isSubscribed {
+			return
+		}
+
+		body, err := cam.soapCallWithAuth(ctx, subscriptionURL, request, "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/PullMessagesRequest", true)
+		if ctx.Err() != nil {
+			cam.unsubscribe(subscriptionURL)
+			return
+		}
+		var pullResponse onvifPullMessagesResponse
+		if err == nil {
+			if err = xml.Unmarshal(wrapBody(body), &pullResponse); err != nil {
+				err = fmt.Errorf("failed to parse response : %w", err)
+			}
+		}
+		// transport and parse errors are retried with the same backoff , so a camera returning malformed responses isn't polled in a busy loop
+		if err != nil {
+			failures++
+			if failures >= onvifMaxPullFailures {
+				log.Error("ONVIF PullMessages failed: ", err)
+				return
+			}
+			delay := onvifPullRetryDelay << (failures - 1)
+			log.Warnf("ONVIF PullMessages failed , retrying in %s : %s", delay, err)
+			select {
+			case <-ctx.Done():
+				cam.unsubscribe(subscriptionURL)
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		failures = 0
+		for _, msg := range pullResponse.Messages {
+			event := onvifMessageToCameraEvent(msg, source)
+			select {
+			case messages <- event:
+			default:
+				log.Info("Channel is full, message not sent")
+			}
+		}
+		// PullMessages extends subscription on most devices , explicit renew is done for devices that don't do it
+		if time.Since(lastRenew) > 30*time.Second {
+			renew := `<Renew xmlns="http://docs.oasis-open.org/wsn/b-2"><TerminationTime>` + onvifSubscriptionTTL + `</TerminationTime></Renew>`
+			if _, err := cam.soapCallWithAuth(ctx, subscriptionURL, renew, "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/RenewRequest", true); err != nil {
+				log.Debug("ONVIF subscription renew failed: ", err)
+			}
+			lastRenew = time.Now()
+		}
+	}
+}
+
+// onvifMessageToCameraEvent maps ONVIF notification onto CameraEvent. Type is the last segment of the topic , for example "Motion".
+func onvifMessageToCameraEvent(msg onvifNotificationMessage, source string) CameraEvent {
+	topic := strings.TrimSpace(msg.Topic)
+	eventType := topic
+	if i := strings.LastIndex(topic, "/"); i >= 0 {
+		eventType = topic[i+1:]
+	}
+	timestamp := time.Now().UnixMilli()
+	if msg.Message.UtcTime != "" {
+		if t, err := time.Parse(time.RFC3339Nano, msg.Message.UtcTime); err == nil {
+			timestamp = t.UnixMilli()
+		}
+	}
+	message := EventMessage{
+		Source: onvifSimpleItemsToMap(msg.Message.Source),
+		Key:    onvifSimpleItemsToMap(msg.Message.Key),
+		Data:   onvifSimpleItemsToMap(msg.Message.Data),
+	}
+	return CameraEvent{
+		CoreType:  "notification",
+		Type:      eventType,
+		Topic:     topic,
+		Source:    source,
+		Timestamp: timestamp,
+		RawData:   msg.Raw,
+		State:     EventStateFromData(message.Data),
+		Message:   message,
+	}
+}
+
+func onvifSimpleItemsToMap(items []onvifSimpleItem) map[string]string {
+	if len(items) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		result[item.Name] = item.Value
+	}
+	return result
+}
+
+// buildOnvifEventFilter converts list of event filters into wsnt Filter element. Topic expressions are combined using "|" operator ,
+// content filters are combined using "or" operator since ONVIF allows only one expression of each type.
+func buildOnvifEventFilter(eventFilters []EventFilter) string {
+	var topics, contents []string
+	for _, f := range eventFilters {
+		if f.TopicFilter != "" {
+			topics = append(topics, f.TopicFilter)
+		}
+		if f.ContentFilter != "" {
+			contents = append(contents, f.ContentFilter)
+		}
+	}
+	if len(topics) == 0 && len(contents) == 0 {
+		return ""
+	}
+	var filter strings.Builder
+	filter.WriteString(`<Filter>`)
+	if len(topics) > 0 {
+		filter.WriteString(`<wsnt:TopicExpression xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tns1="http://www.onvif.org/ver10/topics" xmlns:tnsaxis="http://www.axis.com/2009/event/topics" Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">`)
+		filter.WriteString(xmlEscape(strings.Join(topics, "|")))
+		filter.WriteString(`</wsnt:TopicExpression>`)
+	}
+	if len(contents) > 0 {
+		filter.WriteString(`<wsnt:MessageContent xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" Dialect="http://www.onvif.org/ver10/tev/messageContentFilter/ItemFilter">`)
+		filter.WriteString(xmlEscape(strings.Join(contents, " or ")))
+		filter.WriteString(`</wsnt:MessageContent>`)
+	}
+	filter.WriteString(`</Filter>`)
+	return filter.String()
+}
+
+// soapCall sends authenticated SOAP request and returns content of the response Body element.
+func (cam *OnvifCameraDriver) soapCall(ctx context.Context, serviceURL, body string) ([]byte, error) {
+	return cam.soapCallWithAuth(ctx, serviceURL, body, "", true)
+}
+
+// soapCallWithAuth sends SOAP 1.2 request. If action is set , WS-Addressing headers are added (required by PullPoint subscriptions).
+func (cam *OnvifCameraDriver) soapCallWithAuth(ctx context.Context, serviceURL, body, action string, withAuth bool) ([]byte, error) {
+	var header strings.Builder
+	if action != "" {
+		header.WriteString(`<wsa:Action xmlns:wsa="http://www.w3.org/2005/08/addressing">` + action + `</wsa:Action>`)
+		header.WriteString(`<wsa:To xmlns:wsa="http://www.w3.org/2005/08/addressing">` + xmlEscape(serviceURL) + `</wsa:To>`)
+	}
+	if withAuth && cam.username != "" {
+		header.WriteString(cam.buildSecurityHeader())
+	}
+	envelope := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Header>` + header.String() + `</s:Header>` +
+		`<s:Body>` + body + `</s:Body></s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, "POST", serviceURL, bytes.NewReader([]byte(envelope)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+	resp, err := cam.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed soapEnvelope
+	if err := xml.Unmarshal(respBody, &parsed); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("camera api returned error code %s", resp.Status)
+		}
+		return nil, fmt.Errorf("invalid SOAP response: %w", err)
+	}
+	if parsed.Body.Fault != nil {
+		fault := parsed.Body.Fault
+		return nil, fmt.Errorf("SOAP fault %s %s : %s", fault.Code.Value, fault.Code.Subcode.Value, strings.TrimSpace(fault.Reason.Text))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("camera api returned error code %s", resp.Status)
+	}
+	return parsed.Body.Content, nil
+}
+
+// buildSecurityHeader creates WS-Security UsernameToken header with password digest : Base64(SHA1(nonce + created + password))
+func (cam *OnvifCameraDriver) buildSecurityHeader() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	cam.mux.Lock()
+	timeOffset := cam.timeOffset
+	cam.mux.Unlock()
+	created := time.Now().Add(timeOffset).UTC().Format("2006-01-02T15:04:05.000Z")
+	hash := sha1.New()
+	hash.Write(nonce)
+	hash.Write([]byte(created))
+	hash.Write([]byte(cam.password))
+	digest := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	return `<Security s:mustUnderstand="1" xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd">` +
+		`<UsernameToken><Username>` + xmlEscape(cam.username) + `</Username>` +
+		`<Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest">` + digest + `</Password>` +
+		`<Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">` + base64.StdEncoding.EncodeToString(nonce) + `</Nonce>` +
+		`<Created xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">` + created + `</Created>` +
+		`</UsernameToken></Security>`
+}
+
+// wrapBody wraps inner content of SOAP Body into single root element , so it can be unmarshalled into response structs.
+func wrapBody(content []byte) []byte {
+	return append(append([]byte("<Body>"), content...), []byte("</Body>")...)
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (cam *OnvifCameraDriver) Close() {
+	cam.mux.Lock()
+	subscriptionURL := cam.subscriptionURL
+	cam.mux.Unlock()
+	if subscriptionURL != "" {
+		log.Info("Stopping ONVIF camera driver , unsubscribing from events")
+		cam.unsubscribe(subscriptionURL)
+	}
+}
+
+// unsubscribe terminates PullPoint subscription if it's still the active one.
+func (cam *OnvifCameraDriver) unsubscribe(subscriptionURL string) {
+	cam.mux.Lock()
+	isSubscribed := cam.isSubscribed && cam.subscriptionURL == subscriptionURL
+	if isSubscribed {
+		cam.isSubscribed = false
+		cam.subscriptionURL = ""
+	}
+	cam.mux.Unlock()
+	if isSubscribed {
+		cam.soapCallWithAuth(context.Background(), subscriptionURL, `<Unsubscribe xmlns="http://docs.oasis-open.org/wsn/b-2"/>`, "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/UnsubscribeRequest", true)
+	}
+}
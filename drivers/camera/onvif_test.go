@@ -0,0 +1,383 @@
+package camera
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// onvifTestRequest is SOAP request received by onvifStub.
+type onvifTestRequest struct {
+	Header struct {
+		Action   string `xml:"Action"`
+		To       string `xml:"To"`
+		Security *struct {
+			Username string `xml:"UsernameToken>Username"`
+			Password string `xml:"UsernameToken>Password"`
+			Nonce    string `xml:"UsernameToken>Nonce"`
+			Created  string `xml:"UsernameToken>Created"`
+		} `xml:"Security"`
+	} `xml:"Header"`
+	Body struct {
+		Operation struct {
+			XMLName xml.Name
+			Content string `xml:",innerxml"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// onvifStub is minimal ONVIF device. Service addresses and snapshot uri are advertised with camera internal address ,
+// so the driver must rebase them onto the configured address.
+type onvifStub struct {
+	t          *testing.T
+	username   string
+	password   string
+	clockSkew  time.Duration
+	mux        sync.Mutex
+	operations []string
+	requests   map[string]onvifTestRequest
+	pulls      int
+	// isMalformed makes PullMessages return response that isn't valid XML
+	isMalformed bool
+}
+
+const onvifStubInternalAddress = "http://192.168.0.90:8000"
+
+func newOnvifStub(t *testing.T) (*onvifStub, *httptest.Server) {
+	stub := &onvifStub{t: t, username: "admin", password: "secret", clockSkew: time.Hour, requests: map[string]onvifTestRequest{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (s *onvifStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/snapshot.jpg" {
+		if _, _, ok := r.BasicAuth(); !ok && r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="onvif", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("\xff\xd8\xff\xe0jpeg"))
+		return
+	}
+	rawRequest, _ := io.ReadAll(r.Body)
+	var request onvifTestRequest
+	if err := xml.Unmarshal(rawRequest, &request); err != nil {
+		s.t.Errorf("invalid SOAP request : %s", err)
+		return
+	}
+	operation := request.Body.Operation.XMLName.Local
+	s.mux.Lock()
+	s.operations = append(s.operations, operation)
+	s.requests[operation] = request
+	s.mux.Unlock()
+
+	if operation != "GetSystemDateAndTime" {
+		if err := s.verifySecurityHeader(request); err != nil {
+			s.writeFault(w, "ter:NotAuthorized", err.Error())
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	switch r.URL.Path + " " + operation {
+	case "/onvif/device_service GetSystemDateAndTime":
+		now := time.Now().Add(s.clockSkew).UTC()
+		s.writeResponse(w, fmt.Sprintf(`<tds:GetSystemDateAndTimeResponse><tds:SystemDateAndTime><tt:UTCDateTime>`+
+			`<tt:Time><tt:Hour>%d</tt:Hour><tt:Minute>%d</tt:Minute><tt:Second>%d</tt:Second></tt:Time>`+
+			`<tt:Date><tt:Year>%d</tt:Year><tt:Month>%d</tt:Month><tt:Day>%d</tt:Day></tt:Date>`+
+			`</tt:UTCDateTime></tds:SystemDateAndTime></tds:GetSystemDateAndTimeResponse>`, now.Hour(), now.Minute(), now.Second(), now.Year(), now.Month(), now.Day()))
+	case "/onvif/device_service GetCapabilities":
+		s.writeResponse(w, `<tds:GetCapabilitiesResponse><tds:Capabilities>`+
+			`<tt:Events><tt:XAddr>`+onvifStubInternalAddress+`/onvif/events_service</tt:XAddr></tt:Events>`+
+			`<tt:Media><tt:XAddr>`+onvifStubInternalAddress+`/onvif/media_service</tt:XAddr></tt:Media>`+
+			`</tds:Capabilities></tds:GetCapabilitiesResponse>`)
+	case "/onvif/media_service GetProfiles":
+		s.writeResponse(w, `<trt:GetProfilesResponse><trt:Profiles token="main" fixed="true"><tt:Name>mainStream</tt:Name></trt:Profiles>`+
+			`<trt:Profiles token="sub"><tt:Name>subStream</tt:Name></trt:Profiles></trt:GetProfilesResponse>`)
+	case "/onvif/media_service GetSnapshotUri":
+		if !strings.Contains(request.Body.Operation.Content, "main") {
+			s.writeFault(w, "ter:InvalidArgVal", "unexpected profile "+request.Body.Operation.Content)
+			return
+		}
+		s.writeResponse(w, `<trt:GetSnapshotUriResponse><trt:MediaUri><tt:Uri>`+onvifStubInternalAddress+`/snapshot.jpg</tt:Uri>`+
+			`<tt:Timeout>PT0S</tt:Timeout></trt:MediaUri></trt:GetSnapshotUriResponse>`)
+	case "/onvif/events_service CreatePullPointSubscription":
+		s.writeResponse(w, `<tev:CreatePullPointSubscriptionResponse><tev:SubscriptionReference>`+
+			`<wsa5:Address>`+onvifStubInternalAddress+`/onvif/subscription/1</wsa5:Address>`+
+			`</tev:SubscriptionReference></tev:CreatePullPointSubscriptionResponse>`)
+	case "/onvif/subscription/1 PullMessages":
+		s.mux.Lock()
+		s.pulls++
+		isFirst, isMalformed := s.pulls == 1, s.isMalformed
+		s.mux.Unlock()
+		if isMalformed {
+			w.Write([]byte("<s:Envelope><s:Body><tev:PullMessagesResponse>"))
+			return
+		}
+		if !isFirst {
+			// long poll without messages
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			s.writeResponse(w, `<tev:PullMessagesResponse></tev:PullMessagesResponse>`)
+			return
+		}
+		s.writeResponse(w, `<tev:PullMessagesResponse><tev:CurrentTime>2024-05-02T10:15:30Z</tev:CurrentTime>`+
+			`<wsnt:NotificationMessage><wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/CellMotionDetector/Motion</wsnt:Topic>`+
+			`<wsnt:Message><tt:Message UtcTime="2024-05-02T10:15:30.250Z" PropertyOperation="Changed">`+
+			`<tt:Source><tt:SimpleItem Name="VideoSourceConfigurationToken" Value="VideoSourceToken"/><tt:SimpleItem Name="Rule" Value="MyMotionDetectorRule"/></tt:Source>`+
+			`<tt:Data><tt:SimpleItem Name="IsMotion" Value="true"/></tt:Data>`+
+			`</tt:Message></wsnt:Message></wsnt:NotificationMessage></tev:PullMessagesResponse>`)
+	case "/onvif/subscription/1 Unsubscribe":
+		s.writeResponse(w, `<wsnt:UnsubscribeResponse/>`)
+	default:
+		s.writeFault(w, "ter:ActionNotSupported", "unexpected request "+r.URL.Path+" "+operation)
+	}
+}
+
+// verifySecurityHeader checks WS-Security UsernameToken : Base64(SHA1(nonce + created + password)).
+func (s *onvifStub) verifySecurityHeader(request onvifTestRequest) error {
+	security := request.Header.Security
+	if security == nil {
+		return fmt.Errorf("security header is missing")
+	}
+	if security.Username != s.username {
+		return fmt.Errorf("unexpected username %s", security.Username)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(security.Nonce)
+	if err != nil || len(nonce) == 0 {
+		return fmt.Errorf("invalid nonce %q", security.Nonce)
+	}
+	hash := sha1.New()
+	hash.Write(nonce)
+	hash.Write([]byte(security.Created))
+	hash.Write([]byte(s.password))
+	if digest := base64.StdEncoding.EncodeToString(hash.Sum(nil)); digest != security.Password {
+		return fmt.Errorf("invalid password digest")
+	}
+	created, err := time.Parse(time.RFC3339Nano, security.Created)
+	if err != nil {
+		return fmt.Errorf("invalid created time %q", security.Created)
+	}
+	// created time must follow camera clock
+	if skew := created.Sub(time.Now().Add(s.clockSkew)); skew > time.Minute || skew < -time.Minute {
+		return fmt.Errorf("created time %s is out of sync with camera clock", security.Created)
+	}
+	return nil
+}
+
+func (s *onvifStub) writeResponse(w http.ResponseWriter, body string) {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" `+
+		`xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:tds="http://www.onvif.org/ver10/device/wsdl" xmlns:trt="http://www.onvif.org/ver10/media/wsdl" `+
+		`xmlns:tev="http://www.onvif.org/ver10/events/wsdl" xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:wsa5="http://www.w3.org/2005/08/addressing">`+
+		`<s:Body>%s</s:Body></s:Envelope>`, body)
+}
+
+func (s *onvifStub) writeFault(w http.ResponseWriter, subcode, reason string) {
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:ter="http://www.onvif.org/ver10/error">`+
+		`<s:Body><s:Fault><s:Code><s:Value>s:Sender</s:Value><s:Subcode><s:Value>%s</s:Value></s:Subcode></s:Code>`+
+		`<s:Reason><s:Text xml:lang="en">%s</s:Text></s:Reason></s:Fault></s:Body></s:Envelope>`, subcode, xmlEscape(reason))
+}
+
+func (s *onvifStub) countOperation(operation string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	count := 0
+	for _, op := range s.operations {
+		if op == operation {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *onvifStub) request(operation string) onvifTestRequest {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.requests[operation]
+}
+
+func newTestOnvifDriver(t *testing.T, address, password string) *OnvifCameraDriver {
+	driver := NewOnvifCameraDriver().(*OnvifCameraDriver)
+	if err := driver.Configure(address+"/", "admin", password); err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func TestOnvifExtractImage(t *testing.T) {
+	stub, server := newOnvifStub(t)
+	driver := newTestOnvifDriver(t, server.URL, stub.password)
+
+	// concurrent captures share discovered services and snapshot uri
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			img, err := driver.ExtractImage(context.Background())
+			if err == nil && (img.Format != "image/jpeg" || string(img.Body) != "\xff\xd8\xff\xe0jpeg") {
+				err = fmt.Errorf("unexpected image %s %q", img.Format, img.Body)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, operation := range []string{"GetSystemDateAndTime", "GetCapabilities", "GetProfiles", "GetSnapshotUri"} {
+		if stub.countOperation(operation) == 0 {
+			t.Errorf("%s wasn't called", operation)
+		}
+	}
+	if stub.request("GetSystemDateAndTime").Header.Security != nil {
+		t.Error("GetSystemDateAndTime must be sent without security header")
+	}
+	if snapshotURI := driver.snapshotURI; snapshotURI != server.URL+"/snapshot.jpg" {
+		t.Errorf("snapshot uri isn't rebased onto camera address : %s", snapshotURI)
+	}
+
+	// cached snapshot uri is used by the next capture
+	calls := stub.countOperation("GetSnapshotUri")
+	if _, err := driver.ExtractImage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stub.countOperation("GetSnapshotUri") != calls {
+		t.Error("snapshot uri is resolved again")
+	}
+}
+
+func TestOnvifInvalidPassword(t *testing.T) {
+	_, server := newOnvifStub(t)
+	driver := newTestOnvifDriver(t, server.URL, "wrong")
+	_, err := driver.ExtractImage(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "GetCapabilities failed") || !strings.Contains(err.Error(), "NotAuthorized") {
+		t.Fatalf("expected NotAuthorized fault , got %v", err)
+	}
+}
+
+func TestOnvifPullPointSubscription(t *testing.T) {
+	stub, server := newOnvifStub(t)
+	driver := newTestOnvifDriver(t, server.URL, stub.password)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := []EventFilter{{TopicFilter: "tns1:RuleEngine/CellMotionDetector/Motion"}, {ContentFilter: `boolean(//SimpleItem[@Name="IsMotion"])`}}
+	stream, err := driver.SubscribeToEventsStream(ctx, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscribeRequest := stub.request("CreatePullPointSubscription").Body.Operation.Content
+	for _, expected := range []string{"tns1:RuleEngine/CellMotionDetector/Motion</wsnt:TopicExpression>", "boolean(//SimpleItem[@Name=&#34;IsMotion&#34;])", "PT60S"} {
+		if !strings.Contains(subscribeRequest, expected) {
+			t.Errorf("subscription request doesn't contain %s : %s", expected, subscribeRequest)
+		}
+	}
+
+	var event CameraEvent
+	select {
+	case event = <-stream:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event wasn't received")
+	}
+	if event.Type != "Motion" || event.Topic != "tns1:RuleEngine/CellMotionDetector/Motion" || event.State != EventStateActive {
+		t.Errorf("unexpected type %s , topic %s , state %s", event.Type, event.Topic, event.State)
+	}
+	if expected := time.Date(2024, 5, 2, 10, 15, 30, 250000000, time.UTC).UnixMilli(); event.Timestamp != expected {
+		t.Errorf("expected timestamp %d , got %d", expected, event.Timestamp)
+	}
+	if event.Message.Source["VideoSourceConfigurationToken"] != "VideoSourceToken" || event.Message.Source["Rule"] != "MyMotionDetectorRule" || event.Message.Data["IsMotion"] != "true" {
+		t.Errorf("unexpected message %+v", event.Message)
+	}
+	if event.Source != "cam:onvif:"+server.URL {
+		t.Errorf("unexpected source %s", event.Source)
+	}
+
+	pull := stub.request("PullMessages")
+	if pull.Header.Action != "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/PullMessagesRequest" || pull.Header.To != server.URL+"/onvif/subscription/1" {
+		t.Errorf("unexpected WS-Addressing headers , action %s , to %s", pull.Header.Action, pull.Header.To)
+	}
+
+	cancel()
+	deadline := time.After(5 * time.Second)
+	for isOpen := true; isOpen; {
+		select {
+		case _, isOpen = <-stream:
+		case <-deadline:
+			t.Fatal("events channel wasn't closed after cancel")
+		}
+	}
+	if stub.countOperation("Unsubscribe") != 1 {
+		t.Error("subscription wasn't terminated")
+	}
+}
+
+func TestOnvifPullMessagesMalformedResponse(t *testing.T) {
+	stub, server := newOnvifStub(t)
+	stub.isMalformed = true
+	driver := newTestOnvifDriver(t, server.URL, stub.password)
+	start := time.Now()
+	stream, err := driver.SubscribeToEventsStream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(10 * time.Second)
+	for isOpen := true; isOpen; {
+		select {
+		case _, isOpen = <-stream:
+		case <-deadline:
+			t.Fatal("events channel wasn't closed after consecutive failures")
+		}
+	}
+	// failed pulls are retried after 1s and 2s , the stream is closed after the 3rd failure
+	if elapsed := time.Since(start); elapsed < 3*onvifPullRetryDelay {
+		t.Errorf("malformed responses are retried without backoff , stream closed after %s", elapsed)
+	}
+	if pulls := stub.countOperation("PullMessages"); pulls != onvifMaxPullFailures {
+		t.Errorf("expected %d PullMessages requests , got %d", onvifMaxPullFailures, pulls)
+	}
+}
+
+func TestBuildOnvifEventFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventFilter
+		expected []string
+	}{
+		{name: "no filters"},
+		{name: "topics", filters: []EventFilter{{TopicFilter: "tns1:VideoSource//."}, {TopicFilter: "tns1:Device/Trigger"}}, expected: []string{">tns1:VideoSource//.|tns1:Device/Trigger</wsnt:TopicExpression>"}},
+		{name: "content", filters: []EventFilter{{ContentFilter: "a"}, {ContentFilter: "b"}}, expected: []string{">a or b</wsnt:MessageContent>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := buildOnvifEventFilter(tt.filters)
+			if len(tt.expected) == 0 && filter != "" {
+				t.Fatalf("expected empty filter , got %s", filter)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(filter, expected) {
+					t.Errorf("filter doesn't contain %s : %s", expected, filter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package camera
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DriverCapabilities describes optional features implemented by a driver. Capabilities backed by optional interfaces
+// are detected automatically during registration.
+type DriverCapabilities struct {
+	Events       bool // EventStreamer is implemented
+	Metadata     bool // MetadataExtractor is implemented (camera+metadata mode)
+	Measurements bool // MeasurementsExtractor is implemented
+	Manifests    bool // ManifestProvider is implemented
+	PTZ          bool // PtzController is implemented , camera can be moved between presets
+	Commit       bool // Committer is implemented
+}
+
+// detectCapabilities sets capability flags based on optional interfaces implemented by the driver.
+func detectCapabilities(driver Driver, capabilities *DriverCapabilities) {
+	_, capabilities.Events = driver.(EventStreamer)
+	_, capabilities.Metadata = driver.(MetadataExtractor)
+	_, capabilities.Measurements = driver.(MeasurementsExtractor)
+	_, capabilities.Manifests = driver.(ManifestProvider)
+	_, capabilities.PTZ = driver.(PtzController)
+	_, capabilities.Commit = driver.(Committer)
+}
+
+// DriverOptionInfo describes one driver specific option that can be set in CameraConfig.DriverOptions.
+type DriverOptionInfo struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// DriverInfo is self-describing driver metadata. It is used to validate camera configurations before processors are started
+// and to list available drivers.
+type DriverInfo struct {
+	Name           string // set by Register
+	Description    string
+	Capabilities   DriverCapabilities
+	RequiredFields []string // camera config fields that must be set , supported values : Address, Username, Password
+	Options        []DriverOptionInfo
+}
+
+type registeredDriver struct {
+	constructor DriverConstructor
+	info        DriverInfo
+}
+
+var (
+	driversMux sync.RWMutex
+	drivers    = make(map[string]registeredDriver)
+)
+
+// Register makes driver available by name (camera model). Drivers register themselves from init functions.
+// Constructor is called once to detect optional capabilities , so it must not have side effects.
+// Register panics if the same name is registered twice or constructor is nil.
+func Register(name string, constructor DriverConstructor, info DriverInfo) {
+	driversMux.Lock()
+	defer driversMux.Unlock()
+	if constructor == nil {
+		panic("camera: Register driver constructor is nil for " + name)
+	}
+	if _, exists := drivers[name]; exists {
+		panic("camera: Register called twice for driver " + name)
+	}
+	info.Name = name
+	detectCapabilities(constructor(), &info.Capabilities)
+	drivers[name] = registeredDriver{constructor: constructor, info: info}
+}
+
+// NewDriver creates new driver instance for camera model. Returns error with list of available models if the model is unknown.
+func NewDriver(name string) (Driver, error) {
+	driversMux.RLock()
+	reg, ok := drivers[name]
+	driversMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown camera model %q , available models : %s", name, strings.Join(DriverNames(), ", "))
+	}
+	return reg.constructor(), nil
+}
+
+// GetDriverInfo returns metadata of registered driver.
+func GetDriverInfo(name string) (DriverInfo, bool) {
+	driversMux.RLock()
+	defer driversMux.RUnlock()
+	reg, ok := drivers[name]
+	return reg.info, ok
+}
+
+// DriverNames returns sorted list of registered driver names.
+func DriverNames() []string {
+	driversMux.RLock()
+	defer driversMux.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListDrivers returns metadata of all registered drivers sorted by name.
+func ListDrivers() []DriverInfo {
+	names := DriverNames()
+	driversMux.RLock()
+	defer driversMux.RUnlock()
+	infos := make([]DriverInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, drivers[name].info)
+	}
+	return infos
+}
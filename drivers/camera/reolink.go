@@ -1,39 +1,252 @@
 package camera
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// docs : https://support.reolink.com/hc/en-us/articles/900000625763-Introduction-to-CGI-API
+
+const (
+	reolinkApiPath             = "/cgi-bin/api.cgi"
+	reolinkRspCodeLoginFirst   = -6 // token is missing or expired
+	reolinkTokenRefreshMargin  = 60 * time.Second
+	reolinkDefaultPollInterval = 1 * time.Second
+)
+
+var errReolinkLoginRequired = errors.New("reolink api token is invalid")
+
 type ReolinkCameraDriver struct {
-	httpClient http.Client
-	address    string
-	username   string
-	password   string
+	httpClient   http.Client
+	address      string
+	username     string
+	password     string
+	channel      int
+	pollInterval time.Duration // event state polling interval
+	aiEvents     bool          // poll AI detection state in addition to motion detection state
+
+	mux         sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+type reolinkCommand struct {
+	Cmd    string      `json:"cmd"`
+	Action int         `json:"action"`
+	Param  interface{} `json:"param"`
+}
+
+type reolinkResponse struct {
+	Cmd   string          `json:"cmd"`
+	Code  int             `json:"code"`
+	Value json.RawMessage `json:"value"`
+	Error *struct {
+		Detail  string `json:"detail"`
+		RspCode int    `json:"rspCode"`
+	} `json:"error"`
+}
+
+func init() {
+	Register("reolink", NewReolinkCameraDriver, DriverInfo{
+		Description:    "Reolink cameras (JSON API)",
+		RequiredFields: []string{"Address", "Username", "Password"},
+		Options: []DriverOptionInfo{
+			{Name: "channel", Description: "camera channel , used by NVRs and multi-lens cameras", Default: "0"},
+			{Name: "eventPollInterval", Description: "motion and AI detection state polling interval in seconds", Default: "1"},
+			{Name: "aiEvents", Description: "poll AI detection state (people , vehicle , dog_cat , face) in addition to motion detection", Default: "true"},
+		},
+	})
 }
 
 func NewReolinkCameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
 	}
-	return &ReolinkCameraDriver{httpClient: httpClient}
+	return &ReolinkCameraDriver{httpClient: httpClient, pollInterval: reolinkDefaultPollInterval, aiEvents: true}
 }
 
+// Configure accepts camera base address , for example http://10.0.0.5 . Full snapshot URL used by older versions of the driver
+// is accepted as well , the path and credentials are dropped and the channel is taken from the URL.
 func (cam *ReolinkCameraDriver) Configure(address, username, password string) error {
-	cam.address = address
+	parsedAddress, err := url.Parse(address)
+	if err != nil || parsedAddress.Host == "" {
+		return fmt.Errorf("invalid camera address %s", address)
+	}
+	if channel, err := strconv.Atoi(parsedAddress.Query().Get("channel")); err == nil {
+		cam.channel = channel
+	}
+	if parsedAddress.Path != "" && parsedAddress.Path != "/" {
+		log.Infof("Reolink camera address should be base address , path %s is ignored", parsedAddress.Path)
+	}
+	cam.address = parsedAddress.Scheme + "://" + parsedAddress.Host
 	cam.username = username
 	cam.password = password
+	cam.invalidateToken()
 	return nil
 }
 
-func (cam *ReolinkCameraDriver) ExtractImage() (*Image, error) {
+func (cam *ReolinkCameraDriver) ConfigureOptions(options map[string]string) error {
+	if value, ok := options["channel"]; ok {
+		channel, err := strconv.Atoi(value)
+		if err != nil || channel < 0 {
+			return fmt.Errorf("invalid channel value %s", value)
+		}
+		cam.channel = channel
+	}
+	if value, ok := options["eventPollInterval"]; ok {
+		interval, err := strconv.ParseFloat(value, 64)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid eventPollInterval value %s", value)
+		}
+		cam.pollInterval = time.Duration(interval * float64(time.Second))
+	}
+	if value, ok := options["aiEvents"]; ok {
+		aiEvents, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid aiEvents value %s", value)
+		}
+		cam.aiEvents = aiEvents
+	}
+	return nil
+}
+
+// getToken returns valid API token , new token is requested if the current one is missing or about to expire.
+func (cam *ReolinkCameraDriver) getToken(ctx context.Context) (string, error) {
+	cam.mux.Lock()
+	defer cam.mux.Unlock()
+	if cam.token != "" && time.Now().Before(cam.tokenExpiry) {
+		return cam.token, nil
+	}
+	login := reolinkCommand{Cmd: "Login", Param: map[string]interface{}{
+		"User": map[string]string{"Version": "0", "userName": cam.username, "password": cam.password},
+	}}
+	value, err := cam.post(ctx, "Login", "", login)
+	if err != nil {
+		return "", fmt.Errorf("login failed : %w", err)
+	}
+	var loginValue struct {
+		Token struct {
+			Name      string `json:"name"`
+			LeaseTime int    `json:"leaseTime"`
+		} `json:"Token"`
+	}
+	if err := json.Unmarshal(value, &loginValue); err != nil || loginValue.Token.Name == "" {
+		return "", fmt.Errorf("login failed , camera didn't return token")
+	}
+	cam.token = loginValue.Token.Name
+	cam.tokenExpiry = time.Now().Add(time.Duration(loginValue.Token.LeaseTime)*time.Second - reolinkTokenRefreshMargin)
+	log.Debugf("Reolink camera %s login succeeded , token lease time %d s", cam.address, loginValue.Token.LeaseTime)
+	return cam.token, nil
+}
+
+func (cam *ReolinkCameraDriver) invalidateToken() {
+	cam.mux.Lock()
+	cam.token = ""
+	cam.mux.Unlock()
+}
 
-	address := fmt.Sprintf("%s&user=%s&password=%s", cam.address, cam.username, cam.password)
-	resp, err := cam.httpClient.Get(address)
+// callApi executes API command and returns command value. The command is retried once with a new token if the token has expired.
+func (cam *ReolinkCameraDriver) callApi(ctx context.Context, cmd string, param interface{}) (json.RawMessage, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := cam.getToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value, err := cam.post(ctx, cmd, token, reolinkCommand{Cmd: cmd, Param: param})
+		if errors.Is(err, errReolinkLoginRequired) && attempt == 0 {
+			cam.invalidateToken()
+			continue
+		}
+		return value, err
+	}
+}
+
+func (cam *ReolinkCameraDriver) post(ctx context.Context, cmd, token string, command reolinkCommand) (json.RawMessage, error) {
+	query := url.Values{"cmd": {cmd}}
+	if token != "" {
+		query.Set("token", token)
+	}
+	body, err := json.Marshal([]reolinkCommand{command})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", cam.address+reolinkApiPath+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cam.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("camera api %s returned error code %s", cmd, resp.Status)
+	}
+	return parseReolinkResponse(cmd, respBody)
+}
+
+func parseReolinkResponse(cmd string, body []byte) (json.RawMessage, error) {
+	var responses []reolinkResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response : %w", cmd, err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("camera returned empty %s response", cmd)
+	}
+	response := responses[0]
+	if response.Code != 0 || response.Error != nil {
+		if response.Error == nil {
+			return nil, fmt.Errorf("camera api %s returned error code %d", cmd, response.Code)
+		}
+		if response.Error.RspCode == reolinkRspCodeLoginFirst {
+			return nil, errReolinkLoginRequired
+		}
+		return nil, fmt.Errorf("camera api %s returned error %s (rspCode %d)", cmd, response.Error.Detail, response.Error.RspCode)
+	}
+	return response.Value, nil
+}
+
+func (cam *ReolinkCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
+	for attempt := 0; ; attempt++ {
+		img, err := cam.snap(ctx)
+		if errors.Is(err, errReolinkLoginRequired) && attempt == 0 {
+			cam.invalidateToken()
+			continue
+		}
+		return img, err
+	}
+}
+
+func (cam *ReolinkCameraDriver) snap(ctx context.Context) (*Image, error) {
+	token, err := cam.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// rs is a random string that prevents caching of snapshots
+	query := url.Values{"cmd": {"Snap"}, "channel": {strconv.Itoa(cam.channel)}, "rs": {strconv.FormatInt(rand.Int63(), 36)}, "token": {token}}
+	req, err := http.NewRequestWithContext(ctx, "GET", cam.address+reolinkApiPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cam.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -43,42 +256,189 @@ func (cam *ReolinkCameraDriver) ExtractImage() (*Image, error) {
 		return nil, fmt.Errorf("camera api returned error code %s", resp.Status)
 	}
 	body, err := io.ReadAll(resp.Body)
-
 	if err != nil {
 		return nil, err
 	}
 
 	contentType := resp.Header.Get("Content-Type")
-
-	if contentType != "image/jpeg" {
+	if !strings.Contains(contentType, "image/jpeg") {
+		// errors are returned as JSON documents
+		if _, err := parseReolinkResponse("Snap", body); err != nil {
+			return nil, err
+		}
 		log.Errorf("Incompatable content type %s from camera API", contentType)
 		return nil, fmt.Errorf("incompatible content type %s", contentType)
 	}
-
-	img := Image{Body: body, Format: "image/jpeg"}
-
-	return &img, nil
+	return &Image{Body: body, Format: "image/jpeg"}, nil
 }
 
-func (cam *ReolinkCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
+// GetCameraCapabilitiesManifest returns device information (GetDevInfo) and user abilities (GetAbility) as JSON documents.
+// Supported component names are devinfo , ability and all.
+func (cam *ReolinkCameraDriver) GetCameraCapabilitiesManifest(componentName string) ([]CameraCapabilitiesManifest, error) {
+	requests := []struct {
+		component string
+		name      string
+		cmd       string
+		param     interface{}
+	}{
+		{"devinfo", "devinfo.json", "GetDevInfo", map[string]interface{}{}},
+		{"ability", "ability.json", "GetAbility", map[string]interface{}{"User": map[string]string{"userName": cam.username}}},
+	}
+	ctx := context.Background()
+	var manifests []CameraCapabilitiesManifest
+	for _, r := range requests {
+		if componentName != "all" && componentName != r.component {
+			continue
+		}
+		value, err := cam.callApi(ctx, r.cmd, r.param)
+		if err != nil {
+			log.Infof("Reolink manifest component %s can't be retrieved. Err: %s", r.component, err.Error())
+			continue
+		}
+		manifests = append(manifests, CameraCapabilitiesManifest{
+			Name:          r.name,
+			Format:        "json",
+			ComponentName: r.component,
+			Body:          value,
+			IsRaw:         true,
+		})
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("camera didn't return manifest component %s", componentName)
+	}
+	return manifests, nil
 }
 
-func (cam *ReolinkCameraDriver) Ping(address string) bool {
-	return true
+// SubscribeToEventsStream polls motion detection state (GetMdState) and AI detection state (GetAiState) every eventPollInterval
+// and publishes event on each state change. Event type is motion or AI detection type (people , vehicle , dog_cat , face) ,
+// topic is <type>/<channel> , for example motion/0 , and State is active or inactive. Detections that are active on the first poll are published as active.
+// Filters are applied using MatchEventFilters , ContentFilter is matched against the JSON state document.
+// The channel is closed when the camera can't be polled or ctx is cancelled.
+func (cam *ReolinkCameraDriver) SubscribeToEventsStream(ctx context.Context, eventFilters []EventFilter) (chan CameraEvent, error) {
+	param := map[string]int{"channel": cam.channel}
+	if _, err := cam.callApi(ctx, "GetMdState", param); err != nil {
+		return nil, err
+	}
+	log.Info("Subscribed to Reolink camera events. Camera address : ", cam.address)
+	messages := make(chan CameraEvent, 10)
+	go cam.pollEventsLoop(ctx, eventFilters, messages)
+	return messages, nil
 }
 
-func (cam *ReolinkCameraDriver) Commit(transactionId string) error {
-	return nil
+func (cam *ReolinkCameraDriver) pollEventsLoop(ctx context.Context, eventFilters []EventFilter, messages chan CameraEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Info("Recovered from panic:", r)
+		}
+		close(messages)
+		log.Info("Disconnected from Reolink camera events.")
+	}()
+	source := "cam:reolink:" + cam.address
+	param := map[string]int{"channel": cam.channel}
+	isAiSupported := cam.aiEvents
+	states := map[string]bool{}
+	for {
+		detections := map[string]bool{}
+		value, err := cam.callApi(ctx, "GetMdState", param)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error("Reolink GetMdState failed: ", err)
+			return
+		}
+		var mdState struct {
+			State int `json:"state"`
+		}
+		if err := json.Unmarshal(value, &mdState); err != nil {
+			log.Error("Error parsing Reolink GetMdState response: ", err)
+			return
+		}
+		detections["motion"] = mdState.State == 1
+		rawData := map[string]json.RawMessage{"motion": value}
+
+		if isAiSupported {
+			aiValue, err := cam.callApi(ctx, "GetAiState", param)
+			if ctx.Err() != nil {
+				return
+			}
+			aiStates, parseErr := parseReolinkAiState(aiValue)
+			if err != nil || parseErr != nil {
+				log.Info("Reolink AI detection state isn't available , only motion detection events are published. Err: ", errors.Join(err, parseErr))
+				isAiSupported = false
+			}
+			for aiType, isActive := range aiStates {
+				detections[aiType] = isActive
+				rawData[aiType] = aiValue
+			}
+		}
+
+		timestamp := time.Now().UnixMilli()
+		for detectionType, isActive := range detections {
+			previousState, isKnown := states[detectionType]
+			states[detectionType] = isActive
+			if (isKnown && previousState == isActive) || (!isKnown && !isActive) {
+				continue
+			}
+			state := EventStateInactive
+			if isActive {
+				state = EventStateActive
+			}
+			topic := fmt.Sprintf("%s/%d", detectionType, cam.channel)
+			if !MatchEventFilters(eventFilters, topic, rawData[detectionType]) {
+				continue
+			}
+			select {
+			case messages <- CameraEvent{CoreType: "notification", Type: detectionType, Topic: topic, Source: source, Timestamp: timestamp, RawData: rawData[detectionType], State: state}:
+			default:
+				log.Info("Channel is full, message not sent")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cam.pollInterval):
+		}
+	}
 }
 
-func (cam *ReolinkCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, fmt.Errorf("reolink camera does not support event streams")
+// parseReolinkAiState returns alarm state of each AI detection type supported by the camera. GetAiState value has format
+// {"channel":0,"people":{"alarm_state":0,"support":1},"vehicle":{"alarm_state":1,"support":1}} .
+func parseReolinkAiState(value json.RawMessage) (map[string]bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, err
+	}
+	aiStates := map[string]bool{}
+	for aiType, field := range fields {
+		var aiState struct {
+			AlarmState int `json:"alarm_state"`
+			Support    int `json:"support"`
+		}
+		if err := json.Unmarshal(field, &aiState); err != nil || aiState.Support != 1 {
+			continue
+		}
+		aiStates[aiType] = aiState.AlarmState == 1
+	}
+	return aiStates, nil
 }
 
-func (cam *ReolinkCameraDriver) Close() {
+func (cam *ReolinkCameraDriver) Ping(address string) bool {
+	return true
 }
 
-func (cam *ReolinkCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
+// Close releases API token.
+func (cam *ReolinkCameraDriver) Close() {
+	cam.mux.Lock()
+	token := cam.token
+	cam.token = ""
+	cam.mux.Unlock()
+	if token == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cam.post(ctx, "Logout", token, reolinkCommand{Cmd: "Logout", Param: map[string]interface{}{}}); err != nil {
+		log.Debug("Reolink logout failed: ", err)
+	}
 }
@@ -0,0 +1,246 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/pkg/ffmpeg"
+	log "github.com/sirupsen/logrus"
+)
+
+// RtspCameraDriver keeps persistent ffmpeg session per camera and returns the latest decoded frame encoded as JPEG.
+// Requires ffmpeg to be installed and available in PATH.
+// Supported options :
+//   - transport : udp or tcp (default udp)
+//   - fps : how many frames per second ffmpeg decodes into the frame buffer (default 1)
+//   - jpegQuality : JPEG quality 1-100 (default 90)
+type RtspCameraDriver struct {
+	address        string
+	username       string
+	password       string
+	transport      string
+	frameRate      string
+	jpegQuality    int
+	session        *ffmpeg.RTSPCamera
+	latestFrame    []byte
+	width          int
+	height         int
+	frameTime      time.Time
+	maxFrameAge    time.Duration // the stream is considered stalled if no frame is received within this time
+	nextConnectAt  time.Time
+	reconnectDelay time.Duration
+	isClosed       bool
+	mux            sync.Mutex
+	connectMux     sync.Mutex // serializes connection attempts , ffmpeg probe runs without mux locked
+}
+
+const (
+	rtspMinReconnectDelay = 5 * time.Second
+	rtspMaxReconnectDelay = 5 * time.Minute
+	rtspFirstFrameTimeout = 15 * time.Second
+	rtspConnectTimeout    = 20 * time.Second // max duration of ffmpeg stream probe
+	rtspMinMaxFrameAge    = 60 * time.Second
+	rtspMaxFrameAgeFrames = 3 // the stream is stalled if this many frame periods passed without a frame
+)
+
+func init() {
+	Register("rtsp", NewRtspCameraDriver, DriverInfo{
+		Description:    "RTSP stream decoded by ffmpeg (ffmpeg must be installed)",
+		RequiredFields: []string{"Address"},
+		Options: []DriverOptionInfo{
+			{Name: "transport", Description: "RTSP transport , tcp or udp", Default: "udp"},
+			{Name: "fps", Description: "frames per second decoded from the stream", Default: "1"},
+			{Name: "jpegQuality", Description: "JPEG quality of captured images (1-100)", Default: "90"},
+		},
+	})
+}
+
+func NewRtspCameraDriver() Driver {
+	return &RtspCameraDriver{transport: "udp", frameRate: "1", jpegQuality: 90, reconnectDelay: rtspMinReconnectDelay, maxFrameAge: rtspMinMaxFrameAge}
+}
+
+func (cam *RtspCameraDriver) Configure(address, username, password string) error {
+	cam.address = address
+	cam.username = username
+	cam.password = password
+	return nil
+}
+
+func (cam *RtspCameraDriver) ConfigureOptions(options map[string]string) error {
+	if transport, ok := options["transport"]; ok {
+		if transport != "tcp" && transport != "udp" {
+			return fmt.Errorf("unsupported rtsp transport %s , supported values : tcp, udp", transport)
+		}
+		cam.transport = transport
+	}
+	if fps, ok := options["fps"]; ok {
+		v, err := strconv.ParseFloat(fps, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid fps value %s", fps)
+		}
+		cam.frameRate = fps
+		cam.maxFrameAge = max(rtspMinMaxFrameAge, time.Duration(rtspMaxFrameAgeFrames*float64(time.Second)/v))
+	}
+	if quality, ok := options["jpegQuality"]; ok {
+		v, err := strconv.Atoi(quality)
+		if err != nil || v < 1 || v > 100 {
+			return fmt.Errorf("invalid jpegQuality value %s", quality)
+		}
+		cam.jpegQuality = v
+	}
+	return nil
+}
+
+// connect starts new ffmpeg session and frame reader loop if the stream is not connected. The stream is probed without
+// mux locked , so a silent server doesn't block readLoop and Close , and the probe is cancelled with ctx.
+func (cam *RtspCameraDriver) connect(ctx context.Context) error {
+	cam.connectMux.Lock()
+	defer cam.connectMux.Unlock()
+
+	cam.mux.Lock()
+	switch {
+	case cam.isClosed:
+		cam.mux.Unlock()
+		return fmt.Errorf("camera driver is closed")
+	case cam.session != nil:
+		cam.mux.Unlock()
+		return nil
+	case time.Now().Before(cam.nextConnectAt):
+		nextConnectAt := cam.nextConnectAt
+		cam.mux.Unlock()
+		return fmt.Errorf("rtsp stream is not connected , next reconnect attempt in %s", time.Until(nextConnectAt).Round(time.Second))
+	}
+	cam.mux.Unlock()
+
+	log.Infof("Connecting to rtsp stream %s over %s", cam.address, cam.transport)
+	options := ffmpeg.RTSPOptions{Transport: cam.transport, FrameRate: cam.frameRate, Timeout: rtspConnectTimeout}
+	session, err := ffmpeg.NewRtspCameraWithContext(ctx, cam.address, cam.username, cam.password, cam.address, options)
+	if err == nil {
+		err = session.InitCamera()
+	}
+
+	cam.mux.Lock()
+	if err != nil {
+		if ctx.Err() == nil {
+			cam.scheduleReconnect()
+		}
+		cam.mux.Unlock()
+		return fmt.Errorf("failed to connect to rtsp stream: %w", err)
+	}
+	if cam.isClosed {
+		cam.mux.Unlock()
+		session.Close()
+		return fmt.Errorf("camera driver is closed")
+	}
+	cam.session = session
+	cam.width = session.Camera().Width()
+	cam.height = session.Camera().Height()
+	cam.latestFrame = nil
+	cam.mux.Unlock()
+	go cam.readLoop(session)
+	return nil
+}
+
+// scheduleReconnect applies exponential backoff to reconnect attempts. Must be called with mux locked.
+func (cam *RtspCameraDriver) scheduleReconnect() {
+	cam.nextConnectAt = time.Now().Add(cam.reconnectDelay)
+	cam.reconnectDelay *= 2
+	if cam.reconnectDelay > rtspMaxReconnectDelay {
+		cam.reconnectDelay = rtspMaxReconnectDelay
+	}
+}
+
+// readLoop continuously reads decoded frames from ffmpeg and keeps the latest one.
+func (cam *RtspCameraDriver) readLoop(session *ffmpeg.RTSPCamera) {
+	for session.Read() {
+		frame := session.Camera().FrameBuffer()
+		cam.mux.Lock()
+		if cam.session != session {
+			cam.mux.Unlock()
+			break
+		}
+		if len(cam.latestFrame) != len(frame) {
+			cam.latestFrame = make([]byte, len(frame))
+		}
+		copy(cam.latestFrame, frame)
+		cam.frameTime = time.Now()
+		cam.reconnectDelay = rtspMinReconnectDelay
+		cam.mux.Unlock()
+	}
+	session.Close()
+	cam.mux.Lock()
+	if cam.session == session {
+		log.Infof("rtsp stream %s has been disconnected", cam.address)
+		cam.session = nil
+		if !cam.isClosed {
+			cam.scheduleReconnect()
+		}
+	}
+	cam.mux.Unlock()
+}
+
+func (cam *RtspCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
+	if err := cam.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	// Waiting for the first frame after (re)connect
+	deadline := time.Now().Add(rtspFirstFrameTimeout)
+	for {
+		cam.mux.Lock()
+		if cam.latestFrame != nil {
+			break
+		}
+		isConnected := cam.session != nil
+		cam.mux.Unlock()
+		if !isConnected {
+			return nil, fmt.Errorf("rtsp stream has been disconnected")
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("no frames received from rtsp stream within %s", rtspFirstFrameTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	if time.Since(cam.frameTime) > cam.maxFrameAge {
+		// stream is stalled , killing ffmpeg session , read loop will schedule reconnect
+		log.Infof("rtsp stream %s is stalled , last frame received %s ago", cam.address, time.Since(cam.frameTime).Round(time.Second))
+		session := cam.session
+		cam.mux.Unlock()
+		if session != nil {
+			session.Close()
+		}
+		return nil, fmt.Errorf("rtsp stream is stalled")
+	}
+	defer cam.mux.Unlock()
+
+	var buf bytes.Buffer
+	if err := ffmpeg.EncodeJPEG(&buf, cam.width, cam.height, cam.latestFrame, cam.jpegQuality); err != nil {
+		return nil, err
+	}
+	img := Image{Body: buf.Bytes(), Format: "image/jpeg"}
+	return &img, nil
+}
+
+func (cam *RtspCameraDriver) Ping(address string) bool {
+	return true
+}
+
+func (cam *RtspCameraDriver) Close() {
+	cam.mux.Lock()
+	cam.isClosed = true
+	session := cam.session
+	cam.session = nil
+	cam.mux.Unlock()
+	if session != nil {
+		log.Info("Stopping rtsp camera driver")
+		session.Close()
+	}
+}
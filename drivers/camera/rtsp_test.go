@@ -0,0 +1,351 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"image/jpeg"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/pkg/ffmpeg"
+)
+
+const (
+	rtspTestWidth    = 160
+	rtspTestHeight   = 120
+	rtspTestPassword = "rtsp-test-secret"
+)
+
+// requireFfmpeg skips the test if ffmpeg isn't installed.
+func requireFfmpeg(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	return path
+}
+
+// rtspTestServer serves looped test video over RTSP with ffmpeg in listen mode. ffmpeg serves a single client per process ,
+// so the server is started again every time the client disconnects , until it's stopped.
+type rtspTestServer struct {
+	t       *testing.T
+	ffmpeg  string
+	source  string
+	address string
+	mux     sync.Mutex
+	cmd     *exec.Cmd
+	running bool
+	done    chan struct{}
+}
+
+func newRtspTestServer(t *testing.T) *rtspTestServer {
+	ffmpegPath := requireFfmpeg(t)
+	source := filepath.Join(t.TempDir(), "source.mp4")
+	output, err := exec.Command(ffmpegPath, "-hide_banner", "-loglevel", "error", "-f", "lavfi", "-i", "testsrc=size=160x120:rate=10",
+		"-t", "2", "-pix_fmt", "yuv420p", "-c:v", "mpeg4", source).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to generate test video : %s %s", err, output)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	server := &rtspTestServer{t: t, ffmpeg: ffmpegPath, source: source, address: "rtsp://127.0.0.1:" + strconv.Itoa(port) + "/live"}
+	server.start()
+	t.Cleanup(server.stop)
+	return server
+}
+
+func (s *rtspTestServer) start() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.done = make(chan struct{})
+	go s.serve(s.done)
+}
+
+func (s *rtspTestServer) serve(done chan struct{}) {
+	defer close(done)
+	for {
+		s.mux.Lock()
+		if !s.running {
+			s.mux.Unlock()
+			return
+		}
+		cmd := exec.Command(s.ffmpeg, "-hide_banner", "-loglevel", "error", "-re", "-stream_loop", "-1", "-i", s.source,
+			"-c", "copy", "-f", "rtsp", "-rtsp_transport", "tcp", "-rtsp_flags", "listen", s.address)
+		if err := cmd.Start(); err != nil {
+			s.mux.Unlock()
+			s.t.Errorf("failed to start rtsp server : %s", err)
+			return
+		}
+		s.cmd = cmd
+		s.mux.Unlock()
+		cmd.Wait()
+	}
+}
+
+// stop kills the server and waits until it's not listening anymore.
+func (s *rtspTestServer) stop() {
+	s.mux.Lock()
+	if !s.running {
+		s.mux.Unlock()
+		return
+	}
+	s.running = false
+	if s.cmd != nil && s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	done := s.done
+	s.mux.Unlock()
+	<-done
+}
+
+func newTestRtspDriver(t *testing.T, address string) *RtspCameraDriver {
+	cam := NewRtspCameraDriver().(*RtspCameraDriver)
+	if err := cam.Configure(address, "admin", rtspTestPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := cam.ConfigureOptions(map[string]string{"transport": "tcp", "fps": "5"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cam.Close)
+	return cam
+}
+
+// resetReconnectBackoff allows the next connection attempt right away.
+func (cam *RtspCameraDriver) resetReconnectBackoff() {
+	cam.mux.Lock()
+	cam.nextConnectAt = time.Time{}
+	cam.mux.Unlock()
+}
+
+// extractTestImage extracts image from the driver. The test server is restarted after every client , so a connection
+// attempt may be refused while the server is starting , such attempts are retried without backoff.
+func extractTestImage(t *testing.T, cam *RtspCameraDriver) *Image {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		img, err := cam.ExtractImage(ctx)
+		cancel()
+		if err == nil {
+			return img
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to extract image : %s", err)
+		}
+		cam.resetReconnectBackoff()
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func assertTestFrame(t *testing.T, img *Image) {
+	t.Helper()
+	if img.Format != "image/jpeg" {
+		t.Fatalf("unexpected image format %s", img.Format)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() != rtspTestWidth || bounds.Dy() != rtspTestHeight {
+		t.Fatalf("expected %dx%d frame , got %dx%d", rtspTestWidth, rtspTestHeight, bounds.Dx(), bounds.Dy())
+	}
+}
+
+// processesWithArgument returns command lines of running processes that contain arg.
+func processesWithArgument(arg string) []string {
+	cmdlines, _ := filepath.Glob("/proc/[0-9]*/cmdline")
+	var matches []string
+	for _, path := range cmdlines {
+		cmdline, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(cmdline, []byte(arg)) {
+			matches = append(matches, string(bytes.ReplaceAll(cmdline, []byte{0}, []byte(" "))))
+		}
+	}
+	return matches
+}
+
+func TestRtspCapture(t *testing.T) {
+	server := newRtspTestServer(t)
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	cam := newTestRtspDriver(t, server.address)
+
+	assertTestFrame(t, extractTestImage(t, cam))
+	assertTestFrame(t, extractTestImage(t, cam))
+
+	// credentials are passed to ffmpeg in private ffconcat file , the file is removed once the stream is running
+	if _, err := os.Stat("/proc/self/cmdline"); err == nil {
+		if processes := processesWithArgument(rtspTestPassword); len(processes) != 0 {
+			t.Errorf("password is exposed in process list : %v", processes)
+		}
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("ffconcat file with credentials wasn't removed : %d entries in temp dir", len(entries))
+	}
+}
+
+func TestRtspReconnectAfterServerRestart(t *testing.T) {
+	server := newRtspTestServer(t)
+	cam := newTestRtspDriver(t, server.address)
+	assertTestFrame(t, extractTestImage(t, cam))
+
+	server.stop()
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		cam.mux.Lock()
+		isConnected := cam.session != nil
+		reconnectDelay, nextConnectAt := cam.reconnectDelay, cam.nextConnectAt
+		cam.mux.Unlock()
+		if !isConnected {
+			if reconnectDelay != 2*rtspMinReconnectDelay || !nextConnectAt.After(time.Now()) {
+				t.Fatalf("reconnect backoff isn't applied after disconnect , delay %s , next attempt %s", reconnectDelay, nextConnectAt)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("driver didn't detect server shutdown")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := cam.ExtractImage(context.Background()); err == nil || !strings.Contains(err.Error(), "next reconnect attempt") {
+		t.Fatalf("expected backoff error , got %v", err)
+	}
+
+	server.start()
+	cam.resetReconnectBackoff()
+	assertTestFrame(t, extractTestImage(t, cam))
+	cam.mux.Lock()
+	defer cam.mux.Unlock()
+	if cam.reconnectDelay != rtspMinReconnectDelay {
+		t.Errorf("reconnect delay isn't reset after frame is received , got %s", cam.reconnectDelay)
+	}
+}
+
+func TestRtspProbeTimeout(t *testing.T) {
+	requireFfmpeg(t)
+	// listener accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	var conns []net.Conn
+	var connsMux sync.Mutex
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connsMux.Lock()
+			conns = append(conns, conn)
+			connsMux.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		connsMux.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		connsMux.Unlock()
+	})
+	address := "rtsp://" + listener.Addr().String() + "/live"
+
+	t.Run("option", func(t *testing.T) {
+		start := time.Now()
+		_, err := ffmpeg.NewRtspCameraWithOptions("silent", "", "", address, ffmpeg.RTSPOptions{Transport: "tcp", Timeout: time.Second})
+		if err == nil {
+			t.Fatal("expected probe error")
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Fatalf("probe wasn't killed after timeout , took %s", elapsed)
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		cam := newTestRtspDriver(t, address)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		start := time.Now()
+		if _, err := cam.ExtractImage(ctx); err == nil {
+			t.Fatal("expected probe error")
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Fatalf("probe wasn't cancelled with context , took %s", elapsed)
+		}
+		// cancelled attempt isn't counted as a failure of the camera
+		cam.mux.Lock()
+		defer cam.mux.Unlock()
+		if !cam.nextConnectAt.IsZero() {
+			t.Errorf("reconnect backoff is applied after cancelled probe")
+		}
+	})
+}
+
+func TestRtspReconnectBackoff(t *testing.T) {
+	cam := NewRtspCameraDriver().(*RtspCameraDriver)
+	expected := rtspMinReconnectDelay
+	for i := 0; i < 10; i++ {
+		before := time.Now()
+		cam.scheduleReconnect()
+		if cam.nextConnectAt.Before(before.Add(expected)) || cam.nextConnectAt.After(time.Now().Add(expected)) {
+			t.Fatalf("attempt %d : expected next attempt in %s , got %s", i, expected, cam.nextConnectAt.Sub(before))
+		}
+		expected = min(2*expected, rtspMaxReconnectDelay)
+	}
+	if cam.reconnectDelay != rtspMaxReconnectDelay {
+		t.Fatalf("reconnect delay isn't capped , got %s", cam.reconnectDelay)
+	}
+
+	cam.nextConnectAt = time.Now().Add(time.Minute)
+	err := cam.connect(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "next reconnect attempt") {
+		t.Fatalf("expected backoff error , got %v", err)
+	}
+}
+
+func TestRtspConfigureOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		isValid bool
+	}{
+		{name: "defaults", options: map[string]string{}, isValid: true},
+		{name: "all options", options: map[string]string{"transport": "tcp", "fps": "0.5", "jpegQuality": "80"}, isValid: true},
+		{name: "unknown transport", options: map[string]string{"transport": "http"}},
+		{name: "zero fps", options: map[string]string{"fps": "0"}},
+		{name: "invalid fps", options: map[string]string{"fps": "fast"}},
+		{name: "quality out of range", options: map[string]string{"jpegQuality": "101"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewRtspCameraDriver().(*RtspCameraDriver).ConfigureOptions(tt.options)
+			if (err == nil) != tt.isValid {
+				t.Fatalf("expected valid %v , got error %v", tt.isValid, err)
+			}
+		})
+	}
+
+	// stall detection waits for a few frame periods at low frame rates
+	cam := NewRtspCameraDriver().(*RtspCameraDriver)
+	cam.ConfigureOptions(map[string]string{"fps": "0.01"})
+	if cam.maxFrameAge != 300*time.Second {
+		t.Fatalf("expected max frame age 5m0s , got %s", cam.maxFrameAge)
+	}
+}
@@ -1,6 +1,7 @@
 package camera
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +17,13 @@ type UrlCameraDriver struct {
 	password   string
 }
 
+func init() {
+	Register("urlcam", NewUrlCameraDriver, DriverInfo{
+		Description:    "Generic camera that serves JPEG snapshots over HTTP , optional basic auth",
+		RequiredFields: []string{"Address"},
+	})
+}
+
 func NewUrlCameraDriver() Driver {
 	httpClient := http.Client{
 		Timeout: 15 * time.Second,
@@ -30,11 +38,11 @@ func (cam *UrlCameraDriver) Configure(address, username, password string) error
 	return nil
 }
 
-func (cam *UrlCameraDriver) ExtractImage() (*Image, error) {
+func (cam *UrlCameraDriver) ExtractImage(ctx context.Context) (*Image, error) {
 
 	// resp, err := cam.httpClient.Get(address)
 
-	req, err := http.NewRequest("GET", cam.address, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", cam.address, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -69,25 +77,9 @@ func (cam *UrlCameraDriver) ExtractImage() (*Image, error) {
 	return &img, nil
 }
 
-func (cam *UrlCameraDriver) ExtractMetadata() ([]byte, error) {
-	return nil, nil
-}
-
 func (cam *UrlCameraDriver) Ping(address string) bool {
 	return true
 }
 
-func (cam *UrlCameraDriver) Commit(transactionId string) error {
-	return nil
-}
-
-func (cam *UrlCameraDriver) SubscribeToEventsStream(eventFilters []EventFilter) (chan CameraEvent, error) {
-	return nil, nil
-}
-
 func (cam *UrlCameraDriver) Close() {
 }
-
-func (cam *UrlCameraDriver) GetCameraCapabilitiesManifest(component string) ([]CameraCapabilitiesManifest, error) {
-	return nil, nil
-}
@@ -7,7 +7,23 @@ require (
 	github.com/sirupsen/logrus v1.6.0
 )
 
-require github.com/cskr/pubsub/v2 v2.0.1 // indirect
+require github.com/cskr/pubsub/v2 v2.0.1
+
+require github.com/prometheus/client_golang v1.11.1
+
+require github.com/eclipse/paho.mqtt.golang v1.4.3
+
+require github.com/fsnotify/fsnotify v1.7.0
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.26.0 // indirect
+	github.com/prometheus/procfs v0.6.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+)
 
 require (
 	github.com/golang/protobuf v1.5.0 // indirect
@@ -1,7 +1,9 @@
 package integrations
 
 import (
+	"context"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
@@ -19,26 +21,91 @@ type BaseIntegration struct {
 	extractorID         string
 	ConfigObserver      *internal.CdfConfigObserver // remote config observer
 	disableRunReporting bool
+	ctx                 context.Context // integration context , cancelled when integration is stopped
+	cancel              context.CancelFunc
+	procContexts        map[uint64]processorContext
+	ctxMux              *sync.Mutex
+}
+
+type processorContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewIntegration(id string, cogClient *internal.CdfClient, extractorID string, configObserver *internal.CdfConfigObserver) *BaseIntegration {
+	ctx, cancel := context.WithCancel(context.Background())
+	stateTracker := internal.NewStateTracker()
+	internal.RegisterStateTracker(id, stateTracker)
 	return &BaseIntegration{ID: id,
 		CogClient:      cogClient,
 		extractorID:    extractorID,
 		ConfigObserver: configObserver,
-		StateTracker:   internal.NewStateTracker(),
+		StateTracker:   stateTracker,
+		ctx:            ctx,
+		cancel:         cancel,
+		procContexts:   make(map[uint64]processorContext),
+		ctxMux:         &sync.Mutex{},
 	}
 }
 
+// Stop cancels integration context and all processor contexts. Integration can be started again after Stop.
 func (intgr *BaseIntegration) Stop() {
 	intgr.IsRunning = false
+	intgr.ctxMux.Lock()
+	defer intgr.ctxMux.Unlock()
+	intgr.cancel()
+	intgr.procContexts = make(map[uint64]processorContext)
+	intgr.ctx, intgr.cancel = context.WithCancel(context.Background())
+}
+
+// Context returns integration context.
+func (intgr *BaseIntegration) Context() context.Context {
+	intgr.ctxMux.Lock()
+	defer intgr.ctxMux.Unlock()
+	return intgr.ctx
+}
+
+// NewProcessorContext creates new context for processor derived from integration context. Context of previous processor instance
+// with the same ID is cancelled.
+func (intgr *BaseIntegration) NewProcessorContext(procId uint64) context.Context {
+	intgr.ctxMux.Lock()
+	defer intgr.ctxMux.Unlock()
+	if proc, ok := intgr.procContexts[procId]; ok {
+		proc.cancel()
+	}
+	ctx, cancel := context.WithCancel(intgr.ctx)
+	intgr.procContexts[procId] = processorContext{ctx: ctx, cancel: cancel}
+	return ctx
+}
+
+// ProcessorContext returns context of running processor or integration context if processor is not running.
+func (intgr *BaseIntegration) ProcessorContext(procId uint64) context.Context {
+	intgr.ctxMux.Lock()
+	defer intgr.ctxMux.Unlock()
+	if proc, ok := intgr.procContexts[procId]; ok {
+		return proc.ctx
+	}
+	return intgr.ctx
+}
+
+// cancelProcessor cancels processor context , all blocking operations of the processor are interrupted immediately.
+func (intgr *BaseIntegration) cancelProcessor(procId uint64) {
+	intgr.ctxMux.Lock()
+	defer intgr.ctxMux.Unlock()
+	if proc, ok := intgr.procContexts[procId]; ok {
+		proc.cancel()
+		delete(intgr.procContexts, procId)
+	}
 }
 
 func (intgr *BaseIntegration) DisableRunReporting(state bool) {
 	intgr.disableRunReporting = state
 }
 
+// StopProcessor cancels processor context and waits until processor is stopped.
 func (intgr *BaseIntegration) StopProcessor(procId uint64) {
+	// background tasks of the processor (for instance event streams) may still run even if the main loop has already exited
+	intgr.cancelProcessor(procId)
 	procState := intgr.StateTracker.GetProcessorState(procId)
 	if procState.CurrentState == internal.ProcessorStateStopped || procState.CurrentState == internal.ProcessorStateShutdown || procState.CurrentState == internal.ProcessorStateNotFound {
 		log.Info("Processor is already stopped or not found")
@@ -1,5 +1,18 @@
 package ip_cams_to_cdf
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal/schedule"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+)
+
 type CameraConfig struct {
 	ID                      uint64
 	ExternalID              string
@@ -14,6 +27,70 @@ type CameraConfig struct {
 	LinkedAssetID           uint64
 	EnableCameraEventStream bool
 	EventFilters            []CameraEventFilter
+	EventMappings           []EventMappingRule // rules that map camera events to output events , the first matching rule is applied
+	DriverOptions           map[string]string  // driver specific options , for example {"transport":"tcp"} for rtsp driver
+	TimeSeries              CameraTimeSeriesConfig
+	PtzPresets              []PtzPresetConfig // presets visited in ptz_tour mode , in configured order
+	PtzSettleTime           int               // default wait time in seconds after the camera has been moved to preset , default 5
+	MotionDetection         MotionDetectionConfig
+	Dedup                   DedupConfig
+	Transforms              []imaging.TransformConfig // image transforms applied before upload , in configured order
+	Schedule                schedule.Config           // cron expression , active time windows and jitter of the polling loop
+
+	transformPipeline *imaging.Pipeline // built from Transforms by Validate , so it's not rebuilt for each image
+}
+
+const (
+	CameraModeCamera         = "camera"          // images only
+	CameraModeCameraMetadata = "camera+metadata" // images and metadata (measurements)
+	CameraModePtzTour        = "ptz_tour"        // the camera is moved to each preset and image is captured at each preset
+)
+
+// PtzPresetConfig configures single preset of PTZ preset tour.
+type PtzPresetConfig struct {
+	Preset        string // preset name or number , depending on camera API
+	SettleTime    int    // wait time in seconds after the camera has been moved to the preset , PtzSettleTime is used if 0
+	LinkedAssetID uint64 // images captured at the preset are linked to this asset , camera LinkedAssetID is used if 0
+}
+
+// MotionDetectionConfig configures software motion detection in the polling loop. When enabled , images are uploaded only
+// if the change from the previous image is over the threshold , and motion event is published for each such image.
+type MotionDetectionConfig struct {
+	Enabled bool
+	imaging.MotionDetectorConfig
+}
+
+// DedupConfig configures suppression of unchanged images. Captured image is compared with the last uploaded image of the camera
+// (of the same preset in ptz_tour mode) and it's not uploaded if it's unchanged.
+type DedupConfig struct {
+	Mode                string // none (default) , exact , dhash or phash
+	MaxDistance         *int   // max Hamming distance of perceptual hashes of unchanged images , default 4 . 0 means equal hashes only
+	ForceUploadInterval int    // unchanged image is uploaded at least every ForceUploadInterval minutes , 0 disables forced uploads
+}
+
+// IsEqual compares DedupConfig with another DedupConfig
+func (c *DedupConfig) IsEqual(other *DedupConfig) bool {
+	return c.Mode == other.Mode &&
+		c.maxDistance() == other.maxDistance() &&
+		c.ForceUploadInterval == other.ForceUploadInterval
+}
+
+// maxDistance returns configured MaxDistance , default is 4 for perceptual hashes and 0 for exact hashes.
+func (c *DedupConfig) maxDistance() int {
+	if c.MaxDistance != nil {
+		return *c.MaxDistance
+	}
+	if c.Mode == imaging.HashExact {
+		return 0
+	}
+	return defaultDedupMaxDistance
+}
+
+// CameraTimeSeriesConfig configures camera health time series. Time series are linked to LinkedAssetID of the camera.
+type CameraTimeSeriesConfig struct {
+	Enabled          bool
+	ExternalIdPrefix string   // default edge-extractor:<camera name>: , metric name is appended to the prefix
+	Metrics          []string // up , capture_latency , image_size , event_rate . All metrics are written if empty
 }
 
 type CameraEventFilter struct {
@@ -21,6 +98,19 @@ type CameraEventFilter struct {
 	ContentFilter string
 }
 
+// EventMappingRule maps camera events with matching topic to output events.
+type EventMappingRule struct {
+	TopicPattern string            // exact topic , topic prefix or glob pattern , empty pattern matches all events
+	Type         string            // output event type , camera event type is used if empty
+	Subtype      string            // output event subtype , camera event core type is used if empty
+	Description  string            // output event description
+	Metadata     map[string]string // metadata key -> message field , for example {"profile":"source.Profile","active":"data.active"}
+	OmitRawData  bool              // raw event data isn't added to metadata
+	Stateful     bool              // active and inactive events are paired into single output event with start and end time
+	StateField   string            // message field that carries event state , for example data.active . State reported by the driver is used if empty
+	MaxDuration  int               // max duration of stateful event in seconds , the event is closed if inactive event isn't received in time. Default is 3600
+}
+
 // Compare CameraConfig with anothert CameraConfig
 func (c *CameraConfig) IsEqual(other *CameraConfig) bool {
 	var isEventFiltersEqual bool
@@ -32,6 +122,30 @@ func (c *CameraConfig) IsEqual(other *CameraConfig) bool {
 			return false
 		}
 	}
+	if len(c.EventMappings) != len(other.EventMappings) {
+		return false
+	}
+	for i := range c.EventMappings {
+		if !c.EventMappings[i].IsEqual(&other.EventMappings[i]) {
+			return false
+		}
+	}
+	if len(c.DriverOptions) != len(other.DriverOptions) {
+		return false
+	}
+	if len(c.PtzPresets) != len(other.PtzPresets) {
+		return false
+	}
+	for i, preset := range c.PtzPresets {
+		if preset != other.PtzPresets[i] {
+			return false
+		}
+	}
+	for k, v := range c.DriverOptions {
+		if other.DriverOptions[k] != v {
+			return false
+		}
+	}
 
 	return c.Name == other.Name &&
 		c.Model == other.Model &&
@@ -43,15 +157,229 @@ func (c *CameraConfig) IsEqual(other *CameraConfig) bool {
 		c.State == other.State &&
 		c.LinkedAssetID == other.LinkedAssetID &&
 		c.EnableCameraEventStream == other.EnableCameraEventStream &&
+		c.TimeSeries.IsEqual(&other.TimeSeries) &&
+		c.PtzSettleTime == other.PtzSettleTime &&
+		c.MotionDetection.IsEqual(&other.MotionDetection) &&
+		c.Dedup.IsEqual(&other.Dedup) &&
+		isTransformsEqual(c.Transforms, other.Transforms) &&
+		c.Schedule.IsEqual(&other.Schedule) &&
 		isEventFiltersEqual
 
 }
 
+// IsEqual compares EventMappingRule with another EventMappingRule
+func (r *EventMappingRule) IsEqual(other *EventMappingRule) bool {
+	if len(r.Metadata) != len(other.Metadata) {
+		return false
+	}
+	for k, v := range r.Metadata {
+		if other.Metadata[k] != v {
+			return false
+		}
+	}
+	return r.TopicPattern == other.TopicPattern &&
+		r.Type == other.Type &&
+		r.Subtype == other.Subtype &&
+		r.Description == other.Description &&
+		r.OmitRawData == other.OmitRawData &&
+		r.Stateful == other.Stateful &&
+		r.StateField == other.StateField &&
+		r.MaxDuration == other.MaxDuration
+}
+
+// pollingInterval returns configured polling interval , default is 60 seconds. Negative interval disables polling.
+func (c *CameraConfig) pollingInterval() time.Duration {
+	if c.PollingInterval == 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.PollingInterval) * time.Second
+}
+
+// isTransformsEqual compares two transform chains
+func isTransformsEqual(a, b []imaging.TransformConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEqual compares MotionDetectionConfig with another MotionDetectionConfig
+func (c *MotionDetectionConfig) IsEqual(other *MotionDetectionConfig) bool {
+	if len(c.IncludeRegions) != len(other.IncludeRegions) || len(c.ExcludeRegions) != len(other.ExcludeRegions) {
+		return false
+	}
+	for i := range c.IncludeRegions {
+		if c.IncludeRegions[i] != other.IncludeRegions[i] {
+			return false
+		}
+	}
+	for i := range c.ExcludeRegions {
+		if c.ExcludeRegions[i] != other.ExcludeRegions[i] {
+			return false
+		}
+	}
+	return c.Enabled == other.Enabled &&
+		c.Width == other.Width &&
+		c.PixelThreshold == other.PixelThreshold &&
+		c.Threshold == other.Threshold
+}
+
+// IsEqual compares CameraTimeSeriesConfig with another CameraTimeSeriesConfig
+func (c *CameraTimeSeriesConfig) IsEqual(other *CameraTimeSeriesConfig) bool {
+	if len(c.Metrics) != len(other.Metrics) {
+		return false
+	}
+	for i := range c.Metrics {
+		if c.Metrics[i] != other.Metrics[i] {
+			return false
+		}
+	}
+	return c.Enabled == other.Enabled && c.ExternalIdPrefix == other.ExternalIdPrefix
+}
+
+// Validate checks camera config against metadata of the camera driver , for example required fields and supported capabilities.
+func (c *CameraConfig) Validate() error {
+	info, ok := camera.GetDriverInfo(c.Model)
+	if !ok {
+		return fmt.Errorf("unknown camera model %q , available models : %s", c.Model, strings.Join(camera.DriverNames(), ", "))
+	}
+	var errs []error
+	for _, field := range info.RequiredFields {
+		var value string
+		switch field {
+		case "Address":
+			value = c.Address
+		case "Username":
+			value = c.Username
+		case "Password":
+			value = c.Password
+		default:
+			continue
+		}
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required by %s driver", field, c.Model))
+		}
+	}
+	if c.EnableCameraEventStream && !info.Capabilities.Events {
+		errs = append(errs, fmt.Errorf("%s driver doesn't support camera event stream", c.Model))
+	}
+	if c.Mode == CameraModeCameraMetadata && !info.Capabilities.Metadata {
+		errs = append(errs, fmt.Errorf("%s driver doesn't support metadata extraction", c.Model))
+	}
+	if c.Mode == CameraModePtzTour {
+		if !info.Capabilities.PTZ {
+			errs = append(errs, fmt.Errorf("%s driver doesn't support ptz", c.Model))
+		}
+		if len(c.PtzPresets) == 0 {
+			errs = append(errs, fmt.Errorf("PtzPresets are required in %s mode", CameraModePtzTour))
+		}
+		for i, preset := range c.PtzPresets {
+			if preset.Preset == "" {
+				errs = append(errs, fmt.Errorf("ptz preset %d : Preset is required", i))
+			}
+		}
+	}
+	if c.TimeSeries.Enabled {
+		for _, metric := range c.TimeSeries.Metrics {
+			if _, ok := cameraTimeSeriesMetrics[metric]; !ok {
+				errs = append(errs, fmt.Errorf("unknown time series metric %q , supported metrics : %s", metric, strings.Join(CameraTimeSeriesMetricNames, ", ")))
+			}
+		}
+	}
+	if c.MotionDetection.Enabled {
+		if c.Mode == CameraModePtzTour {
+			errs = append(errs, fmt.Errorf("motion detection is not supported in %s mode", CameraModePtzTour))
+		}
+		if c.MotionDetection.Threshold < 0 || c.MotionDetection.Threshold > 100 {
+			errs = append(errs, fmt.Errorf("motion detection Threshold must be between 0 and 100"))
+		}
+		if c.MotionDetection.PixelThreshold < 0 || c.MotionDetection.PixelThreshold > 255 {
+			errs = append(errs, fmt.Errorf("motion detection PixelThreshold must be between 0 and 255"))
+		}
+	}
+	switch c.Dedup.Mode {
+	case "", DedupModeNone, imaging.HashExact, imaging.HashDHash, imaging.HashPHash:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported dedup mode %q , supported modes : none, exact, dhash, phash", c.Dedup.Mode))
+	}
+	if c.Dedup.MaxDistance != nil && (*c.Dedup.MaxDistance < 0 || *c.Dedup.MaxDistance > 64) {
+		errs = append(errs, fmt.Errorf("dedup MaxDistance must be between 0 and 64"))
+	}
+	if !c.Schedule.IsEmpty() {
+		if captureSchedule, err := schedule.New(c.Schedule, c.pollingInterval()); err != nil {
+			errs = append(errs, err)
+		} else if captureSchedule.Next(time.Now()) < 0 {
+			errs = append(errs, fmt.Errorf("schedule has no capture time , Cron expression never matches or doesn't match any of Windows"))
+		}
+	}
+	if len(c.Transforms) > 0 {
+		if pipeline, err := imaging.NewPipeline(c.Transforms); err != nil {
+			errs = append(errs, err)
+		} else {
+			c.transformPipeline = pipeline
+		}
+	}
+	for i, rule := range c.EventMappings {
+		for key, field := range rule.Metadata {
+			if !isEventMessageField(field) {
+				errs = append(errs, fmt.Errorf("event mapping %d : unsupported field %q of metadata %s , supported fields : %s", i, field, key, eventMessageFieldsHelp))
+			}
+		}
+		if rule.StateField != "" && !isEventMessageField(rule.StateField) {
+			errs = append(errs, fmt.Errorf("event mapping %d : unsupported state field %q , supported fields : %s", i, rule.StateField, eventMessageFieldsHelp))
+		}
+		if rule.MaxDuration < 0 {
+			errs = append(errs, fmt.Errorf("event mapping %d : MaxDuration must not be negative", i))
+		}
+	}
+	if len(c.DriverOptions) > 0 {
+		for name := range c.DriverOptions {
+			if !isDriverOptionSupported(info, name) {
+				errs = append(errs, fmt.Errorf("driver option %s is not supported by %s driver", name, c.Model))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isDriverOptionSupported(info camera.DriverInfo, name string) bool {
+	for _, option := range info.Options {
+		if option.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SpoolConfig configures durable on-disk upload queue. When enabled , captured images are written to the spool first
+// and uploaded to CDF by background uploader , so images are not lost during CDF or network outages.
+type SpoolConfig struct {
+	Enabled     bool
+	Dir         string // spool directory , default <working dir>/spool/ip_cams_to_cdf
+	MaxSizeMB   int    // max total size of the spool , the oldest images are dropped first. Default 1024
+	MaxAgeHours int    // images older than MaxAgeHours are dropped. Default 72
+}
+
 type IntegrationConfig struct {
 	Cameras             []CameraConfig
 	RetryCount          int
 	RetryInterval       int
 	DisableRunReporting bool
+	Spool               SpoolConfig
+	Output              outputs.Config     // destination of images and events , CDF by default
+	Mqtt                outputs.MqttConfig // optional publishing of events and images to MQTT broker in addition to the output
+	TimeSeries          TimeSeriesConfig   // datapoints writer settings , time series are enabled per camera
+}
+
+// TimeSeriesConfig configures writing of camera health datapoints to CDF.
+type TimeSeriesConfig struct {
+	FlushInterval         int // datapoints are written to CDF every FlushInterval seconds , default 60
+	MaxBufferedDatapoints int // max number of buffered datapoints per time series , default 10000
 }
 
 // Compare CameraImagesToCdfConfig with another CameraImagesToCdfConfig
@@ -67,6 +395,21 @@ func (c *IntegrationConfig) IsEqual(other *IntegrationConfig) bool {
 	return true
 }
 
+// Validate validates all enabled cameras and returns combined error.
+func (c *IntegrationConfig) Validate() error {
+	var errs []error
+	for i := range c.Cameras {
+		cam := &c.Cameras[i]
+		if cam.State != "enabled" {
+			continue
+		}
+		if err := cam.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("camera %s : %w", cam.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // clone returns a deep copy of IntegrationConfig
 func (c *IntegrationConfig) Clone() IntegrationConfig {
 	clone := IntegrationConfig{}
@@ -0,0 +1,53 @@
+package ip_cams_to_cdf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cognitedata/edge-extractor/internal/schedule"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+)
+
+func TestCameraConfigValidateSchedule(t *testing.T) {
+	night := []schedule.Window{{Start: "22:00", End: "06:00"}}
+	tests := []struct {
+		name     string
+		schedule schedule.Config
+		isValid  bool
+	}{
+		{name: "cron inside of window", schedule: schedule.Config{Cron: "0 23 * * *", Windows: night, Timezone: "UTC"}, isValid: true},
+		{name: "polling interval inside of window", schedule: schedule.Config{Windows: night, Timezone: "UTC"}, isValid: true},
+		{name: "invalid cron", schedule: schedule.Config{Cron: "0 25 * * *"}},
+		{name: "cron never matches", schedule: schedule.Config{Cron: "0 0 30 2 *"}},
+		{name: "cron never inside of window", schedule: schedule.Config{Cron: "0 12 * * *", Windows: night, Timezone: "UTC"}},
+		{name: "cron on days without window", schedule: schedule.Config{Cron: "0 23 * * sat", Windows: []schedule.Window{{Days: []string{"mon"}, Start: "22:00", End: "23:30"}}, Timezone: "UTC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CameraConfig{ID: 1, Name: "fscam", Model: "fscam", Address: t.TempDir(), Schedule: tt.schedule}
+			err := config.Validate()
+			if tt.isValid && err != nil {
+				t.Fatalf("expected valid config , got %s", err)
+			}
+			if !tt.isValid && (err == nil || !strings.Contains(err.Error(), "schedule") && !strings.Contains(err.Error(), "cron")) {
+				t.Fatalf("expected schedule error , got %v", err)
+			}
+		})
+	}
+}
+
+func TestCameraConfigValidateBuildsTransformPipeline(t *testing.T) {
+	config := IntegrationConfig{Cameras: []CameraConfig{{ID: 1, Name: "fscam", Model: "fscam", Address: t.TempDir(), State: "enabled",
+		Transforms: []imaging.TransformConfig{{Type: imaging.TransformRotate, Angle: 90}}}}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cameraConfig := config.Cameras[0]
+	if cameraConfig.transformPipeline == nil {
+		t.Fatal("transform pipeline isn't built by Validate")
+	}
+	img := newTestImage(t, 0)
+	if !transformImage(cameraConfig, img) || img.Format != "image/jpeg" {
+		t.Fatal("image isn't transformed")
+	}
+}
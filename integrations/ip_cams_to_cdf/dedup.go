@@ -0,0 +1,59 @@
+package ip_cams_to_cdf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DedupModeNone           = "none"
+	defaultDedupMaxDistance = 4
+)
+
+// dedupState is the hash of the last uploaded image
+type dedupState struct {
+	hash       imaging.ImageHash
+	uploadedAt time.Time
+}
+
+// dedupImage returns true if the image is unchanged compared to the last uploaded image and forced upload isn't due.
+// Otherwise commit must be called after the image has been delivered , the image becomes the reference for next images.
+// Images that can't be hashed are never reported as unchanged.
+func (intgr *CameraImagesToCdf) dedupImage(cameraConfig CameraConfig, img *camera.Image, metadata map[string]string) (isUnchanged bool, commit func()) {
+	config := cameraConfig.Dedup
+	if config.Mode == "" || config.Mode == DedupModeNone {
+		return false, func() {}
+	}
+	hash, err := imaging.HashImage(img.Body, config.Mode)
+	if err != nil {
+		log.Errorf("Failed to compute %s hash of image from camera %s , the image is uploaded. Err : %s", config.Mode, cameraConfig.Name, err.Error())
+		return false, func() {}
+	}
+	// each preset of ptz tour has its own reference image
+	key := fmt.Sprintf("%d/%s", cameraConfig.ID, metadata["ptzPreset"])
+	commit = func() {
+		intgr.dedupStates.Store(key, dedupState{hash: hash, uploadedAt: time.Now()})
+	}
+	previous, ok := intgr.dedupStates.Load(key)
+	if !ok {
+		return false, commit
+	}
+	state := previous.(dedupState)
+	distance := hash.Distance(state.hash)
+	if distance > config.maxDistance() {
+		return false, commit
+	}
+	if config.ForceUploadInterval > 0 && time.Since(state.uploadedAt) >= time.Duration(config.ForceUploadInterval)*time.Minute {
+		log.Debugf("Image from camera %s is unchanged , forced upload", cameraConfig.Name)
+		return false, commit
+	}
+	log.Debugf("Image from camera %s is unchanged (hash distance %d) , upload skipped", cameraConfig.Name, distance)
+	intgr.unchangedCounter.Add(1)
+	internal.ImagesSkippedTotal.WithLabelValues(cameraConfig.Name, internal.MetricSkipReasonUnchanged).Inc()
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package ip_cams_to_cdf
+
+import (
+	"testing"
+
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+)
+
+func TestDedupMaxDistance(t *testing.T) {
+	zero, eight := 0, 8
+	reference := newTestImage(t, 0)
+	captured := []*camera.Image{newTestImage(t, 0), newTestImage(t, 4), newTestImage(t, 24)} // same image , small change , big change
+	tests := []struct {
+		name        string
+		config      DedupConfig
+		isUnchanged []bool // of captured images
+	}{
+		{name: "dhash default distance", config: DedupConfig{Mode: imaging.HashDHash}, isUnchanged: []bool{true, true, false}},
+		{name: "dhash zero distance", config: DedupConfig{Mode: imaging.HashDHash, MaxDistance: &zero}, isUnchanged: []bool{true, false, false}},
+		{name: "dhash large distance", config: DedupConfig{Mode: imaging.HashDHash, MaxDistance: &eight}, isUnchanged: []bool{true, true, true}},
+		{name: "exact", config: DedupConfig{Mode: imaging.HashExact}, isUnchanged: []bool{true, false, false}},
+		{name: "none", config: DedupConfig{Mode: DedupModeNone}, isUnchanged: []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, img := range captured {
+				cameraConfig := CameraConfig{ID: 1, Name: "gauge", Dedup: tt.config}
+				intgr, _ := newTestIntegration(cameraConfig)
+				isUnchanged, commit := intgr.dedupImage(cameraConfig, reference, map[string]string{})
+				if isUnchanged {
+					t.Fatal("the first image is reported as unchanged")
+				}
+				commit()
+				if isUnchanged, _ := intgr.dedupImage(cameraConfig, img, map[string]string{}); isUnchanged != tt.isUnchanged[i] {
+					t.Errorf("image %d : expected unchanged %v , got %v", i, tt.isUnchanged[i], isUnchanged)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupConfigIsEqual(t *testing.T) {
+	zero, four := 0, 4
+	tests := []struct {
+		name    string
+		a, b    DedupConfig
+		isEqual bool
+	}{
+		{name: "default and explicit default", a: DedupConfig{Mode: imaging.HashDHash}, b: DedupConfig{Mode: imaging.HashDHash, MaxDistance: &four}, isEqual: true},
+		{name: "default and zero", a: DedupConfig{Mode: imaging.HashDHash}, b: DedupConfig{Mode: imaging.HashDHash, MaxDistance: &zero}},
+		{name: "exact default and zero", a: DedupConfig{Mode: imaging.HashExact}, b: DedupConfig{Mode: imaging.HashExact, MaxDistance: &zero}, isEqual: true},
+		{name: "different mode", a: DedupConfig{Mode: imaging.HashDHash}, b: DedupConfig{Mode: imaging.HashPHash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if isEqual := tt.a.IsEqual(&tt.b); isEqual != tt.isEqual {
+				t.Fatalf("expected %v , got %v", tt.isEqual, isEqual)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+const eventMessageFieldsHelp = "topic , type , state , source.<name> , key.<name> , data.<name>"
+
+const (
+	defaultMaxEventDuration   = time.Hour        // max duration of stateful event if MaxDuration of the rule isn't set
+	activeEventsSweepInterval = 30 * time.Second // how often stateful events are checked for exceeding max duration
+	activeEventsCloseTimeout  = 30 * time.Second // timeout of writing closed events to the output
+)
+
+// Reasons of closing stateful event without inactive event , the reason is added to endReason metadata field.
+const (
+	eventEndReasonTimeout      = "timeout"
+	eventEndReasonDisconnected = "disconnected"
+	eventEndReasonStopped      = "stopped"
+)
+
+// activeEvent is the start of stateful event waiting for inactive event.
+type activeEvent struct {
+	cameraID   uint64
+	cameraName string
+	event      outputs.Event
+	expiresAt  time.Time // the event is closed by timeout after this time
+}
+
+// close returns output event that ends at endTime (ms). Non empty reason is added to metadata.
+func (a *activeEvent) close(endTime int64, reason string) outputs.Event {
+	event := a.event
+	event.EndTime = endTime
+	if event.EndTime <= event.StartTime {
+		event.EndTime = event.StartTime + 1
+	}
+	delete(event.Metadata, "state")
+	if reason != "" {
+		event.Metadata["endReason"] = reason
+	}
+	return event
+}
+
+// isEventMessageField returns true if field is a supported reference to camera event field.
+func isEventMessageField(field string) bool {
+	switch field {
+	case "topic", "type", "state":
+		return true
+	}
+	for _, prefix := range []string{"source.", "key.", "data."} {
+		if strings.HasPrefix(field, prefix) && len(field) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventMessageField returns value of camera event field , for example data.active . ok is false if the event doesn't have the field.
+func eventMessageField(event camera.CameraEvent, field string) (value string, ok bool) {
+	switch field {
+	case "topic":
+		return event.Topic, true
+	case "type":
+		return event.Type, true
+	case "state":
+		return event.State, event.State != ""
+	}
+	group, name, _ := strings.Cut(field, ".")
+	switch group {
+	case "source":
+		value, ok = event.Message.Source[name]
+	case "key":
+		value, ok = event.Message.Key[name]
+	case "data":
+		value, ok = event.Message.Data[name]
+	}
+	return value, ok
+}
+
+// findEventMappingRule returns the first rule that matches event topic , nil is returned if no rule matches.
+func findEventMappingRule(rules []EventMappingRule, event camera.CameraEvent) *EventMappingRule {
+	for i := range rules {
+		if (camera.EventFilter{TopicFilter: rules[i].TopicPattern}).MatchTopic(event.Topic) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// mapOutputEvent converts camera event into output event using event mapping rules of the camera. Events without matching rule are converted by newOutputEvent.
+// isReady is false if the event is the start of stateful event , the output event is produced when the matching inactive event is received.
+// If the previous start of the same stateful event has exceeded its max duration , it's closed and returned instead.
+func (intgr *CameraImagesToCdf) mapOutputEvent(event camera.CameraEvent) (outputEvent outputs.Event, isReady bool) {
+	outputEvent = newOutputEvent(event)
+	cameraConfig := intgr.GetCameraConfigByID(event.CameraID)
+	if cameraConfig == nil {
+		return outputEvent, true
+	}
+	rule := findEventMappingRule(cameraConfig.EventMappings, event)
+	if rule == nil {
+		return outputEvent, true
+	}
+	if rule.Type != "" {
+		outputEvent.Type = rule.Type
+	}
+	if rule.Subtype != "" {
+		outputEvent.Subtype = rule.Subtype
+	}
+	outputEvent.Description = rule.Description
+	for key, field := range rule.Metadata {
+		if value, ok := eventMessageField(event, field); ok {
+			outputEvent.Metadata[key] = value
+		}
+	}
+	if rule.OmitRawData {
+		delete(outputEvent.Metadata, "rawData")
+	}
+	if !rule.Stateful {
+		return outputEvent, true
+	}
+
+	state := event.State
+	if rule.StateField != "" {
+		if value, ok := eventMessageField(event, rule.StateField); ok {
+			state = camera.EventStateFromValue(value)
+		}
+	}
+	key := eventPairingKey(event)
+	switch state {
+	case camera.EventStateActive:
+		maxDuration := defaultMaxEventDuration
+		if rule.MaxDuration > 0 {
+			maxDuration = time.Duration(rule.MaxDuration) * time.Second
+		}
+		now := time.Now()
+		newEvent := &activeEvent{cameraID: event.CameraID, cameraName: event.CameraName, event: outputEvent, expiresAt: now.Add(maxDuration)}
+		value, isLoaded := intgr.activeEvents.LoadOrStore(key, newEvent)
+		if !isLoaded {
+			return outputEvent, false
+		}
+		startEvent := value.(*activeEvent)
+		if now.Before(startEvent.expiresAt) || !intgr.activeEvents.CompareAndSwap(key, startEvent, newEvent) {
+			log.Debugf("Event %s of camera %s is already active", event.Topic, event.CameraName)
+			return outputEvent, false
+		}
+		log.Infof("Event %s of camera %s exceeded max duration %s , the event is closed", event.Topic, event.CameraName, maxDuration)
+		return startEvent.close(now.UnixMilli(), eventEndReasonTimeout), true
+	case camera.EventStateInactive:
+		value, isLoaded := intgr.activeEvents.LoadAndDelete(key)
+		if !isLoaded {
+			log.Debugf("Event %s of camera %s became inactive without being active , the event is ignored", event.Topic, event.CameraName)
+			return outputEvent, false
+		}
+		return value.(*activeEvent).close(event.Timestamp, ""), true
+	default:
+		// event without state matched stateful rule
+		return outputEvent, true
+	}
+}
+
+// closeActiveEvents writes stateful events of the camera that are still active to the output with end time now and forgets them.
+// If onlyExpired is true , only events that exceeded max duration of their rule are closed.
+func (intgr *CameraImagesToCdf) closeActiveEvents(cameraID uint64, reason string, onlyExpired bool) {
+	now := time.Now()
+	var closedEvents []outputs.Event
+	var cameraName string
+	intgr.activeEvents.Range(func(key, value any) bool {
+		startEvent := value.(*activeEvent)
+		if startEvent.cameraID != cameraID || (onlyExpired && now.Before(startEvent.expiresAt)) {
+			return true
+		}
+		// the event may have been paired with inactive event meanwhile
+		if intgr.activeEvents.CompareAndDelete(key, startEvent) {
+			closedEvents = append(closedEvents, startEvent.close(now.UnixMilli(), reason))
+			cameraName = startEvent.cameraName
+		}
+		return true
+	})
+	if len(closedEvents) == 0 || intgr.output == nil {
+		return
+	}
+	log.Infof("Closing %d active events of camera %s , reason : %s", len(closedEvents), cameraName, reason)
+	ctx, cancel := context.WithTimeout(context.Background(), activeEventsCloseTimeout)
+	defer cancel()
+	if err := intgr.output.CreateEvents(ctx, closedEvents); err != nil {
+		log.Errorf("Failed to publish closed events to %s output. Error : %s", intgr.output.Type(), err.Error())
+		internal.ErrorsTotal.WithLabelValues(cameraName, internal.MetricStageEvent).Inc()
+	}
+}
+
+// runActiveEventsSweeper periodically closes stateful events of the camera that exceeded max duration until ctx is cancelled.
+func (intgr *CameraImagesToCdf) runActiveEventsSweeper(ctx context.Context, cameraID uint64) {
+	for internal.SleepWithContext(ctx, activeEventsSweepInterval) {
+		intgr.closeActiveEvents(cameraID, eventEndReasonTimeout, true)
+	}
+}
+
+// eventPairingKey identifies stateful event instance , for example motion on specific video channel.
+func eventPairingKey(event camera.CameraEvent) string {
+	return fmt.Sprintf("%d|%s|%s|%s", event.CameraID, event.Topic, formatEventItems(event.Message.Source), formatEventItems(event.Message.Key))
+}
+
+func formatEventItems(items map[string]string) string {
+	pairs := make([]string, 0, len(items))
+	for name, value := range items {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
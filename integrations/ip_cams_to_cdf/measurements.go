@@ -0,0 +1,94 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	log "github.com/sirupsen/logrus"
+)
+
+// Camera event types produced from alarm state changes
+const (
+	CameraEventTypeAlarmTriggered = "measurement_alarm_triggered"
+	CameraEventTypeAlarmCleared   = "measurement_alarm_cleared"
+)
+
+// processMeasurements writes measurement values as datapoints and converts alarm state changes into camera events.
+func (intgr *CameraImagesToCdf) processMeasurements(ctx context.Context, cameraConfig CameraConfig, measurements *camera.Measurements) {
+	intgr.writeMeasurementDatapoints(cameraConfig, measurements)
+	intgr.processAlarms(ctx, cameraConfig, measurements)
+	intgr.BaseIntegration.StateTracker.ReportProcessorSuccess(cameraConfig.ID)
+}
+
+// writeMeasurementDatapoints writes each measurement value to its own time series , time series are registered on first reading.
+// Time series external id is <prefix><type>_<id>_<value name> , for example edge-extractor:camera-1:box_1_max .
+func (intgr *CameraImagesToCdf) writeMeasurementDatapoints(cameraConfig CameraConfig, measurements *camera.Measurements) {
+	writer := intgr.tsWriter.Load()
+	if writer == nil {
+		log.Debugf("Time series writer is not running , measurements of camera %s are not written", cameraConfig.Name)
+		return
+	}
+	timestamp := time.UnixMilli(measurements.Timestamp)
+	for _, measurement := range measurements.Measurements {
+		valueNames := make([]string, 0, len(measurement.Values))
+		for valueName := range measurement.Values {
+			valueNames = append(valueNames, valueName)
+		}
+		sort.Strings(valueNames)
+		for _, valueName := range valueNames {
+			externalId := cameraTimeSeriesExternalId(cameraConfig, fmt.Sprintf("%s_%d_%s", measurement.Type, measurement.ID, valueName))
+			if !writer.HasTimeSeries(externalId) {
+				measurementName := measurement.Name
+				if measurementName == "" {
+					measurementName = fmt.Sprintf("%s %d", measurement.Type, measurement.ID)
+				}
+				writer.RegisterTimeSeries(core.TimeSerie{
+					ExternalID:  externalId,
+					Name:        fmt.Sprintf("%s %s %s", cameraConfig.Name, measurementName, valueName),
+					Description: fmt.Sprintf("Camera %s measurement", measurement.Type),
+					Unit:        measurement.Unit,
+					AssetID:     cameraConfig.LinkedAssetID,
+					Metadata: map[string]string{"cameraName": cameraConfig.Name, "cameraId": fmt.Sprint(cameraConfig.ID), "measurementType": measurement.Type,
+						"measurementId": fmt.Sprint(measurement.ID), "value": valueName, "source": "edge-extractor"},
+				})
+			}
+			writer.Add(externalId, timestamp, measurement.Values[valueName])
+		}
+	}
+}
+
+// processAlarms publishes camera event when alarm state changes. Alarms that are active on the first reading are published as triggered.
+func (intgr *CameraImagesToCdf) processAlarms(ctx context.Context, cameraConfig CameraConfig, measurements *camera.Measurements) {
+	for _, alarm := range measurements.Alarms {
+		key := fmt.Sprintf("%d/%d", cameraConfig.ID, alarm.ID)
+		previousState, isKnown := intgr.alarmStates.Swap(key, alarm.Active)
+		if isKnown && previousState.(bool) == alarm.Active {
+			continue
+		}
+		if !isKnown && !alarm.Active {
+			continue
+		}
+		eventType, state := CameraEventTypeAlarmCleared, camera.EventStateInactive
+		if alarm.Active {
+			eventType, state = CameraEventTypeAlarmTriggered, camera.EventStateActive
+		}
+		log.Infof("Camera %s alarm %d state has been changed : %s", cameraConfig.Name, alarm.ID, eventType)
+		rawData, _ := json.Marshal(alarm)
+		intgr.publishCameraEvent(ctx, camera.CameraEvent{
+			CoreType:   "alarm",
+			Type:       eventType,
+			Topic:      fmt.Sprintf("alarms/%d", alarm.ID),
+			Source:     cameraConfig.Name,
+			Timestamp:  measurements.Timestamp,
+			RawData:    rawData,
+			State:      state,
+			CameraID:   cameraConfig.ID,
+			CameraName: cameraConfig.Name,
+		})
+	}
+}
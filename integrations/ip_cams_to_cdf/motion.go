@@ -0,0 +1,61 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+	log "github.com/sirupsen/logrus"
+)
+
+// Camera event produced by software motion detection
+const (
+	CameraEventTypeMotionDetected = "software_motion_detected"
+	CameraEventTopicMotion        = "motion"
+)
+
+// newMotionFilter returns image filter that accepts only images with motion and publishes motion event for each accepted image.
+// Returns nil if motion detection is disabled. Images that can't be decoded are accepted.
+func (intgr *CameraImagesToCdf) newMotionFilter(ctx context.Context, cameraConfig CameraConfig) imageFilter {
+	if !cameraConfig.MotionDetection.Enabled || cameraConfig.Mode == CameraModePtzTour {
+		return nil
+	}
+	log.Infof("Motion detection is enabled for camera %s , images are uploaded only if motion is detected", cameraConfig.Name)
+	detector := imaging.NewMotionDetector(cameraConfig.MotionDetection.MotionDetectorConfig)
+	return func(img *camera.Image) bool {
+		result, err := detector.Detect(img.Body)
+		if err != nil {
+			log.Errorf("Motion detection failed for camera %s , the image is uploaded. Err : %s", cameraConfig.Name, err.Error())
+			return true
+		}
+		if !result.IsMotion {
+			log.Debugf("No motion detected by camera %s . Score = %.2f", cameraConfig.Name, result.Score)
+			intgr.noMotionCounter.Add(1)
+			internal.ImagesSkippedTotal.WithLabelValues(cameraConfig.Name, internal.MetricSkipReasonNoMotion).Inc()
+			return false
+		}
+		log.Infof("Motion detected by camera %s . Score = %.2f", cameraConfig.Name, result.Score)
+		if img.Metadata == nil {
+			img.Metadata = make(map[string]string)
+		}
+		score := strconv.FormatFloat(result.Score, 'f', 2, 64)
+		img.Metadata["motionScore"] = score
+		rawData, _ := json.Marshal(map[string]float64{"score": result.Score})
+		intgr.publishCameraEvent(ctx, camera.CameraEvent{
+			CoreType:   "motion",
+			Type:       CameraEventTypeMotionDetected,
+			Topic:      CameraEventTopicMotion,
+			Source:     "edge-extractor:motion-detector",
+			Timestamp:  time.Now().UnixMilli(),
+			RawData:    rawData,
+			Message:    camera.EventMessage{Data: map[string]string{"score": score}},
+			CameraID:   cameraConfig.ID,
+			CameraName: cameraConfig.Name,
+		})
+		return true
+	}
+}
@@ -0,0 +1,109 @@
+package ip_cams_to_cdf
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+)
+
+// newTestImage returns PNG encoded gray image with white block of given size in the top left corner.
+func newTestImage(t *testing.T, blockSize int) *camera.Image {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return &camera.Image{Body: buf.Bytes(), Format: "image/png"}
+}
+
+func TestMotionFilter(t *testing.T) {
+	cameraConfig := CameraConfig{ID: 3, Name: "gate", MotionDetection: MotionDetectionConfig{Enabled: true}}
+	intgr, out := newTestIntegration(cameraConfig)
+	events := intgr.GetEventBus().Sub(EventBusTopicAll)
+	defer intgr.GetEventBus().Unsub(events)
+	filter := intgr.newMotionFilter(context.Background(), cameraConfig)
+	if filter == nil {
+		t.Fatal("motion filter isn't created")
+	}
+
+	// the first image is the reference , unchanged image has no motion
+	for i := 0; i < 2; i++ {
+		if filter(newTestImage(t, 0)) {
+			t.Fatalf("image %d without motion is accepted", i)
+		}
+	}
+	if count := intgr.noMotionCounter.Load(); count != 2 {
+		t.Fatalf("expected 2 images without motion , got %d", count)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %s", event.Type)
+	default:
+	}
+
+	img := newTestImage(t, 16)
+	if !filter(img) {
+		t.Fatal("image with motion is rejected")
+	}
+	if img.Metadata["motionScore"] != "8.33" {
+		t.Errorf("expected motionScore 8.33 , got %q", img.Metadata["motionScore"])
+	}
+	select {
+	case event := <-events:
+		if event.Type != CameraEventTypeMotionDetected || event.Topic != CameraEventTopicMotion || event.CameraID != 3 || event.CameraName != "gate" || event.Message.Data["score"] != "8.33" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("motion event isn't published to event bus")
+	}
+	if len(out.events) != 1 || out.events[0].Type != CameraEventTypeMotionDetected || out.events[0].Metadata["cameraName"] != "gate" {
+		t.Fatalf("expected motion event in output , got %+v", out.events)
+	}
+
+	// images that can't be decoded are accepted
+	if !filter(&camera.Image{Body: []byte("not an image")}) {
+		t.Fatal("image that can't be decoded is rejected")
+	}
+}
+
+func TestMotionFilterRegions(t *testing.T) {
+	cameraConfig := CameraConfig{ID: 3, Name: "gate", MotionDetection: MotionDetectionConfig{Enabled: true}}
+	cameraConfig.MotionDetection.ExcludeRegions = []imaging.Region{{X: 0, Y: 0, Width: 0.5, Height: 0.5}}
+	intgr, out := newTestIntegration(cameraConfig)
+	filter := intgr.newMotionFilter(context.Background(), cameraConfig)
+	filter(newTestImage(t, 0))
+	if filter(newTestImage(t, 16)) {
+		t.Fatal("change inside of excluded region is reported as motion")
+	}
+	if len(out.events) != 0 {
+		t.Fatalf("unexpected events %+v", out.events)
+	}
+}
+
+func TestMotionFilterDisabled(t *testing.T) {
+	for _, cameraConfig := range []CameraConfig{
+		{Name: "disabled"},
+		{Name: "ptz", Mode: CameraModePtzTour, MotionDetection: MotionDetectionConfig{Enabled: true}},
+	} {
+		intgr, _ := newTestIntegration(cameraConfig)
+		if intgr.newMotionFilter(context.Background(), cameraConfig) != nil {
+			t.Errorf("motion filter is created for camera %s", cameraConfig.Name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventBusTopicAll is event bus topic that receives events from all cameras.
+const EventBusTopicAll = "all"
+
+// startMqtt connects to MQTT broker and starts publishing camera events from the event bus if MQTT is enabled in integration config.
+func (intgr *CameraImagesToCdf) startMqtt() {
+	intgr.stopMqtt()
+	config := intgr.integrationConfig.Mqtt
+	if !config.Enabled {
+		return
+	}
+	if intgr.secretManager != nil {
+		config.Password = intgr.secretManager.GetSecret(config.Password)
+	}
+	mqttOutput, err := outputs.NewMqttOutput(config)
+	if err != nil {
+		log.Errorf("Failed to create MQTT output , events and images are not published to MQTT. Err : %s", err.Error())
+		intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusFailure, "failed to create mqtt output , err :"+err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(intgr.BaseIntegration.Context())
+	intgr.mqttCancel = cancel
+	intgr.mqtt.Store(mqttOutput)
+	stream := intgr.eventbus.Sub(EventBusTopicAll)
+	go func() {
+		<-ctx.Done()
+		intgr.eventbus.Unsub(stream, EventBusTopicAll)
+	}()
+	go intgr.runMqttEventsPublisher(ctx, mqttOutput, stream)
+}
+
+// stopMqtt stops events publisher and disconnects from the broker.
+func (intgr *CameraImagesToCdf) stopMqtt() {
+	mqttOutput := intgr.mqtt.Swap(nil)
+	if intgr.mqttCancel != nil {
+		intgr.mqttCancel()
+		intgr.mqttCancel = nil
+	}
+	if mqttOutput != nil {
+		mqttOutput.Close()
+	}
+}
+
+// runMqttEventsPublisher publishes camera events from the event bus to MQTT until the stream is closed.
+func (intgr *CameraImagesToCdf) runMqttEventsPublisher(ctx context.Context, mqttOutput *outputs.MqttOutput, stream chan camera.CameraEvent) {
+	log.Info("Starting MQTT events publisher")
+	for event := range stream {
+		if ctx.Err() != nil {
+			// draining the stream until it's closed by Unsub
+			continue
+		}
+		if err := mqttOutput.CreateEvents(ctx, []outputs.Event{newOutputEvent(event)}); err != nil {
+			log.Errorf("Failed to publish camera %s event to MQTT. Err : %s", event.CameraName, err.Error())
+			internal.ErrorsTotal.WithLabelValues(event.CameraName, internal.MetricStageMqtt).Inc()
+		}
+	}
+	log.Info("MQTT events publisher has been stopped")
+}
+
+// publishImageToMqtt publishes image or image reference to MQTT. Errors are logged and don't affect image upload.
+func (intgr *CameraImagesToCdf) publishImageToMqtt(ctx context.Context, file outputs.File) {
+	mqttOutput := intgr.mqtt.Load()
+	if mqttOutput == nil {
+		return
+	}
+	if err := mqttOutput.UploadFile(ctx, file); err != nil {
+		log.Errorf("Failed to publish image %s to MQTT. Err : %s", file.Name, err.Error())
+		internal.ErrorsTotal.WithLabelValues(file.Source, internal.MetricStageMqtt).Inc()
+	}
+}
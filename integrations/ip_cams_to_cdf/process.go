@@ -1,31 +1,49 @@
 package ip_cams_to_cdf
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
 	"github.com/cognitedata/edge-extractor/connectors/inputs"
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
 	"github.com/cognitedata/edge-extractor/drivers/camera"
 	"github.com/cognitedata/edge-extractor/integrations"
 	"github.com/cognitedata/edge-extractor/internal"
+	"github.com/cognitedata/edge-extractor/internal/schedule"
 	"github.com/cskr/pubsub/v2"
 	log "github.com/sirupsen/logrus"
 )
 
 type CameraImagesToCdf struct {
 	integrations.BaseIntegration
-	successCounter    uint64
-	failureCounter    uint64
+	successCounter    atomic.Uint64
+	failureCounter    atomic.Uint64
+	unchangedCounter  atomic.Uint64 // images that were not uploaded because they were unchanged
+	noMotionCounter   atomic.Uint64 // images that were not uploaded because no motion was detected
 	cameraConfigs     []CameraConfig
 	cameras           map[uint64]*inputs.IpCamera
 	secretManager     *internal.SecretManager
 	integrationConfig IntegrationConfig
 	eventbus          *pubsub.PubSub[string, camera.CameraEvent]
+	output            outputs.Output                 // destination of images and events , CDF by default
+	spool             atomic.Pointer[internal.Spool] // nil if spool is disabled
+	spoolCancel       context.CancelFunc
+	mqtt              atomic.Pointer[outputs.MqttOutput] // nil if MQTT publishing is disabled
+	mqttCancel        context.CancelFunc
+	tsWriter          atomic.Pointer[internal.DatapointsWriter] // nil if camera time series are disabled
+	tsCancel          context.CancelFunc
+	tsEventCounters   sync.Map // camera ID -> *atomic.Uint64 , number of events since the last flush
+	alarmStates       sync.Map // <camera ID>/<alarm ID> -> bool , the last known alarm state
+	activeEvents      sync.Map // event pairing key -> *activeEvent , start of stateful event waiting for inactive event
+	dedupStates       sync.Map // <camera ID>/<ptz preset> -> dedupState , hash of the last uploaded image
 }
 
 func NewCameraImagesToCdf(cogClient *internal.CdfClient, extractorMonitoringID string, configObserver *internal.CdfConfigObserver, systemEventBus *pubsub.PubSub[string, internal.SystemEvent]) *CameraImagesToCdf {
@@ -99,10 +117,31 @@ func (intgr *CameraImagesToCdf) Start() error {
 
 func (intgr *CameraImagesToCdf) startAllProcessors() {
 	intgr.IsRunning = true
+	output, err := outputs.NewOutput(intgr.integrationConfig.Output, intgr.BaseIntegration.CogClient)
+	if err != nil {
+		log.Errorf("Failed to create output , camera processors are not started. Err : %s", err.Error())
+		intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusFailure, "failed to create output , err :"+err.Error())
+		return
+	}
+	intgr.output = output
+	log.Infof("Using %s output", output.Type())
+	intgr.startMqtt()
+	intgr.startSpool()
+	intgr.startTimeSeries()
 	log.Info("Starting all camera processors")
-	for _, camera := range intgr.cameraConfigs {
+	for i := range intgr.cameraConfigs {
+		// configs are validated in place , so values built by Validate are used by all runs of the camera
+		camera := &intgr.cameraConfigs[i]
 		if camera.State == "enabled" {
-			go intgr.startSingleCameraProcessorLoop(camera)
+			if err := camera.Validate(); err != nil {
+				log.Errorf("Camera %s has invalid configuration , processor is not started. Err : %s", camera.Name, err.Error())
+				intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, err.Error())
+				intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(camera.ID, internal.ProcessorStateStopped)
+				intgr.BaseIntegration.StateTracker.SetProcessorName(camera.ID, camera.Name)
+				intgr.BaseIntegration.StateTracker.ReportProcessorError(camera.ID, err)
+				continue
+			}
+			go intgr.startSingleCameraProcessorLoop(*camera)
 		} else {
 			log.Infof("Camera %s is disabled , operation skipped", camera.Name)
 		}
@@ -113,23 +152,31 @@ func (intgr *CameraImagesToCdf) startAllProcessors() {
 // startSelfMonitoring run a status reporting look that periodically sends status reports to pipeline monitoring
 func (intgr *CameraImagesToCdf) startSelfMonitoring() {
 	for {
-		if intgr.successCounter > 0 && intgr.failureCounter == 0 {
-			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Processed %d images", intgr.successCounter))
-		} else if intgr.successCounter > 0 && intgr.failureCounter > 0 {
-			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Processed %d images, %d failures", intgr.successCounter, intgr.failureCounter))
+		var queueStatus string
+		if queueDepth := intgr.spoolQueueDepth(); queueDepth >= 0 {
+			queueStatus = fmt.Sprintf(", spool queue depth %d", queueDepth)
+		}
+		unchangedCount, noMotionCount := intgr.unchangedCounter.Swap(0), intgr.noMotionCounter.Swap(0)
+		if unchangedCount+noMotionCount > 0 {
+			queueStatus = fmt.Sprintf(", %d images skipped (unchanged: %d, no motion: %d)%s", unchangedCount+noMotionCount, unchangedCount, noMotionCount, queueStatus)
+		}
+		successCount := intgr.successCounter.Swap(0)
+		failureCount := intgr.failureCounter.Swap(0)
+		if successCount > 0 && failureCount == 0 {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Processed %d images%s", successCount, queueStatus))
+		} else if successCount > 0 && failureCount > 0 {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Processed %d images, %d failures%s", successCount, failureCount, queueStatus))
 		} else {
-			intgr.ReportRunStatus("", core.ExtractionRunStatusSeen, "")
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSeen, strings.TrimPrefix(queueStatus, ", "))
 		}
-		intgr.successCounter = 0
-		intgr.failureCounter = 0
 		time.Sleep(time.Second * 60)
 	}
 }
 
 func (intgr *CameraImagesToCdf) restartProcessor(camera CameraConfig) {
-	intgr.BaseIntegration.StateTracker.SetProcessorTargetState(camera.ID, internal.ProcessorStateStopped)
-	if intgr.BaseIntegration.StateTracker.WaitForProcessorTargetState(camera.ID, time.Second*120) {
-		log.Infof("Processor %d has been stopped", camera.ID)
+	intgr.BaseIntegration.StopProcessor(camera.ID)
+	intgr.closeActiveEvents(camera.ID, eventEndReasonStopped, false)
+	if intgr.BaseIntegration.StateTracker.GetProcessorState(camera.ID).CurrentState == internal.ProcessorStateStopped {
 		intgr.startSingleCameraProcessorLoop(camera)
 	} else {
 		log.Errorf("Failed to restart processor %d. Previous instance is still running", camera.ID)
@@ -137,7 +184,8 @@ func (intgr *CameraImagesToCdf) restartProcessor(camera CameraConfig) {
 
 }
 
-// startProcessor starts camera processor , the operation is blocking and must be started in its own goroute
+// startProcessor starts camera processor , the operation is blocking and must be started in its own goroute.
+// The processor is stopped when its context is cancelled (see BaseIntegration.StopProcessor).
 func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig CameraConfig) error {
 	log.Infof("Starting camera processor %s", cameraConfig.Name)
 	defer func() {
@@ -150,14 +198,11 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 
 	intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(cameraConfig.ID, internal.ProcessorStateStarting)
 	intgr.BaseIntegration.StateTracker.SetProcessorTargetState(cameraConfig.ID, internal.ProcessorStateRunning)
-	var pollingInterval time.Duration
-
+	intgr.BaseIntegration.StateTracker.SetProcessorName(cameraConfig.ID, cameraConfig.Name)
+	intgr.registerCameraTimeSeries(cameraConfig)
+	ctx := intgr.BaseIntegration.NewProcessorContext(cameraConfig.ID)
 	log.Infof("Non-default polling interval = %d", cameraConfig.PollingInterval)
-	pollingInterval = time.Duration(cameraConfig.PollingInterval) * time.Second
-
-	if pollingInterval == 0 {
-		pollingInterval = 60 * time.Second
-	}
+	pollingInterval := cameraConfig.pollingInterval()
 
 	log.Infof("Camera name = %s, model = %s, address = %s, username = %s, mode = %s", cameraConfig.Name, cameraConfig.Model, cameraConfig.Address, cameraConfig.Username, cameraConfig.Mode)
 
@@ -165,10 +210,11 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 		log.Errorf("Processor can't be started for camera %s . Model or address aren't set.", cameraConfig.Name)
 		return fmt.Errorf("empty asset model or address")
 	}
-	cam := inputs.NewIpCamera(cameraConfig.ID, cameraConfig.Name, cameraConfig.Model, cameraConfig.Address, "", cameraConfig.Username, intgr.secretManager.GetSecret(cameraConfig.Password))
-	if cam == nil {
-		log.Error("Unsupported camera model")
-		return fmt.Errorf("unsupported camera model")
+	cam, err := inputs.NewIpCamera(cameraConfig.ID, cameraConfig.Name, cameraConfig.Model, cameraConfig.Address, "", cameraConfig.Username, intgr.secretManager.GetSecret(cameraConfig.Password), cameraConfig.DriverOptions)
+	if err != nil {
+		log.Errorf("Processor can't be started for camera %s . Err : %s", cameraConfig.Name, err.Error())
+		intgr.BaseIntegration.StateTracker.ReportProcessorError(cameraConfig.ID, err)
+		return err
 	}
 	intgr.cameras[cameraConfig.ID] = cam
 	intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(cameraConfig.ID, internal.ProcessorStateRunning)
@@ -178,27 +224,49 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 		cameraEventFilters[i] = camera.EventFilter(filter)
 	}
 
-	err := intgr.DiscoverCameraCapabilities(cam)
-	if err != nil {
-		log.Error("Failed to sync cameras manifests with CDF. Err:", err.Error())
+	if cam.SupportsManifests() {
+		err = intgr.DiscoverCameraCapabilities(cam)
+		if err != nil {
+			log.Error("Failed to sync cameras manifests with CDF. Err:", err.Error())
+		}
 	}
 
 	if cameraConfig.EnableCameraEventStream {
-		go intgr.StartSingleCameraEventsProcessingLoop(cameraConfig.ID, cameraConfig.Name, cam, cameraEventFilters)
+		if cam.SupportsEvents() {
+			go intgr.StartSingleCameraEventsProcessingLoop(ctx, cameraConfig.ID, cameraConfig.Name, cam, cameraEventFilters)
+		} else {
+			log.Warnf("Camera model %s doesn't support event streams , events processor for camera %s is not started", cameraConfig.Model, cameraConfig.Name)
+		}
 	}
-	if pollingInterval < 0 {
+	isMetadataEnabled := cameraConfig.Mode == CameraModeCameraMetadata && cam.SupportsMetadata()
+	motionFilter := intgr.newMotionFilter(ctx, cameraConfig)
+	if pollingInterval < 0 && cameraConfig.Schedule.Cron == "" {
 		log.Infof("Polling interval is negative, processor %d will not run", cameraConfig.ID)
 		return nil
 	}
+	captureSchedule, err := schedule.New(cameraConfig.Schedule, pollingInterval)
+	if err != nil {
+		log.Errorf("Invalid schedule of camera %s , processor will not run. Err : %s", cameraConfig.Name, err.Error())
+		intgr.BaseIntegration.StateTracker.ReportProcessorError(cameraConfig.ID, err)
+		return err
+	}
+	if !intgr.waitForCapture(ctx, cameraConfig, captureSchedule.First(time.Now())) {
+		return nil
+	}
 	for {
 
-		intgr.executeProcessorRun(cameraConfig, cam, nil)
+		if cameraConfig.Mode == CameraModePtzTour {
+			intgr.executePtzTourRun(ctx, cameraConfig, cam)
+		} else {
+			intgr.executeFilteredProcessorRun(ctx, cameraConfig, cam, nil, motionFilter)
+		}
 
 		if !intgr.IsRunning {
 			break
 		}
-		// TODO : Randomize delays to distribute load
-		time.Sleep(pollingInterval)
+		if !intgr.waitForCapture(ctx, cameraConfig, captureSchedule.Next(time.Now())) {
+			break
+		}
 		st := intgr.BaseIntegration.StateTracker.GetProcessorState(cameraConfig.ID)
 		if st == nil {
 			break
@@ -208,8 +276,8 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 			}
 		}
 
-		if cameraConfig.Mode == "camera+metadata" {
-			intgr.executeCameraMetadataProcessorRun(cameraConfig, cam)
+		if isMetadataEnabled {
+			intgr.executeCameraMetadataProcessorRun(ctx, cameraConfig, cam)
 		}
 	}
 	log.Infof("Processor %d exited main loop ", cameraConfig.ID)
@@ -217,10 +285,23 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 	return nil
 }
 
+// waitForCapture sleeps until the next scheduled capture. Returns false if ctx is cancelled or there is no next capture.
+func (intgr *CameraImagesToCdf) waitForCapture(ctx context.Context, cameraConfig CameraConfig, delay time.Duration) bool {
+	if delay < 0 {
+		log.Warnf("Schedule of camera %s has no next capture time , processor is stopped", cameraConfig.Name)
+		return false
+	}
+	if delay > time.Minute {
+		log.Debugf("Next capture of camera %s is scheduled at %s", cameraConfig.Name, time.Now().Add(delay).Format(time.RFC3339))
+	}
+	return internal.SleepWithContext(ctx, delay)
+}
+
 // StartSingleCameraEventsProcessingLoop starts a loop to process events from a single camera.
 // It subscribes to the events stream of the specified camera and publishes the events to the event bus and CDF.
-// The loop continues until the IsRunning flag is set to false or an error occurs.
+// The loop continues until ctx is cancelled or the IsRunning flag is set to false.
 // Parameters:
+//   - ctx: The processor context , cancelling it closes the events stream.
 //   - ID: The ID of the camera.
 //   - name: The name of the camera.
 //   - camera: The IP camera object.
@@ -228,7 +309,7 @@ func (intgr *CameraImagesToCdf) startSingleCameraProcessorLoop(cameraConfig Came
 //
 // Returns:
 //   - error: An error if the subscription to the events stream fails or if there is an error publishing the events to CDF.
-func (intgr *CameraImagesToCdf) StartSingleCameraEventsProcessingLoop(ID uint64, name string, camera *inputs.IpCamera, eventFilters []camera.EventFilter) error {
+func (intgr *CameraImagesToCdf) StartSingleCameraEventsProcessingLoop(ctx context.Context, ID uint64, name string, camera *inputs.IpCamera, eventFilters []camera.EventFilter) error {
 	log.Infof("Starting camera events processor %s", name)
 	defer func() {
 		if r := recover(); r != nil {
@@ -236,18 +317,27 @@ func (intgr *CameraImagesToCdf) StartSingleCameraEventsProcessingLoop(ID uint64,
 			log.Error("startProcessor failed to start with error : ", stack)
 		}
 	}()
+	// stateful events that are still active when the processor stops can't be paired anymore
+	defer intgr.closeActiveEvents(ID, eventEndReasonStopped, false)
+	go intgr.runActiveEventsSweeper(ctx, ID)
 	retryCount := 0
 	for {
-		stream, err := camera.SubscribeToEventsStream(eventFilters)
+		stream, err := camera.SubscribeToEventsStream(ctx, eventFilters)
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			retryCount++
+			internal.EventStreamReconnectsTotal.WithLabelValues(name).Inc()
 			log.Infof("Lost connection to camera %s event stream. Reconnecting ...", name)
 			intgr.BaseIntegration.ReportRunStatus(name, core.ExtractionRunStatusFailure, fmt.Sprintf("Lost connection to camera %s event stream. Reconnecting ...", name))
 			retryInterval := intgr.integrationConfig.RetryInterval * retryCount
 			if retryInterval > 600 {
 				retryInterval = 600 // max 10 minutes
 			}
-			time.Sleep(time.Second * time.Duration(retryInterval))
+			if !internal.SleepWithContext(ctx, time.Second*time.Duration(retryInterval)) {
+				break
+			}
 			continue
 
 		} else {
@@ -256,48 +346,72 @@ func (intgr *CameraImagesToCdf) StartSingleCameraEventsProcessingLoop(ID uint64,
 		intgr.BaseIntegration.ReportRunStatus(name, core.ExtractionRunStatusSuccess, fmt.Sprintf("Connected to camera event stream.Camera name : %s", name))
 		for event := range stream {
 			log.Infof("Received event from camera %s : %s", name, event.Type)
+			internal.EventsTotal.WithLabelValues(name).Inc()
+			intgr.countCameraEvent(ID)
 			log.Debugf("Event data : %s", string(event.RawData))
 			log.Debugf("Time from Axis WS stream: %d", event.Timestamp)
-			topic := fmt.Sprintf("%d/%s", ID, event.Topic)
-			intgr.eventbus.TryPub(event, topic)
-			log.Debugf("Event published to event bus. Topic : %s", topic)
-			corellationID := fmt.Sprintf("%d", event.Timestamp)
-			cdfEvents := core.EventList{
-				core.Event{
-					StartTime:   event.Timestamp,
-					EndTime:     event.Timestamp + 1,
-					Type:        event.Type,
-					Subtype:     event.CoreType,
-					Description: "",
-					Metadata:    map[string]string{"cameraName": name, "cameraId": fmt.Sprint(ID), "eventCorrelationId": corellationID, "topic": event.Topic, "rawData": string(event.RawData)},
-					Source:      "edge-extractor:camera",
-				},
-			}
-			_, err := intgr.CogClient.Client().Events.Create(cdfEvents)
-			if err != nil {
-				log.Errorf("Failed to publish event to CDF. Error : %s", err.Error())
-				continue
-			}
+			event.CameraID = ID
+			event.CameraName = name
+			intgr.publishCameraEvent(ctx, event)
 		}
 		log.Infof("Camera events stream has been closed.Camera name : %s", name)
-		if !intgr.IsRunning {
+		if !intgr.IsRunning || ctx.Err() != nil {
 			log.Infof("Camera events processor %s has been stopped.Breaking stream retry loop.", name)
 			break
 		}
+		// inactive events may be lost while the stream is disconnected
+		intgr.closeActiveEvents(ID, eventEndReasonDisconnected, false)
+		internal.EventStreamReconnectsTotal.WithLabelValues(name).Inc()
 	}
 	return nil
 }
 
+// publishCameraEvent publishes camera event to the event bus and writes it to the output using event mapping rules of the camera.
+// CameraID and CameraName of the event must be set.
+func (intgr *CameraImagesToCdf) publishCameraEvent(ctx context.Context, event camera.CameraEvent) {
+	topic := fmt.Sprintf("%d/%s", event.CameraID, event.Topic)
+	intgr.eventbus.TryPub(event, topic, EventBusTopicAll)
+	log.Debugf("Event published to event bus. Topic : %s", topic)
+	outputEvent, isReady := intgr.mapOutputEvent(event)
+	if !isReady {
+		return
+	}
+	err := intgr.output.CreateEvents(ctx, []outputs.Event{outputEvent})
+	if err != nil {
+		log.Errorf("Failed to publish event to %s output. Error : %s", intgr.output.Type(), err.Error())
+		internal.ErrorsTotal.WithLabelValues(event.CameraName, internal.MetricStageEvent).Inc()
+	}
+}
+
+// newOutputEvent converts camera event into output event. CameraID and CameraName of the event must be set.
+func newOutputEvent(event camera.CameraEvent) outputs.Event {
+	corellationID := fmt.Sprintf("%d", event.Timestamp)
+	metadata := map[string]string{"cameraName": event.CameraName, "cameraId": fmt.Sprint(event.CameraID), "eventCorrelationId": corellationID, "topic": event.Topic, "rawData": string(event.RawData)}
+	if event.State != "" {
+		metadata["state"] = event.State
+	}
+	return outputs.Event{
+		StartTime:   event.Timestamp,
+		EndTime:     event.Timestamp + 1,
+		Type:        event.Type,
+		Subtype:     event.CoreType,
+		Description: "",
+		Metadata:    metadata,
+		Source:      "edge-extractor:camera",
+	}
+}
+
 // ExecuteProcessorRunByCameraID executes the processor run for a specific camera ID.
 // It retrieves the camera configuration and camera object based on the provided camera ID,
 // and then calls the executeProcessorRun function to perform the actual processing.
 // The metadata parameter is a map of additional information that can be passed to the processor.
 // WARNING: This function is blocking and should be run in its own goroutine to avoid blocking the main application.
 // Returns an error if any error occurs during the execution.
+// The run is cancelled if the camera processor is stopped.
 func (intgr *CameraImagesToCdf) ExecuteProcessorRunByCameraID(cameraID uint64, metadata map[string]string) error {
 	camera := intgr.cameras[cameraID]
 	cameraConfig := intgr.GetCameraConfigByID(cameraID)
-	return intgr.executeProcessorRun(*cameraConfig, camera, metadata)
+	return intgr.executeProcessorRun(intgr.BaseIntegration.ProcessorContext(cameraID), *cameraConfig, camera, metadata)
 }
 
 func (intgr *CameraImagesToCdf) GetCameraConfigByID(cameraID uint64) *CameraConfig {
@@ -310,79 +424,230 @@ func (intgr *CameraImagesToCdf) GetCameraConfigByID(cameraID uint64) *CameraConf
 }
 
 // executeProcessorRun executes single processor run (full process) , the operation is blocking and must be started in its own goroute for low latency and high throughput
-func (intgr *CameraImagesToCdf) executeProcessorRun(camera CameraConfig, cam *inputs.IpCamera, metadata map[string]string) error {
+func (intgr *CameraImagesToCdf) executeProcessorRun(ctx context.Context, camera CameraConfig, cam *inputs.IpCamera, metadata map[string]string) error {
+	return intgr.executeFilteredProcessorRun(ctx, camera, cam, metadata, nil)
+}
+
+// imageFilter decides if captured image is delivered to the output , the filter may add metadata to the image.
+type imageFilter func(img *camera.Image) bool
+
+// executeFilteredProcessorRun captures image and delivers it to the output if filter is nil or the filter accepts the image.
+func (intgr *CameraImagesToCdf) executeFilteredProcessorRun(ctx context.Context, camera CameraConfig, cam *inputs.IpCamera, metadata map[string]string, filter imageFilter) error {
 	defer func() {
 		if r := recover(); r != nil {
 			stack := string(debug.Stack())
 			log.Error("executeProcessorRun crashed with error : ", stack)
-			intgr.failureCounter++
+			intgr.failureCounter.Add(1)
 			intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("executeProcessorRun crashed with error :%s", stack))
 		}
 	}()
 
-	img, err := cam.ExtractImage()
+	captureStartTime := time.Now()
+	img, err := cam.ExtractImage(ctx)
+	if ctx.Err() != nil {
+		log.Debugf("Processor run for camera %s has been cancelled", camera.Name)
+		return ctx.Err()
+	}
 	if metadata != nil {
 		metadata["capturedAt"] = strconv.FormatInt(time.Now().UnixMilli(), 10)
 	}
 	if err != nil {
+		intgr.recordCaptureDatapoints(camera, captureStartTime, 0, 0, err)
+		internal.ErrorsTotal.WithLabelValues(camera.Name, internal.MetricStageExtract).Inc()
 		log.Errorf("Can't extract image from camera  %s  . Error : %s", camera.Name, err.Error())
-		intgr.failureCounter++
+		intgr.BaseIntegration.StateTracker.ReportProcessorError(camera.ID, err)
+		intgr.failureCounter.Add(1)
 		intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to extract img, err :%s", err.Error()))
-		time.Sleep(time.Second * 20)
+		internal.SleepWithContext(ctx, time.Second*20)
 	} else {
 		if img == nil {
-			time.Sleep(time.Second * 1)
+			internal.SleepWithContext(ctx, time.Second*1)
+			return nil
+		}
+		captureDuration := time.Since(captureStartTime)
+		internal.CaptureDuration.WithLabelValues(camera.Name).Observe(captureDuration.Seconds())
+		intgr.recordCaptureDatapoints(camera, captureStartTime, captureDuration, len(img.Body), nil)
+		intgr.BaseIntegration.StateTracker.ReportProcessorSuccess(camera.ID)
+		// images that are delivered or skipped on purpose (no motion , unchanged) are committed , all other images are rolled back ,
+		// so drivers with transactions (fscam) can archive , delete or retry them
+		isCommitted := false
+		defer func() {
+			if isCommitted {
+				commitImage(camera, cam, img)
+			} else if img.TransactionId != "" {
+				cam.Rollback(img.TransactionId)
+			}
+		}()
+		if filter != nil && !filter(img) {
+			isCommitted = true
+			return nil
+		}
+		isUnchanged, commitDedup := intgr.dedupImage(camera, img, metadata)
+		if isUnchanged {
+			isCommitted = true
+			return nil
+		}
+		if !transformImage(camera, img) {
+			intgr.failureCounter.Add(1)
 			return nil
 		}
 
 		timeStamp := time.Now().Format("2006-01-02T15:04:05.999")
 		externalId := fmt.Sprintf("%s_%d", camera.Name, time.Now().UnixNano())
 		fileName := camera.Name + " " + timeStamp + ".jpeg"
-		retryCount := 0
-		for {
-			err := intgr.BaseIntegration.CogClient.UploadInMemoryFile(img.Body, externalId, fileName, img.Format, camera.LinkedAssetID, metadata)
-			if err != nil {
-				if strings.Contains(err.Error(), "Duplicate external ids") {
-					log.Info("Duplicate external ids error. Errror ignored. Error : ", err.Error())
-					intgr.successCounter++
-					break
-				} else {
-					log.Error("Failed to upload image to CDF. Error : ", err.Error())
-				}
-				intgr.failureCounter++
-				intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to upload img, err :%s", err.Error()))
-				retryCount++
-				if !intgr.IsRunning || retryCount > intgr.integrationConfig.RetryCount {
-					break
-				}
-				time.Sleep(time.Second * time.Duration(intgr.integrationConfig.RetryInterval*retryCount))
-			} else {
-				log.Debug("File uploaded to CDF successfully")
-				intgr.successCounter++
+		isDelivered := true
+		for _, file := range newCaptureFiles(camera, img, externalId, fileName, timeStamp, metadata) {
+			if !intgr.deliverFile(ctx, camera, file) {
+				isDelivered = false
 				break
 			}
 		}
+		if isDelivered {
+			commitDedup()
+			isCommitted = true
+		}
 	}
 	return err
 }
 
-func (intgr *CameraImagesToCdf) executeCameraMetadataProcessorRun(camera CameraConfig, cam *inputs.IpCamera) error {
+// deliverFile writes the file to the spool or uploads it to the output with retries. Returns true if the file has been delivered.
+func (intgr *CameraImagesToCdf) deliverFile(ctx context.Context, camera CameraConfig, file outputs.File) bool {
+	if spool := intgr.spool.Load(); spool != nil {
+		spoolErr := spool.Put(internal.SpoolItem{Source: file.Source, ExternalId: file.ExternalId, Name: file.Name, MimeType: file.MimeType, AssetId: file.AssetId, Metadata: file.Metadata}, file.Body)
+		if spoolErr == nil {
+			log.Debug("Image has been written to spool")
+			return true
+		}
+		internal.ErrorsTotal.WithLabelValues(camera.Name, internal.MetricStageSpool).Inc()
+		log.Error("Failed to write image to spool , uploading directly. Error : ", spoolErr.Error())
+	}
+	retryCount := 0
+	for {
+		err := intgr.uploadFile(ctx, file)
+		if err == nil {
+			log.Debug("File uploaded to CDF successfully")
+			intgr.successCounter.Add(1)
+			return true
+		}
+		if outputs.IsDuplicateError(err) {
+			log.Info("Duplicate external ids error. Errror ignored. Error : ", err.Error())
+			intgr.successCounter.Add(1)
+			return true
+		}
+		log.Errorf("Failed to upload image to %s output. Error : %s", intgr.output.Type(), err.Error())
+		intgr.BaseIntegration.StateTracker.ReportProcessorError(camera.ID, err)
+		intgr.failureCounter.Add(1)
+		intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to upload img, err :%s", err.Error()))
+		retryCount++
+		if !intgr.IsRunning || retryCount > intgr.integrationConfig.RetryCount {
+			return false
+		}
+		if !internal.SleepWithContext(ctx, time.Second*time.Duration(intgr.integrationConfig.RetryInterval*retryCount)) {
+			return false
+		}
+	}
+}
+
+// newCaptureFiles returns captured image and its attachments as output files. Attachments are linked to the image using
+// imageExternalId metadata , the image lists external ids of its attachments in attachmentExternalIds metadata.
+func newCaptureFiles(cameraConfig CameraConfig, img *camera.Image, externalId, fileName, timeStamp string, metadata map[string]string) []outputs.File {
+	imageMetadata := mergeMetadata(metadata, img.Metadata)
+	files := []outputs.File{{Source: cameraConfig.Name, ExternalId: externalId, Name: fileName, MimeType: img.Format, AssetId: cameraConfig.LinkedAssetID, Metadata: imageMetadata, Body: img.Body}}
+	var attachmentExternalIds []string
+	for _, attachment := range img.Attachments {
+		attachmentExternalId := externalId + "_" + attachment.Name
+		attachmentExternalIds = append(attachmentExternalIds, attachmentExternalId)
+		files = append(files, outputs.File{
+			Source:     cameraConfig.Name,
+			ExternalId: attachmentExternalId,
+			Name:       cameraConfig.Name + " " + timeStamp + " " + attachment.Name,
+			MimeType:   attachment.Format,
+			AssetId:    cameraConfig.LinkedAssetID,
+			Metadata:   mergeMetadata(metadata, attachment.Metadata, map[string]string{"imageExternalId": externalId}),
+			Body:       attachment.Body,
+		})
+	}
+	if len(attachmentExternalIds) > 0 {
+		if imageMetadata == nil {
+			imageMetadata = make(map[string]string)
+			files[0].Metadata = imageMetadata
+		}
+		imageMetadata["attachmentExternalIds"] = strings.Join(attachmentExternalIds, ",")
+	}
+	return files
+}
+
+// mergeMetadata returns new map with values of all maps , later maps take precedence. Returns nil if all maps are empty.
+func mergeMetadata(maps ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, m := range maps {
+		for k, v := range m {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// commitImage notifies camera driver that the image has been delivered (uploaded or written to the spool) or skipped on purpose , for example fscam driver archives or deletes the file.
+func commitImage(cameraConfig CameraConfig, cam *inputs.IpCamera, img *camera.Image) {
+	if img.TransactionId == "" {
+		return
+	}
+	if err := cam.Commit(img.TransactionId); err != nil {
+		log.Errorf("Failed to commit image %s from camera %s . Err : %s", img.TransactionId, cameraConfig.Name, err.Error())
+	}
+}
+
+// uploadFile writes file to the integration output and records upload metrics. Duplicate error is returned as is , but not counted as failure.
+func (intgr *CameraImagesToCdf) uploadFile(ctx context.Context, file outputs.File) error {
+	startTime := time.Now()
+	err := intgr.output.UploadFile(ctx, file)
+	if err != nil {
+		if !outputs.IsDuplicateError(err) {
+			internal.ErrorsTotal.WithLabelValues(file.Source, internal.MetricStageUpload).Inc()
+		}
+		return err
+	}
+	internal.UploadDuration.WithLabelValues(file.Source).Observe(time.Since(startTime).Seconds())
+	internal.UploadBytesTotal.WithLabelValues(file.Source).Add(float64(len(file.Body)))
+	internal.UploadsTotal.WithLabelValues(file.Source).Inc()
+	intgr.publishImageToMqtt(ctx, file)
+	return nil
+}
+
+func (intgr *CameraImagesToCdf) executeCameraMetadataProcessorRun(ctx context.Context, camera CameraConfig, cam *inputs.IpCamera) error {
 	defer func() {
 		if r := recover(); r != nil {
 			stack := string(debug.Stack())
 			log.Error("Camera metadata extraction crashed with error : ", stack)
-			intgr.failureCounter++
+			intgr.failureCounter.Add(1)
 			intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("camera metadata extraction crashed with error :%s", stack))
 		}
 	}()
 
-	bmeta, err := cam.ExtractMetadata()
+	if cam.SupportsMeasurements() {
+		measurements, err := cam.ExtractMeasurements(ctx)
+		if err != nil {
+			internal.ErrorsTotal.WithLabelValues(camera.Name, internal.MetricStageMetadata).Inc()
+			log.Errorf("Failed to extract measurements from camera %s . Err : %s", camera.Name, err.Error())
+			intgr.BaseIntegration.ReportRunStatus(camera.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to extract measurements, err :%s", err.Error()))
+			return err
+		}
+		intgr.processMeasurements(ctx, camera, measurements)
+		return nil
+	}
+
+	bmeta, err := cam.ExtractMetadata(ctx)
 	if err == nil {
 		log.Debug("Fetching Metadata from camera:")
 		log.Debug(string(bmeta))
 		// This is just a test.
 		intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusSuccess, string(bmeta))
 	} else {
+		internal.ErrorsTotal.WithLabelValues(camera.Name, internal.MetricStageMetadata).Inc()
 		log.Info("Failed to extract metadata . Err :", err.Error())
 	}
 	return err
@@ -392,10 +657,15 @@ func (intgr *CameraImagesToCdf) StopAndClean() error {
 	intgr.IsRunning = false
 	log.Info("Stopping all camera processors")
 	for ID, camera := range intgr.cameras {
-		camera.Close()
 		intgr.BaseIntegration.StopProcessor(ID)
+		// events are paired using event mapping rules of the current config , so they are closed before the config is replaced
+		intgr.closeActiveEvents(ID, eventEndReasonStopped, false)
+		camera.Close()
 		delete(intgr.cameras, ID)
 	}
+	intgr.stopSpool()
+	intgr.stopMqtt()
+	intgr.stopTimeSeries()
 	log.Info("All camera processors have been stopped")
 
 	return nil
@@ -415,11 +685,11 @@ func (intgr *CameraImagesToCdf) DiscoverCameraCapabilities(camera *inputs.IpCame
 	for _, manifest := range manifests {
 		externalId := fmt.Sprintf("camera_%d_capabilities_manifest", camera.ID)
 		fileName := fmt.Sprintf("camera_%s_capabilities_manifest_%s", camera.Name, manifest.Name)
-		err := intgr.BaseIntegration.CogClient.UploadInMemoryFile(manifest.Body, externalId, fileName, "", 0, nil)
+		err := intgr.output.UploadFile(intgr.BaseIntegration.Context(), outputs.File{Source: camera.Name, ExternalId: externalId, Name: fileName, Body: manifest.Body})
 		if err != nil {
-			log.Infof("Failed to upload services discovery manifest to CDF. Error : %s", err.Error())
+			log.Infof("Failed to upload services discovery manifest to %s output. Error : %s", intgr.output.Type(), err.Error())
 		}
-		log.Infof("Services discovery manifest %s has been uploaded to CDF", manifest.Name)
+		log.Infof("Services discovery manifest %s has been uploaded to %s output", manifest.Name, intgr.output.Type())
 	}
 	return nil
 }
@@ -0,0 +1,131 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cognitedata/edge-extractor/connectors/inputs"
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+)
+
+// testOutput records files and events written by the integration.
+type testOutput struct {
+	mux    sync.Mutex
+	files  []outputs.File
+	events []outputs.Event
+}
+
+func (out *testOutput) UploadFile(ctx context.Context, file outputs.File) error {
+	out.mux.Lock()
+	defer out.mux.Unlock()
+	out.files = append(out.files, file)
+	return nil
+}
+
+func (out *testOutput) CreateEvents(ctx context.Context, events []outputs.Event) error {
+	out.mux.Lock()
+	defer out.mux.Unlock()
+	out.events = append(out.events, events...)
+	return nil
+}
+
+func (out *testOutput) Type() string {
+	return "test"
+}
+
+func (out *testOutput) fileCount() int {
+	out.mux.Lock()
+	defer out.mux.Unlock()
+	return len(out.files)
+}
+
+func newTestIntegration(cameraConfig CameraConfig) (*CameraImagesToCdf, *testOutput) {
+	out := &testOutput{}
+	intgr := NewCameraImagesToCdf(nil, "", nil, nil)
+	intgr.output = out
+	intgr.IsRunning = true
+	intgr.cameraConfigs = []CameraConfig{cameraConfig}
+	return intgr, out
+}
+
+// newWatchedDirCamera returns fscam camera in watch mode that deletes committed files.
+func newWatchedDirCamera(t *testing.T, dir string) *inputs.IpCamera {
+	cam, err := inputs.NewIpCamera(1, "fscam", "fscam", dir, "", "", "", map[string]string{"watch": "true", "completeOn": "rename", "postCommit": "delete"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cam.Close)
+	return cam
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSkippedImagesAreCommitted(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.jpg": "same image", "b.jpg": "same image"})
+	cameraConfig := CameraConfig{ID: 1, Name: "fscam", Dedup: DedupConfig{Mode: imaging.HashExact}}
+	intgr, out := newTestIntegration(cameraConfig)
+	cam := newWatchedDirCamera(t, dir)
+
+	for i := 0; i < 2; i++ {
+		if err := intgr.executeFilteredProcessorRun(context.Background(), cameraConfig, cam, map[string]string{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.fileCount() != 1 {
+		t.Fatalf("expected 1 uploaded file , got %d", out.fileCount())
+	}
+	// both the uploaded and the unchanged image are committed , so they are deleted
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("file %s wasn't committed", name)
+		}
+	}
+
+	// filtered image is committed as well
+	writeTestFiles(t, dir, map[string]string{"c.jpg": "no motion"})
+	rejectAll := func(img *camera.Image) bool { return false }
+	if err := intgr.executeFilteredProcessorRun(context.Background(), cameraConfig, cam, map[string]string{}, rejectAll); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.jpg")); !os.IsNotExist(err) {
+		t.Error("filtered file wasn't committed")
+	}
+	if out.fileCount() != 1 {
+		t.Fatalf("filtered file was uploaded")
+	}
+}
+
+func TestFailedTransformIsRolledBack(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"broken.jpg": "not an image"})
+	cameraConfig := CameraConfig{ID: 1, Name: "fscam", Transforms: []imaging.TransformConfig{{Type: imaging.TransformResize, Width: 100}}}
+	intgr, out := newTestIntegration(cameraConfig)
+	cam := newWatchedDirCamera(t, dir)
+
+	if err := intgr.executeFilteredProcessorRun(context.Background(), cameraConfig, cam, map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.fileCount() != 0 {
+		t.Fatal("file that failed to transform was uploaded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broken.jpg")); err != nil {
+		t.Fatal("file that failed to transform was committed")
+	}
+	// rolled back file is extracted again
+	img, err := cam.ExtractImage(context.Background())
+	if err != nil || img == nil || img.TransactionId != filepath.Join(dir, "broken.jpg") {
+		t.Fatalf("rolled back file isn't extracted again , image %v , err %v", img, err)
+	}
+}
@@ -0,0 +1,55 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/connectors/inputs"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPtzSettleTime = 5 * time.Second
+
+// executePtzTourRun moves the camera to each configured preset , waits for settle time and captures image.
+// Preset name is added to file metadata , images are linked to preset asset if configured.
+// Presets that can't be reached are skipped.
+func (intgr *CameraImagesToCdf) executePtzTourRun(ctx context.Context, cameraConfig CameraConfig, cam *inputs.IpCamera) {
+	for _, preset := range cameraConfig.PtzPresets {
+		if ctx.Err() != nil || !intgr.IsRunning {
+			return
+		}
+		log.Debugf("Moving camera %s to ptz preset %s", cameraConfig.Name, preset.Preset)
+		if err := cam.GotoPreset(ctx, preset.Preset); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("Failed to move camera %s to ptz preset %s . Err : %s", cameraConfig.Name, preset.Preset, err.Error())
+			internal.ErrorsTotal.WithLabelValues(cameraConfig.Name, internal.MetricStagePtz).Inc()
+			intgr.BaseIntegration.StateTracker.ReportProcessorError(cameraConfig.ID, err)
+			intgr.failureCounter.Add(1)
+			intgr.BaseIntegration.ReportRunStatus(cameraConfig.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to move camera to ptz preset %s, err :%s", preset.Preset, err.Error()))
+			continue
+		}
+		if !internal.SleepWithContext(ctx, ptzSettleTime(cameraConfig, preset)) {
+			return
+		}
+		presetCameraConfig := cameraConfig
+		if preset.LinkedAssetID != 0 {
+			presetCameraConfig.LinkedAssetID = preset.LinkedAssetID
+		}
+		intgr.executeProcessorRun(ctx, presetCameraConfig, cam, map[string]string{"ptzPreset": preset.Preset})
+	}
+}
+
+func ptzSettleTime(cameraConfig CameraConfig, preset PtzPresetConfig) time.Duration {
+	if preset.SettleTime > 0 {
+		return time.Duration(preset.SettleTime) * time.Second
+	}
+	if cameraConfig.PtzSettleTime > 0 {
+		return time.Duration(cameraConfig.PtzSettleTime) * time.Second
+	}
+	return defaultPtzSettleTime
+}
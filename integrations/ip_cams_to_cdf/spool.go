@@ -0,0 +1,128 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxSpoolRetryInterval = 600 * time.Second
+
+// startSpool opens the spool and starts background uploader if spool is enabled in integration config.
+// If the spool can't be opened , images are uploaded directly.
+func (intgr *CameraImagesToCdf) startSpool() {
+	intgr.stopSpool()
+	config := intgr.integrationConfig.Spool
+	if !config.Enabled {
+		return
+	}
+	if config.Dir == "" {
+		config.Dir = filepath.Join(internal.GetBinaryDir(), "spool", intgr.BaseIntegration.ID)
+	}
+	if config.MaxSizeMB == 0 {
+		config.MaxSizeMB = 1024
+	}
+	if config.MaxAgeHours == 0 {
+		config.MaxAgeHours = 72
+	}
+	spool, err := internal.NewSpool(config.Dir, int64(config.MaxSizeMB)*1024*1024, time.Duration(config.MaxAgeHours)*time.Hour)
+	if err != nil {
+		log.Errorf("Failed to open spool , images will be uploaded directly. Err : %s", err.Error())
+		intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusFailure, "failed to open spool , err :"+err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(intgr.BaseIntegration.Context())
+	intgr.spoolCancel = cancel
+	intgr.spool.Store(spool)
+	go intgr.runSpoolUploader(ctx, spool)
+}
+
+// stopSpool stops background uploader. Items that haven't been uploaded stay on disk and are uploaded after restart.
+func (intgr *CameraImagesToCdf) stopSpool() {
+	intgr.spool.Store(nil)
+	if intgr.spoolCancel != nil {
+		intgr.spoolCancel()
+		intgr.spoolCancel = nil
+	}
+}
+
+// spoolQueueDepth returns number of images waiting for upload or -1 if spool is disabled.
+func (intgr *CameraImagesToCdf) spoolQueueDepth() int {
+	spool := intgr.spool.Load()
+	if spool == nil {
+		return -1
+	}
+	return spool.Len()
+}
+
+// runSpoolUploader drains the spool in order. Failed uploads are retried with exponential backoff , the item is kept in the spool
+// until it's uploaded or dropped by spool size/age bounds. Items rejected with permanent errors (for example invalid asset id)
+// are moved to dead-letter directory , so they don't block newer items.
+func (intgr *CameraImagesToCdf) runSpoolUploader(ctx context.Context, spool *internal.Spool) {
+	log.Infof("Starting spool uploader. Spool directory : %s", spool.Dir())
+	minRetryInterval := time.Duration(intgr.integrationConfig.RetryInterval) * time.Second
+	if minRetryInterval <= 0 {
+		minRetryInterval = 10 * time.Second
+	}
+	retryInterval := minRetryInterval
+	for {
+		item, body, err := spool.Peek()
+		if err != nil {
+			log.Error("Failed to read spool. Err : ", err.Error())
+		}
+		if item == nil {
+			// spool is empty , waiting for new items. Periodic check is used to enforce age bound.
+			select {
+			case <-ctx.Done():
+				log.Info("Spool uploader has been stopped")
+				return
+			case <-spool.Notify():
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		err = intgr.uploadFile(ctx, outputs.File{Source: item.Source, ExternalId: item.ExternalId, Name: item.Name, MimeType: item.MimeType, AssetId: item.AssetId, Metadata: item.Metadata, Body: body})
+		if ctx.Err() != nil {
+			log.Info("Spool uploader has been stopped")
+			return
+		}
+		if outputs.IsPermanentError(err) && !outputs.IsDuplicateError(err) {
+			log.Errorf("Spooled image %s has been rejected by the output and moved to %s . Error : %s", item.Name, spool.DeadLetterDir(), err.Error())
+			if dlErr := spool.DeadLetter(item); dlErr != nil {
+				log.Error(dlErr.Error())
+			}
+			intgr.failureCounter.Add(1)
+			internal.SpoolDeadLetterTotal.WithLabelValues(item.Source).Inc()
+			internal.ErrorsTotal.WithLabelValues(item.Source, internal.MetricStageSpool).Inc()
+			intgr.BaseIntegration.ReportRunStatus(item.Source, core.ExtractionRunStatusFailure, fmt.Sprintf("spooled image %s has been rejected and moved to dead-letter directory , err :%s", item.Name, err.Error()))
+			retryInterval = minRetryInterval
+			continue
+		}
+		if err != nil && !outputs.IsDuplicateError(err) {
+			log.Errorf("Failed to upload spooled image %s , retry in %s. Error : %s", item.Name, retryInterval, err.Error())
+			intgr.failureCounter.Add(1)
+			if !internal.SleepWithContext(ctx, retryInterval) {
+				log.Info("Spool uploader has been stopped")
+				return
+			}
+			retryInterval *= 2
+			if retryInterval > maxSpoolRetryInterval {
+				retryInterval = maxSpoolRetryInterval
+			}
+			continue
+		}
+		if err != nil {
+			log.Info("Duplicate external ids error. Errror ignored. Error : ", err.Error())
+		}
+		log.Debugf("Spooled image %s uploaded successfully", item.Name)
+		spool.Remove(item)
+		intgr.successCounter.Add(1)
+		retryInterval = minRetryInterval
+	}
+}
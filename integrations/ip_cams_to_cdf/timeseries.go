@@ -0,0 +1,180 @@
+package ip_cams_to_cdf
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+// Camera health metrics written to CDF time series
+const (
+	TimeSeriesMetricUp             = "up"              // 1 after successful capture , 0 after failed capture
+	TimeSeriesMetricCaptureLatency = "capture_latency" // image capture duration in seconds
+	TimeSeriesMetricImageSize      = "image_size"      // captured image size in bytes
+	TimeSeriesMetricEventRate      = "event_rate"      // camera events per minute , calculated every flush interval
+)
+
+var CameraTimeSeriesMetricNames = []string{TimeSeriesMetricUp, TimeSeriesMetricCaptureLatency, TimeSeriesMetricImageSize, TimeSeriesMetricEventRate}
+
+// cameraTimeSeriesMetrics defines time series created for each metric
+var cameraTimeSeriesMetrics = map[string]core.TimeSerie{
+	TimeSeriesMetricUp:             {Name: "up", Description: "Camera availability , 1 after successful capture , 0 after failed capture", IsStep: true},
+	TimeSeriesMetricCaptureLatency: {Name: "capture latency", Description: "Image capture duration", Unit: "s"},
+	TimeSeriesMetricImageSize:      {Name: "image size", Description: "Captured image size", Unit: "byte"},
+	TimeSeriesMetricEventRate:      {Name: "event rate", Description: "Camera events per minute", Unit: "1/min"},
+}
+
+// startTimeSeries starts datapoints writer if time series are enabled for at least one camera or at least one camera runs in camera+metadata mode (measurements).
+func (intgr *CameraImagesToCdf) startTimeSeries() {
+	intgr.stopTimeSeries()
+	isEnabled := false
+	for _, cameraConfig := range intgr.cameraConfigs {
+		isEnabled = isEnabled || (cameraConfig.State == "enabled" && (cameraConfig.TimeSeries.Enabled || cameraConfig.Mode == CameraModeCameraMetadata))
+	}
+	if !isEnabled {
+		return
+	}
+	if intgr.BaseIntegration.CogClient == nil {
+		log.Error("CDF client is not configured , camera time series are disabled")
+		return
+	}
+	config := intgr.integrationConfig.TimeSeries
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 60
+	}
+	writer := internal.NewDatapointsWriter(intgr.BaseIntegration.CogClient, config.MaxBufferedDatapoints)
+	ctx, cancel := context.WithCancel(intgr.BaseIntegration.Context())
+	intgr.tsCancel = cancel
+	intgr.tsWriter.Store(writer)
+	go intgr.runTimeSeriesWriter(ctx, writer, intgr.cameraConfigs, time.Duration(config.FlushInterval)*time.Second)
+}
+
+// stopTimeSeries stops datapoints writer , buffered datapoints are flushed by the writer loop.
+func (intgr *CameraImagesToCdf) stopTimeSeries() {
+	intgr.tsWriter.Store(nil)
+	if intgr.tsCancel != nil {
+		intgr.tsCancel()
+		intgr.tsCancel = nil
+	}
+}
+
+// runTimeSeriesWriter calculates event rates and flushes datapoints every flushInterval until ctx is cancelled.
+func (intgr *CameraImagesToCdf) runTimeSeriesWriter(ctx context.Context, writer *internal.DatapointsWriter, cameraConfigs []CameraConfig, flushInterval time.Duration) {
+	log.Info("Starting camera time series writer")
+	lastFlushTime := time.Now()
+	flush := func() {
+		now := time.Now()
+		intgr.writeEventRates(writer, cameraConfigs, now, now.Sub(lastFlushTime))
+		lastFlushTime = now
+		if err := writer.Flush(); err != nil {
+			log.Errorf("Failed to write camera time series to CDF. Buffered datapoints = %d. Err : %s", writer.Len(), err.Error())
+			internal.ErrorsTotal.WithLabelValues("", internal.MetricStageTimeSeries).Inc()
+		}
+	}
+	for internal.SleepWithContext(ctx, flushInterval) {
+		flush()
+	}
+	flush()
+	log.Info("Camera time series writer has been stopped")
+}
+
+// registerCameraTimeSeries registers time series of enabled metrics for the camera. Time series are created on the next flush.
+func (intgr *CameraImagesToCdf) registerCameraTimeSeries(cameraConfig CameraConfig) {
+	writer := intgr.tsWriter.Load()
+	if writer == nil || !cameraConfig.TimeSeries.Enabled {
+		return
+	}
+	for _, metric := range cameraTimeSeriesMetricsOf(cameraConfig) {
+		if metric == TimeSeriesMetricEventRate && !cameraConfig.EnableCameraEventStream {
+			continue
+		}
+		ts := cameraTimeSeriesMetrics[metric]
+		ts.ExternalID = cameraTimeSeriesExternalId(cameraConfig, metric)
+		ts.Name = fmt.Sprintf("%s %s", cameraConfig.Name, ts.Name)
+		ts.AssetID = cameraConfig.LinkedAssetID
+		ts.Metadata = map[string]string{"cameraName": cameraConfig.Name, "cameraId": fmt.Sprint(cameraConfig.ID), "metric": metric, "source": "edge-extractor"}
+		writer.RegisterTimeSeries(ts)
+	}
+}
+
+// recordCaptureDatapoints writes capture result of the camera. imageSize and latency are ignored if captureErr is not nil.
+func (intgr *CameraImagesToCdf) recordCaptureDatapoints(cameraConfig CameraConfig, captureTime time.Time, latency time.Duration, imageSize int, captureErr error) {
+	writer := intgr.tsWriter.Load()
+	if writer == nil || !cameraConfig.TimeSeries.Enabled {
+		return
+	}
+	for _, metric := range cameraTimeSeriesMetricsOf(cameraConfig) {
+		externalId := cameraTimeSeriesExternalId(cameraConfig, metric)
+		switch metric {
+		case TimeSeriesMetricUp:
+			if captureErr != nil {
+				writer.Add(externalId, captureTime, 0)
+			} else {
+				writer.Add(externalId, captureTime, 1)
+			}
+		case TimeSeriesMetricCaptureLatency:
+			if captureErr == nil {
+				writer.Add(externalId, captureTime, latency.Seconds())
+			}
+		case TimeSeriesMetricImageSize:
+			if captureErr == nil {
+				writer.Add(externalId, captureTime, float64(imageSize))
+			}
+		}
+	}
+}
+
+// countCameraEvent increments events counter of the camera , the counter is used to calculate event rate.
+func (intgr *CameraImagesToCdf) countCameraEvent(cameraID uint64) {
+	counter, _ := intgr.tsEventCounters.LoadOrStore(cameraID, &atomic.Uint64{})
+	counter.(*atomic.Uint64).Add(1)
+}
+
+// writeEventRates writes event rates of cameras with enabled event stream and event_rate metric.
+func (intgr *CameraImagesToCdf) writeEventRates(writer *internal.DatapointsWriter, cameraConfigs []CameraConfig, now time.Time, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	for _, cameraConfig := range cameraConfigs {
+		if cameraConfig.State != "enabled" || !cameraConfig.TimeSeries.Enabled || !cameraConfig.EnableCameraEventStream {
+			continue
+		}
+		if !isCameraTimeSeriesMetricEnabled(cameraConfig, TimeSeriesMetricEventRate) {
+			continue
+		}
+		var count uint64
+		if counter, ok := intgr.tsEventCounters.Load(cameraConfig.ID); ok {
+			count = counter.(*atomic.Uint64).Swap(0)
+		}
+		writer.Add(cameraTimeSeriesExternalId(cameraConfig, TimeSeriesMetricEventRate), now, float64(count)/period.Minutes())
+	}
+}
+
+func cameraTimeSeriesExternalId(cameraConfig CameraConfig, metric string) string {
+	prefix := cameraConfig.TimeSeries.ExternalIdPrefix
+	if prefix == "" {
+		prefix = fmt.Sprintf("edge-extractor:%s:", cameraConfig.Name)
+	}
+	return prefix + metric
+}
+
+func cameraTimeSeriesMetricsOf(cameraConfig CameraConfig) []string {
+	if len(cameraConfig.TimeSeries.Metrics) == 0 {
+		return CameraTimeSeriesMetricNames
+	}
+	return cameraConfig.TimeSeries.Metrics
+}
+
+func isCameraTimeSeriesMetricEnabled(cameraConfig CameraConfig, metric string) bool {
+	for _, m := range cameraTimeSeriesMetricsOf(cameraConfig) {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package ip_cams_to_cdf
+
+import (
+	"time"
+
+	"github.com/cognitedata/edge-extractor/drivers/camera"
+	"github.com/cognitedata/edge-extractor/internal"
+	"github.com/cognitedata/edge-extractor/pkg/imaging"
+	log "github.com/sirupsen/logrus"
+)
+
+// transformImage applies configured transforms to the image body. Returns false if the transform failed , in this case
+// the image must not be uploaded because it may contain unmasked private areas. Attachments are not transformed.
+func transformImage(cameraConfig CameraConfig, img *camera.Image) bool {
+	if len(cameraConfig.Transforms) == 0 {
+		return true
+	}
+	pipeline := cameraConfig.transformPipeline
+	var err error
+	if pipeline == nil {
+		// the pipeline is built by Validate , configs that haven't been validated build it for each image
+		pipeline, err = imaging.NewPipeline(cameraConfig.Transforms)
+	}
+	if err == nil {
+		var body []byte
+		vars := map[string]string{"cameraName": cameraConfig.Name, "timestamp": time.Now().Format("2006-01-02 15:04:05")}
+		if body, err = pipeline.Apply(img.Body, vars); err == nil {
+			img.Body = body
+			img.Format = "image/jpeg"
+			return true
+		}
+	}
+	internal.ErrorsTotal.WithLabelValues(cameraConfig.Name, internal.MetricStageTransform).Inc()
+	log.Errorf("Failed to transform image from camera %s , the image is not uploaded. Err : %s", cameraConfig.Name, err.Error())
+	return false
+}
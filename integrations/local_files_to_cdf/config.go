@@ -0,0 +1,80 @@
+package local_files_to_cdf
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+)
+
+const (
+	PostUploadArchive = "archive" // move uploaded file into archive directory
+	PostUploadDelete  = "delete"  // delete uploaded file
+
+	DefaultExternalIdTemplate = "{dirName}_{relPath}_{modTime}"
+	DefaultNameTemplate       = "{fileName}"
+)
+
+// DirectoryConfig configures single watched directory. Each directory is processed by its own processor.
+type DirectoryConfig struct {
+	ID                 uint64
+	Name               string
+	Path               string
+	Recursive          bool
+	Include            []string // file name glob patterns , for example ["*.csv","*.jpg"] . All files are uploaded if empty
+	Exclude            []string // file name glob patterns of files that must be skipped
+	State              string   // enabled , disabled
+	PollingInterval    int      // directory scan interval in seconds , default 10
+	MinFileAge         int      // file is uploaded only if it hasn't been modified for MinFileAge seconds , default 5
+	ExternalIdTemplate string   // default {dirName}_{relPath}_{modTime}
+	NameTemplate       string   // default {fileName}
+	LinkedAssetID      uint64
+	Metadata           map[string]string // static metadata added to each file
+	PostUpload         string            // archive (default) , delete
+	ArchiveDir         string            // default <Path>/archive
+}
+
+type IntegrationConfig struct {
+	Directories         []DirectoryConfig
+	RetryCount          int
+	RetryInterval       int
+	DisableRunReporting bool
+	Output              outputs.Config // destination of files , CDF by default
+}
+
+// Validate checks directory config and sets default values.
+func (c *DirectoryConfig) Validate() error {
+	var errs []error
+	if c.Path == "" {
+		errs = append(errs, fmt.Errorf("path is required"))
+	}
+	switch c.PostUpload {
+	case "":
+		c.PostUpload = PostUploadArchive
+	case PostUploadArchive, PostUploadDelete:
+	default:
+		errs = append(errs, fmt.Errorf("unknown PostUpload action %q , supported actions : %s , %s", c.PostUpload, PostUploadArchive, PostUploadDelete))
+	}
+	for _, pattern := range append(append([]string{}, c.Include...), c.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid file pattern %q : %w", pattern, err))
+		}
+	}
+	if c.PollingInterval <= 0 {
+		c.PollingInterval = 10
+	}
+	if c.MinFileAge <= 0 {
+		c.MinFileAge = 5
+	}
+	if c.ExternalIdTemplate == "" {
+		c.ExternalIdTemplate = DefaultExternalIdTemplate
+	}
+	if c.NameTemplate == "" {
+		c.NameTemplate = DefaultNameTemplate
+	}
+	if c.ArchiveDir == "" && c.Path != "" {
+		c.ArchiveDir = filepath.Join(c.Path, "archive")
+	}
+	return errors.Join(errs...)
+}
@@ -1,265 +1,359 @@
-// package local_files_to_cdf
+package local_files_to_cdf
 
-// import (
-// 	"fmt"
-// 	"runtime/debug"
-// 	"strconv"
-// 	"sync/atomic"
-// 	"time"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
-// 	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
-// 	"github.com/cognitedata/edge-extractor/connectors/inputs"
-// 	"github.com/cognitedata/edge-extractor/integrations"
-// 	"github.com/cognitedata/edge-extractor/internal"
-// 	log "github.com/sirupsen/logrus"
-// )
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	"github.com/cognitedata/edge-extractor/connectors/outputs"
+	"github.com/cognitedata/edge-extractor/integrations"
+	"github.com/cognitedata/edge-extractor/internal"
+	log "github.com/sirupsen/logrus"
+)
 
-// type LocalFilesToCdf struct {
-// 	integrations.BaseIntegration
-// 	successCounter    uint64
-// 	failureCounter    uint64
-// 	cameraConfigs     []CameraConfig
-// 	secretManager     *internal.SecretManager
-// 	integrationConfig CameraImagesToCdfConfig
-// }
+// LocalFilesToCdf watches local directories and uploads new files to CDF Files (or another output).
+// Uploaded files are archived or deleted , so each file is uploaded once.
+type LocalFilesToCdf struct {
+	integrations.BaseIntegration
+	successCounter    atomic.Uint64
+	failureCounter    atomic.Uint64
+	dirConfigs        []DirectoryConfig
+	integrationConfig IntegrationConfig
+	output            outputs.Output
+}
 
-// func NewLocalFilesToCdf(cogClient *internal.CdfClient, extractoMonitoringID string, remoteConfigSource string) *LocalFilesToCdf {
-// 	ingr := &LocalFilesToCdf{cogClient: cogClient, globalCamPollingInterval: time.Second * 30, stateTracker: internal.NewStateTracker(), extractorID: extractoMonitoringID}
-// 	ingr.configObserver = internal.NewCdfConfigObserver(extractoMonitoringID, cogClient, remoteConfigSource)
-// 	return ingr
-// }
+func NewLocalFilesToCdf(cogClient *internal.CdfClient, extractorMonitoringID string, configObserver *internal.CdfConfigObserver) *LocalFilesToCdf {
+	return &LocalFilesToCdf{
+		BaseIntegration: *integrations.NewIntegration("local_files_to_cdf", cogClient, extractorMonitoringID, configObserver),
+	}
+}
 
-// func (intgr *LocalFilesToCdf) Start() error {
-// 	intgr.isStarted = true
-// 	if intgr.localConfig != nil {
-// 		log.Info("Starting LocalFilesToCdf processing loop using local configurations")
-// 		for _, asset := range intgr.localConfig {
-// 			go intgr.startSingleCameraProcessorLoop(asset)
-// 		}
-// 	} else {
-// 		log.Info("Starting LocalFilesToCdf processing loop using remote configurations")
-// 		actionQueue := intgr.configObserver.Start(60 * time.Second)
-// 		go func() {
-// 			for configAction := range actionQueue {
-// 				log.Debugf("New config action event . Action ID = %d ", configAction.Name)
-// 				switch configAction.Name {
-// 				case internal.RestartProcessorAction:
-// 					// this means metada has been changed .
-// 					if configAction.Asset.Metadata["state"] == "enabled" {
-// 						go intgr.restartProcessor(configAction.Asset)
-// 					} else {
-// 						log.Info("Camera has been disabled , sending STOP signal to processor")
-// 						go intgr.stopProcessor(configAction.ProcId)
-// 					}
+func (intgr *LocalFilesToCdf) LoadConfigFromJson(config json.RawMessage) error {
+	var localConfig IntegrationConfig
+	err := json.Unmarshal(config, &localConfig)
+	if err != nil {
+		log.Error("Failed to unmarshal local config with error : ", err.Error())
+		return err
+	}
+	if localConfig.RetryCount == 0 {
+		localConfig.RetryCount = 3
+	}
+	if localConfig.RetryInterval == 0 {
+		localConfig.RetryInterval = 10
+	}
+	intgr.dirConfigs = localConfig.Directories
+	intgr.integrationConfig = localConfig
+	intgr.BaseIntegration.DisableRunReporting(localConfig.DisableRunReporting)
+	log.Info("Integration config has been loaded successfully. Directories count = ", len(intgr.dirConfigs))
+	return nil
+}
 
-// 				case internal.StartProcessorLoopAction:
-// 					if configAction.Asset.Metadata["state"] == "enabled" {
-// 						go intgr.startSingleCameraProcessorLoop(configAction.Asset)
-// 					} else {
-// 						log.Info("Camera is disabled , operation skipped")
-// 					}
+func (intgr *LocalFilesToCdf) Start() error {
+	intgr.IsRunning = true
+	if len(intgr.dirConfigs) > 0 {
+		intgr.startAllProcessors()
+	} else {
+		log.Info("Starting local files processing loop using remote configurations")
+		configQueue := intgr.BaseIntegration.ConfigObserver.SubscribeToIntegrationConfigUpdates(intgr.BaseIntegration.ID)
+		go func() {
+			isFirstRemoteConfig := true
+			for configAction := range configQueue {
+				log.Info("Config has been changed . Restarting directory processors")
+				if !isFirstRemoteConfig {
+					intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusSuccess, "Config has been changed . Restarting directory processors")
+					intgr.StopAndClean()
+				}
+				intgr.LoadConfigFromJson(configAction.Config)
+				intgr.startAllProcessors()
+				isFirstRemoteConfig = false
+			}
+		}()
+	}
+	go intgr.startSelfMonitoring()
+	return nil
+}
 
-// 				case internal.StopProcessorAction:
-// 					go intgr.stopProcessor(configAction.ProcId)
-// 				default:
-// 					log.Infof("Unknown cofig action %+v", configAction)
-// 				}
-// 			}
-// 		}()
-// 	}
-// 	go intgr.startSelfMonitoring()
-// 	return nil
-// }
+func (intgr *LocalFilesToCdf) startAllProcessors() {
+	intgr.IsRunning = true
+	output, err := outputs.NewOutput(intgr.integrationConfig.Output, intgr.BaseIntegration.CogClient)
+	if err != nil {
+		log.Errorf("Failed to create output , directory processors are not started. Err : %s", err.Error())
+		intgr.BaseIntegration.ReportRunStatus("", core.ExtractionRunStatusFailure, "failed to create output , err :"+err.Error())
+		return
+	}
+	intgr.output = output
+	log.Info("Starting all directory processors")
+	for _, dirConfig := range intgr.dirConfigs {
+		if dirConfig.State != "enabled" {
+			log.Infof("Directory %s is disabled , operation skipped", dirConfig.Name)
+			continue
+		}
+		if err := dirConfig.Validate(); err != nil {
+			log.Errorf("Directory %s has invalid configuration , processor is not started. Err : %s", dirConfig.Name, err.Error())
+			intgr.BaseIntegration.ReportRunStatus(dirConfig.Name, core.ExtractionRunStatusFailure, err.Error())
+			intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(dirConfig.ID, internal.ProcessorStateStopped)
+			intgr.BaseIntegration.StateTracker.SetProcessorName(dirConfig.ID, dirConfig.Name)
+			intgr.BaseIntegration.StateTracker.ReportProcessorError(dirConfig.ID, err)
+			continue
+		}
+		go intgr.startSingleDirectoryProcessorLoop(dirConfig)
+	}
+	log.Info("All directory processors have been started")
+}
 
-// func (intgr *LocalFilesToCdf) Stop() {
-// 	intgr.isStarted = false
-// }
+// StopAndClean stops all directory processors.
+func (intgr *LocalFilesToCdf) StopAndClean() error {
+	intgr.IsRunning = false
+	log.Info("Stopping all directory processors")
+	for _, dirConfig := range intgr.dirConfigs {
+		intgr.BaseIntegration.StopProcessor(dirConfig.ID)
+	}
+	log.Info("All directory processors have been stopped")
+	return nil
+}
 
-// func (intgr *LocalFilesToCdf) restartProcessor(asset core.Asset) {
-// 	intgr.stateTracker.SetProcessorTargetState(asset.ID, internal.ProcessorStateStopped)
-// 	if intgr.stateTracker.WaitForProcessorTargetState(asset.ID, time.Second*120) {
-// 		log.Infof("Processor %d has been stopped", asset.ID)
-// 		intgr.startSingleCameraProcessorLoop(asset)
-// 	} else {
-// 		log.Errorf("Failed to restart processor %d. Previous instance is still running", asset.ID)
-// 	}
+// startSelfMonitoring run a status reporting look that periodically sends status reports to pipeline monitoring
+func (intgr *LocalFilesToCdf) startSelfMonitoring() {
+	for {
+		successCount := intgr.successCounter.Swap(0)
+		failureCount := intgr.failureCounter.Swap(0)
+		if successCount > 0 && failureCount == 0 {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Uploaded %d files", successCount))
+		} else if successCount > 0 && failureCount > 0 {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSuccess, fmt.Sprintf("Uploaded %d files, %d failures", successCount, failureCount))
+		} else if failureCount > 0 {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusFailure, fmt.Sprintf("%d failures", failureCount))
+		} else {
+			intgr.ReportRunStatus("", core.ExtractionRunStatusSeen, "")
+		}
+		time.Sleep(time.Second * 60)
+	}
+}
 
-// }
+// startSingleDirectoryProcessorLoop periodically scans the directory and uploads new files , the operation is blocking and must be started in its own goroute.
+// The processor is stopped when its context is cancelled (see BaseIntegration.StopProcessor).
+func (intgr *LocalFilesToCdf) startSingleDirectoryProcessorLoop(dirConfig DirectoryConfig) {
+	log.Infof("Starting directory processor %s , path = %s , recursive = %t , post upload action = %s", dirConfig.Name, dirConfig.Path, dirConfig.Recursive, dirConfig.PostUpload)
+	defer func() {
+		if r := recover(); r != nil {
+			stack := string(debug.Stack())
+			log.Error("Directory processor crashed with error : ", stack)
+		}
+		intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(dirConfig.ID, internal.ProcessorStateStopped)
+	}()
+	intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(dirConfig.ID, internal.ProcessorStateStarting)
+	intgr.BaseIntegration.StateTracker.SetProcessorTargetState(dirConfig.ID, internal.ProcessorStateRunning)
+	intgr.BaseIntegration.StateTracker.SetProcessorName(dirConfig.ID, dirConfig.Name)
+	ctx := intgr.BaseIntegration.NewProcessorContext(dirConfig.ID)
+	intgr.BaseIntegration.StateTracker.SetProcessorCurrentState(dirConfig.ID, internal.ProcessorStateRunning)
 
-// // startSelfMonitoring run a status reporting look that periodically sends status reports to pipeline monitoring
-// func (intgr *LocalFilesToCdf) startSelfMonitoring() {
-// 	for {
-// 		if intgr.successCounter > 0 && intgr.failureCounter == 0 {
-// 			intgr.reportRunStatus("", core.ExtractionRunStatusSuccess, "all cameras operational")
-// 		} else if intgr.successCounter > 0 && intgr.failureCounter > 0 {
-// 			intgr.reportRunStatus("", core.ExtractionRunStatusSuccess, "some cameras not operational")
-// 		} else {
-// 			intgr.reportRunStatus("", core.ExtractionRunStatusSeen, "")
-// 		}
-// 		intgr.successCounter = 0
-// 		intgr.failureCounter = 0
-// 		time.Sleep(time.Second * 60)
-// 	}
-// }
+	for intgr.IsRunning {
+		intgr.executeProcessorRun(ctx, dirConfig)
+		if !internal.SleepWithContext(ctx, time.Duration(dirConfig.PollingInterval)*time.Second) {
+			break
+		}
+		if intgr.BaseIntegration.StateTracker.GetProcessorState(dirConfig.ID).TargetState == internal.ProcessorStateStopped {
+			break
+		}
+	}
+	log.Infof("Directory processor %s exited main loop", dirConfig.Name)
+}
 
-// // startProcessor starts camera processor , the operation is blocking and must be started in its own goroute
-// func (intgr *LocalFilesToCdf) startSingleCameraProcessorLoop(asset core.Asset) error {
-// 	log.Infof("Starting camera processor %d", asset.ID)
-// 	defer func() {
-// 		if r := recover(); r != nil {
-// 			stack := string(debug.Stack())
-// 			log.Error("startProcessor failed to start with error : ", stack)
-// 		}
-// 		intgr.stateTracker.SetProcessorCurrentState(asset.ID, internal.ProcessorStateStopped)
-// 	}()
+// executeProcessorRun uploads all files that are ready for upload , the oldest files first.
+func (intgr *LocalFilesToCdf) executeProcessorRun(ctx context.Context, dirConfig DirectoryConfig) {
+	files, err := findFilesReadyForUpload(dirConfig)
+	if err != nil {
+		log.Errorf("Failed to scan directory %s . Err : %s", dirConfig.Path, err.Error())
+		intgr.failureCounter.Add(1)
+		intgr.BaseIntegration.StateTracker.ReportProcessorError(dirConfig.ID, err)
+		return
+	}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := intgr.processFile(ctx, dirConfig, file); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("Failed to upload file %s . Err : %s", file.path, err.Error())
+			intgr.failureCounter.Add(1)
+			intgr.BaseIntegration.StateTracker.ReportProcessorError(dirConfig.ID, err)
+			intgr.BaseIntegration.ReportRunStatus(dirConfig.Name, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to upload file %s , err :%s", file.relPath, err.Error()))
+			continue
+		}
+		intgr.successCounter.Add(1)
+		intgr.BaseIntegration.StateTracker.ReportProcessorSuccess(dirConfig.ID)
+	}
+}
 
-// 	intgr.stateTracker.SetProcessorCurrentState(asset.ID, internal.ProcessorStateStarting)
-// 	intgr.stateTracker.SetProcessorTargetState(asset.ID, internal.ProcessorStateRunning)
+// processFile uploads single file with retries and archives or deletes it after successful upload. The file is streamed
+// from disk , only the header is read into memory for mime type detection.
+func (intgr *LocalFilesToCdf) processFile(ctx context.Context, dirConfig DirectoryConfig, file localFile) error {
+	header, err := readFileHeader(file.path)
+	if err != nil {
+		return err
+	}
+	vars := newTemplateVars(dirConfig, file)
+	lookup := func(name string) string { return vars[name] }
+	metadata := map[string]string{"sourcePath": file.path, "modifiedAt": strconv.FormatInt(file.modTime.UnixMilli(), 10)}
+	for k, v := range dirConfig.Metadata {
+		metadata[k] = v
+	}
+	outFile := outputs.File{
+		Source:     dirConfig.Name,
+		ExternalId: internal.ExpandTemplate(dirConfig.ExternalIdTemplate, lookup),
+		Name:       internal.ExpandTemplate(dirConfig.NameTemplate, lookup),
+		MimeType:   detectMimeType(file.path, header),
+		AssetId:    dirConfig.LinkedAssetID,
+		Metadata:   metadata,
+		Path:       file.path,
+	}
+	for retryCount := 0; ; retryCount++ {
+		err = intgr.output.UploadFile(ctx, outFile)
+		if err == nil || outputs.IsDuplicateError(err) || retryCount >= intgr.integrationConfig.RetryCount {
+			break
+		}
+		log.Warnf("Failed to upload file %s , retry in %d sec. Err : %s", file.relPath, intgr.integrationConfig.RetryInterval, err.Error())
+		if !internal.SleepWithContext(ctx, time.Duration(intgr.integrationConfig.RetryInterval)*time.Second) {
+			return ctx.Err()
+		}
+	}
+	if err != nil && !outputs.IsDuplicateError(err) {
+		return err
+	}
+	if err != nil {
+		log.Infof("File %s has already been uploaded. Err : %s", file.relPath, err.Error())
+	}
+	log.Debugf("File %s has been uploaded with external id %s", file.relPath, outFile.ExternalId)
+	return postProcessFile(dirConfig, file)
+}
 
-// 	// TODO : Investigate memmory usage with many cameras and big images . Next step is to introduce image streaming through file system to avoid excessive RAM usag.
-// 	model := asset.Metadata["cog_model"]
-// 	address := asset.Metadata["uri"]
-// 	username := asset.Metadata["username"]
-// 	password := asset.Metadata["password"]
-// 	mode := asset.Metadata["mode"]
+// postProcessFile archives or deletes uploaded file.
+func postProcessFile(dirConfig DirectoryConfig, file localFile) error {
+	if dirConfig.PostUpload == PostUploadDelete {
+		return os.Remove(file.path)
+	}
+	archivePath := filepath.Join(dirConfig.ArchiveDir, file.relPath)
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(archivePath); err == nil {
+		// file with the same name has already been archived
+		ext := filepath.Ext(archivePath)
+		archivePath = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(archivePath, ext), time.Now().UnixNano(), ext)
+	}
+	return os.Rename(file.path, archivePath)
+}
 
-// 	pollingIntervalTmp, err := strconv.Atoi(asset.Metadata["polling_interval"]) // polling interval in seconds
-// 	var pollingInterval time.Duration
+type localFile struct {
+	path    string // full path
+	relPath string // path relative to watched directory
+	modTime time.Time
+	size    int64
+}
 
-// 	if err == nil {
-// 		log.Infof("Non-default polling interval = %d", pollingIntervalTmp)
-// 		pollingInterval = time.Duration(pollingIntervalTmp) * time.Second
-// 	}
+// findFilesReadyForUpload returns files that match include/exclude patterns and haven't been modified for MinFileAge seconds.
+// Files are sorted by modification time. Archive directory is skipped.
+func findFilesReadyForUpload(dirConfig DirectoryConfig) ([]localFile, error) {
+	var files []localFile
+	cutoff := time.Now().Add(-time.Duration(dirConfig.MinFileAge) * time.Second)
+	archiveDir := filepath.Clean(dirConfig.ArchiveDir)
+	root := filepath.Clean(dirConfig.Path)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.Warnf("Can't read %s . Err : %s", path, err.Error())
+			return nil
+		}
+		if entry.IsDir() {
+			if path != root && (!dirConfig.Recursive || path == archiveDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !isFileNameMatched(dirConfig, entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		relPath, _ := filepath.Rel(root, path)
+		files = append(files, localFile{path: path, relPath: relPath, modTime: info.ModTime(), size: info.Size()})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files, err
+}
 
-// 	if pollingInterval < 1 && model != "fscam" {
-// 		pollingInterval = intgr.globalCamPollingInterval
-// 	}
+func isFileNameMatched(dirConfig DirectoryConfig, name string) bool {
+	for _, pattern := range dirConfig.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(dirConfig.Include) == 0 {
+		return true
+	}
+	for _, pattern := range dirConfig.Include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
 
-// 	maxParallelRuns, _ := strconv.Atoi(asset.Metadata["max_parallel_runs"]) // polling interval in seconds
-// 	if maxParallelRuns == 0 {
-// 		maxParallelRuns = 1
-// 	}
+// newTemplateVars returns variables that can be used in external id and name templates.
+func newTemplateVars(dirConfig DirectoryConfig, file localFile) map[string]string {
+	fileName := filepath.Base(file.path)
+	ext := filepath.Ext(fileName)
+	return map[string]string{
+		"dirName":  dirConfig.Name,
+		"fileName": fileName,
+		"baseName": strings.TrimSuffix(fileName, ext),
+		"ext":      strings.TrimPrefix(ext, "."),
+		"relPath":  filepath.ToSlash(file.relPath),
+		"modTime":  strconv.FormatInt(file.modTime.UnixMilli(), 10),
+		"date":     file.modTime.Format("2006-01-02"),
+		"size":     strconv.FormatInt(file.size, 10),
+	}
+}
 
-// 	log.Infof("Camera name = %s, model = %s, address = %s, username = %s, mode = %s, max parallel runs = %d", asset.Name, model, address, username, mode, maxParallelRuns)
+// readFileHeader returns first 512 bytes of the file , which is enough for content type detection.
+func readFileHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
 
-// 	if model == "" || address == "" {
-// 		log.Errorf("Processor can't be started for camera %s . Model or address aren't set.", asset.Name)
-// 		return fmt.Errorf("empty asset model or address")
-// 	}
-
-// 	cam := inputs.NewIpCamera(model, address, "", username, password)
-// 	if cam == nil {
-// 		log.Error("Unsupported camera model")
-// 		return fmt.Errorf("unsupported camera model")
-// 	}
-// 	intgr.stateTracker.SetProcessorCurrentState(asset.ID, internal.ProcessorStateRunning)
-// 	var concurentRunsCounter atomic.Int32
-// 	concurentRunsCounter.Store(0)
-// 	for {
-// 		if !intgr.isStarted {
-// 			break
-// 		}
-// 		runner := func() {
-// 			intgr.executeProcessorRun(asset, cam)
-// 			if mode == "camera+metadata" {
-// 				intgr.executeCameraMetadataProcessorRun(asset, cam)
-// 			}
-// 			concurentRunsCounter.Add(-1)
-// 		}
-// 		if maxParallelRuns > 1 {
-// 			if concurentRunsCounter.Load() >= int32(maxParallelRuns) {
-// 				log.Debugf("Max parallel runs reached for camera %s . Waiting for previous runs to finish. Max runs = %d , counter = %d", asset.Name, maxParallelRuns, concurentRunsCounter.Load())
-// 				time.Sleep(time.Second * 2)
-// 				continue
-// 			} else {
-// 				// Concurent worker
-// 				concurentRunsCounter.Add(1)
-// 				go runner()
-// 			}
-// 		} else {
-// 			// Single worker
-// 			concurentRunsCounter.Add(1)
-// 			runner()
-// 		}
-// 		// TODO : Randomize delays to distribute load
-// 		if pollingInterval > 0 {
-// 			time.Sleep(pollingInterval)
-// 		}
-// 		st := intgr.stateTracker.GetProcessorState(asset.ID)
-// 		if st == nil {
-// 			break
-// 		} else {
-// 			if st.TargetState == internal.ProcessorStateStopped {
-// 				break
-// 			}
-// 		}
-
-// 	}
-// 	log.Infof("Processor %d exited main loop ", asset.ID)
-// 	return nil
-// }
-
-// // executeProcessorRun
-// func (intgr *LocalFilesToCdf) executeProcessorRun(asset core.Asset, cam *inputs.IpCamera) error {
-// 	defer func() {
-// 		if r := recover(); r != nil {
-// 			stack := string(debug.Stack())
-// 			log.Error("executeProcessorRun crashed with error : ", stack)
-// 			intgr.failureCounter++
-// 			intgr.reportRunStatus(asset.ExternalID, core.ExtractionRunStatusFailure, fmt.Sprintf("executeProcessorRun crashed with error :%s", stack))
-// 		}
-// 	}()
-
-// 	img, err := cam.ExtractImage()
-// 	log.Debugf("Image extracted from camera ")
-// 	if err != nil {
-// 		log.Debugf("Can't extract image from camera  %s  . Error : %s", asset.Name, err.Error())
-// 		intgr.failureCounter++
-// 		intgr.reportRunStatus(asset.ExternalID, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to extract img, err :%s", err.Error()))
-// 		time.Sleep(time.Second * 60)
-// 	} else {
-// 		if img == nil {
-// 			time.Sleep(time.Second * 10)
-// 			return nil
-// 		}
-
-// 		timeStamp := time.Now().Format(time.RFC3339)
-
-// 		externalId := asset.Name + "_" + timeStamp + "_" + img.ExternalId
-// 		fileName := externalId + ".jpeg"
-// 		err := intgr.cogClient.UploadInMemoryFile(img.Body, externalId, fileName, img.Format, asset.ID)
-// 		if err != nil {
-// 			log.Debug("Can't upload image. Error : ", err.Error())
-// 			intgr.failureCounter++
-// 			intgr.reportRunStatus(asset.ExternalID, core.ExtractionRunStatusFailure, fmt.Sprintf("failed to upload img, err :%s", err.Error()))
-// 			time.Sleep(time.Second * 60)
-// 		} else {
-// 			log.Debug("File uploaded to CDF successfully")
-// 			intgr.successCounter++
-// 			cam.Commit(img.TransactionId)
-// 		}
-// 	}
-// 	return err
-// }
-
-// func (intgr *LocalFilesToCdf) executeCameraMetadataProcessorRun(asset core.Asset, cam *inputs.IpCamera) error {
-// 	defer func() {
-// 		if r := recover(); r != nil {
-// 			stack := string(debug.Stack())
-// 			log.Error("Camera metadata extraction crashed with error : ", stack)
-// 			intgr.failureCounter++
-// 			intgr.reportRunStatus(asset.ExternalID, core.ExtractionRunStatusFailure, fmt.Sprintf("camera metadata extraction crashed with error :%s", stack))
-// 		}
-// 	}()
-
-// 	bmeta, err := cam.ExtractMetadata()
-// 	if err == nil {
-// 		log.Debug("Fetching Metadata from camera:")
-// 		log.Debug(string(bmeta))
-// 		// This is just a test.
-// 		intgr.reportRunStatus("", core.ExtractionRunStatusSuccess, string(bmeta))
-// 	} else {
-// 		log.Info("Failed to extract metadata . Err :", err.Error())
-// 	}
-// 	return err
-// }
+// detectMimeType detects mime type by file extension or by content if extension is unknown.
+func detectMimeType(path string, body []byte) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType
+	}
+	return http.DetectContentType(body)
+}
@@ -2,6 +2,7 @@ package digest
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
@@ -19,6 +20,7 @@ type DigestRequest struct {
 	Wa         *wwwAuthenticate
 	CertVal    bool
 	HTTPClient *http.Client
+	Context    context.Context // optional , used to cancel requests
 }
 
 type DigestTransport struct {
@@ -43,6 +45,13 @@ func NewTransport(username, password string) DigestTransport {
 	return dt
 }
 
+func (dr *DigestRequest) getContext() context.Context {
+	if dr.Context != nil {
+		return dr.Context
+	}
+	return context.Background()
+}
+
 func (dr *DigestRequest) getHTTPClient() *http.Client {
 	if dr.HTTPClient != nil {
 		return dr.HTTPClient
@@ -73,7 +82,7 @@ func (dr *DigestRequest) UpdateRequest(username, password, method, uri, body str
 	return dr
 }
 
-// RoundTrip implements the http.RoundTripper interface
+// RoundTrip implements the http.RoundTripper interface. Unlike the upstream client , request context and headers are preserved.
 func (dt *DigestTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
 	username := dt.Username
 	password := dt.Password
@@ -88,6 +97,11 @@ func (dt *DigestTransport) RoundTrip(req *http.Request) (resp *http.Response, er
 	}
 
 	dr := NewRequest(username, password, method, uri, body)
+	dr.Context = req.Context()
+	dr.Header = req.Header.Clone()
+	if dr.Header == nil {
+		dr.Header = make(http.Header)
+	}
 	if dt.HTTPClient != nil {
 		dr.HTTPClient = dt.HTTPClient
 	}
@@ -103,7 +117,7 @@ func (dr *DigestRequest) Execute() (resp *http.Response, err error) {
 	}
 
 	var req *http.Request
-	if req, err = http.NewRequest(dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
+	if req, err = http.NewRequestWithContext(dr.getContext(), dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
 		return nil, err
 	}
 	req.Header = dr.Header
@@ -125,7 +139,7 @@ func (dr *DigestRequest) Execute() (resp *http.Response, err error) {
 func (dr *DigestRequest) GetNewDigestAuthHeader() (header string, err error) {
 	var req *http.Request
 	var resp *http.Response
-	if req, err = http.NewRequest(dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
+	if req, err = http.NewRequestWithContext(dr.getContext(), dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
 		return "", err
 	}
 	req.Header = dr.Header
@@ -207,7 +221,7 @@ func (dr *DigestRequest) executeExistingDigest() (resp *http.Response, err error
 func (dr *DigestRequest) executeRequest(authString string) (resp *http.Response, err error) {
 	var req *http.Request
 
-	if req, err = http.NewRequest(dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
+	if req, err = http.NewRequestWithContext(dr.getContext(), dr.Method, dr.URI, bytes.NewReader([]byte(dr.Body))); err != nil {
 		return nil, err
 	}
 	req.Header = dr.Header
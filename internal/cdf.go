@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -13,6 +14,16 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// HTTPStatusError is returned if the server responded with non-successful status code.
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "unexpected http status " + e.Status
+}
+
 type CdfClient struct {
 	client    *cognite.Client
 	dataSetId int
@@ -61,7 +72,41 @@ func (co *CdfClient) UploadFile(filePath, externalId, name, mimeType string, ass
 	return co.BasicUploadFileBody(filePath, name, mimeType, uploadUrl.UploadUrl)
 }
 
-func (co *CdfClient) UploadInMemoryFile(body []byte, externalId, name, mimeType string, assetId uint64, metadata map[string]string) error {
+// UploadLocalFile creates file metadata in CDF and streams the body from the local file , so large files are not loaded into memory.
+// Body upload is aborted if ctx is cancelled.
+func (co *CdfClient) UploadLocalFile(ctx context.Context, filePath, externalId, name, mimeType string, assetId uint64, metadata map[string]string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fileMetadata := core.CreateFileMetadata{ExternalId: externalId, Name: name, MimeType: mimeType, DataSetId: co.dataSetId, Source: "edge-extractor", Metadata: metadata}
+	if assetId != 0 {
+		fileMetadata.AssetIds = []uint64{assetId}
+	}
+
+	uploadUrl, err := co.client.Files.Create(fileMetadata)
+	if err != nil {
+		log.Error("Upload error : ", err.Error())
+		return err
+	}
+	log.Debugf("Uploading file %s (%d bytes) using URL: %s", filePath, stat.Size(), uploadUrl.UploadUrl)
+	return co.uploadBody(ctx, file, stat.Size(), mimeType, uploadUrl.UploadUrl)
+}
+
+// UploadInMemoryFile creates file metadata in CDF and uploads the body. Body upload is aborted if ctx is cancelled.
+func (co *CdfClient) UploadInMemoryFile(ctx context.Context, body []byte, externalId, name, mimeType string, assetId uint64, metadata map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	fileMetadata := core.CreateFileMetadata{ExternalId: externalId, Name: name, MimeType: mimeType, DataSetId: co.dataSetId, Source: "edge-extractor", Metadata: metadata}
 	if assetId != 0 {
@@ -74,7 +119,25 @@ func (co *CdfClient) UploadInMemoryFile(body []byte, externalId, name, mimeType
 		return err
 	}
 	log.Debug("Uploading file using URL:", uploadUrl)
-	return co.UploadInMemoryBody(body, name, mimeType, uploadUrl.UploadUrl)
+	return co.UploadInMemoryBody(ctx, body, name, mimeType, uploadUrl.UploadUrl)
+}
+
+// CreateOrUpdateTimeSeries creates time series or updates existing ones with the same external ids.
+// Note : the SDK doesn't support data sets for time series , so time series are created without data set.
+func (co *CdfClient) CreateOrUpdateTimeSeries(timeSeries []core.TimeSerie) error {
+	if len(timeSeries) == 0 {
+		return nil
+	}
+	_, err := co.client.TimeSeries.CreateOrUpdate(timeSeries)
+	return err
+}
+
+// InsertDatapoints writes datapoints to time series identified by external id.
+func (co *CdfClient) InsertDatapoints(externalId string, datapoints []core.Datapoint) error {
+	if len(datapoints) == 0 {
+		return nil
+	}
+	return co.client.TimeSeries.InsertDatapointsExt(datapoints, externalId)
 }
 
 // UploadMultipartFileBody currently not supported by CDF
@@ -181,15 +244,19 @@ func (co *CdfClient) BasicUploadFileBody(filePath, fileName, mimeType, uploadUrl
 
 }
 
-func (co *CdfClient) UploadInMemoryBody(body []byte, fileName, mimeType, uploadUrl string) error {
+func (co *CdfClient) UploadInMemoryBody(ctx context.Context, body []byte, fileName, mimeType, uploadUrl string) error {
 	log.Debug("Uploading file")
-	buf := bytes.NewReader(body)
+	return co.uploadBody(ctx, bytes.NewReader(body), int64(len(body)), mimeType, uploadUrl)
+}
 
-	req, err := http.NewRequest("PUT", uploadUrl, buf)
-	req.Header.Set("Content-Type", mimeType)
+// uploadBody uploads size bytes from reader to upload URL.
+func (co *CdfClient) uploadBody(ctx context.Context, reader io.Reader, size int64, mimeType, uploadUrl string) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadUrl, reader)
 	if err != nil {
 		return err
 	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", mimeType)
 
 	hClient := &http.Client{}
 	resp, err := hClient.Do(req)
@@ -209,6 +276,9 @@ func (co *CdfClient) UploadInMemoryBody(body []byte, fileName, mimeType, uploadU
 	if resp.Body != nil {
 		resp.Body.Close()
 	}
+	if resp.StatusCode >= 300 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
 
 	return nil
 
@@ -3,6 +3,7 @@ package internal
 import (
 	"encoding/json"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -29,7 +30,7 @@ type CdfConfigObserver struct {
 	configUpdatesQueue     map[string]ConfigActionQueue
 	appsConfigUpdatesQueue ConfigActionQueue
 	secretManager          *SecretManager
-	configRevision         int
+	configRevision         atomic.Int64 // read by status server while config loop updates it
 }
 
 type ConfigAction struct {
@@ -42,14 +43,15 @@ type ConfigAction struct {
 type ConfigActionQueue chan ConfigAction
 
 func NewCdfConfigObserver(extractorID string, cogClient *CdfClient, remoteConfigSource string, secretManager *SecretManager) *CdfConfigObserver {
-	return &CdfConfigObserver{extractorID: extractorID,
+	observer := &CdfConfigObserver{extractorID: extractorID,
 		cogClient:              cogClient,
 		remoteConfigSource:     remoteConfigSource,
 		configUpdatesQueue:     make(map[string]ConfigActionQueue),
 		appsConfigUpdatesQueue: make(ConfigActionQueue),
 		secretManager:          secretManager,
-		configRevision:         -1,
 	}
+	observer.configRevision.Store(-1)
+	return observer
 }
 
 // Start starts observer process using provided asset filter and reload interval. The operation is non-blocking
@@ -89,6 +91,11 @@ func (intgr *CdfConfigObserver) SubscribeToAppsConfigUpdates() ConfigActionQueue
 	return intgr.appsConfigUpdatesQueue
 }
 
+// GetConfigRevision returns revision of the latest remote config , -1 if remote config hasn't been loaded.
+func (intgr *CdfConfigObserver) GetConfigRevision() int {
+	return int(intgr.configRevision.Load())
+}
+
 func (intgr *CdfConfigObserver) Stop() {
 	log.Info("Stopping CDF config observer")
 	intgr.isStarted = false
@@ -118,10 +125,11 @@ func (intgr *CdfConfigObserver) reloadRemoteConfigs() error {
 			return err
 		}
 
-		if intgr.configRevision == remoteConfig.Revision {
+		if intgr.configRevision.Load() == int64(remoteConfig.Revision) {
 			return nil
 		} else {
-			intgr.configRevision = remoteConfig.Revision
+			intgr.configRevision.Store(int64(remoteConfig.Revision))
+			ConfigRevision.Set(float64(remoteConfig.Revision))
 			log.Infof("New config revision has been loaded. Revision : %d", remoteConfig.Revision)
 		}
 
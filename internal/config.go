@@ -25,6 +25,8 @@ type StaticConfig struct {
 	EnabledIntegrations  []string
 	LogLevel             string
 	LogDir               string
+	MetricsListenAddress string // address of Prometheus /metrics endpoint , for example ":9100" . Disabled if empty
+	StatusListenAddress  string // address of local status API (/healthz , /readyz , /status) , for example "127.0.0.1:9101" . Disabled if empty
 
 	Integrations map[string]json.RawMessage // map of integration configs (key is integration name, value is integration config)
 	Apps         json.RawMessage            // map of app configs (key is app name, value is app config)
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const metricsNamespace = "edge_extractor"
+
+// Error stages used as "stage" label of ErrorsTotal metric
+const (
+	MetricStageExtract    = "extract"
+	MetricStageUpload     = "upload"
+	MetricStageEvent      = "event"
+	MetricStageMetadata   = "metadata"
+	MetricStageSpool      = "spool"
+	MetricStageMqtt       = "mqtt"
+	MetricStageTimeSeries = "timeseries"
+	MetricStagePtz        = "ptz"
+	MetricStageTransform  = "transform"
+)
+
+// Skip reasons used as "reason" label of ImagesSkippedTotal metric
+const (
+	MetricSkipReasonUnchanged = "unchanged"
+	MetricSkipReasonNoMotion  = "no_motion"
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	CaptureDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "capture_duration_seconds",
+		Help:      "Time spent to extract image from camera.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15, 30},
+	}, []string{"camera"})
+
+	UploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Time spent to upload image to output.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"camera"})
+
+	UploadBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_bytes_total",
+		Help:      "Total number of bytes uploaded to output.",
+	}, []string{"camera"})
+
+	UploadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "uploads_total",
+		Help:      "Total number of images uploaded to output.",
+	}, []string{"camera"})
+
+	ErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "errors_total",
+		Help:      "Total number of errors by processing stage (extract, upload, event, metadata, spool, mqtt).",
+	}, []string{"camera", "stage"})
+
+	ImagesSkippedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "images_skipped_total",
+		Help:      "Total number of captured images that were not uploaded by reason (unchanged, no_motion).",
+	}, []string{"camera", "reason"})
+
+	SpoolDeadLetterTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "spool_dead_letter_total",
+		Help:      "Total number of spooled images moved to dead-letter directory because of permanent upload errors.",
+	}, []string{"camera"})
+
+	EventStreamReconnectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "event_stream_reconnects_total",
+		Help:      "Total number of camera event stream reconnects.",
+	}, []string{"camera"})
+
+	EventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "events_total",
+		Help:      "Total number of events received from camera event streams.",
+	}, []string{"camera"})
+
+	ConfigRevision = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_revision",
+		Help:      "Revision of remote configuration currently applied , -1 if remote configuration is not used.",
+	})
+)
+
+var processorStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "processor_state"),
+	"Current state of processor , the value is 1 for the current state.",
+	[]string{"integration", "processor", "state"}, nil,
+)
+
+// stateTrackersCollector exports states of all registered StateTrackers on each scrape.
+type stateTrackersCollector struct {
+	trackers map[string]*StateTracker
+	mux      sync.Mutex
+}
+
+var stateTrackers = &stateTrackersCollector{trackers: make(map[string]*StateTracker)}
+
+func (c *stateTrackersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- processorStateDesc
+}
+
+func (c *stateTrackersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for integration, tracker := range c.trackers {
+		for _, st := range tracker.GetAllProcessorStates() {
+			ch <- prometheus.MustNewConstMetric(processorStateDesc, prometheus.GaugeValue, 1, integration, strconv.FormatUint(st.ID, 10), st.CurrentState)
+		}
+	}
+}
+
+func init() {
+	ConfigRevision.Set(-1)
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		CaptureDuration,
+		UploadDuration,
+		UploadBytesTotal,
+		UploadsTotal,
+		ErrorsTotal,
+		ImagesSkippedTotal,
+		SpoolDeadLetterTotal,
+		EventStreamReconnectsTotal,
+		EventsTotal,
+		ConfigRevision,
+		stateTrackers,
+	)
+}
+
+// RegisterStateTracker registers integration state tracker. Processor states are exported as edge_extractor_processor_state metric
+// and by status API.
+func RegisterStateTracker(integration string, tracker *StateTracker) {
+	stateTrackers.mux.Lock()
+	defer stateTrackers.mux.Unlock()
+	stateTrackers.trackers[integration] = tracker
+}
+
+// getStateTrackers returns copy of registered state trackers , key is integration name.
+func getStateTrackers() map[string]*StateTracker {
+	stateTrackers.mux.Lock()
+	defer stateTrackers.mux.Unlock()
+	trackers := make(map[string]*StateTracker, len(stateTrackers.trackers))
+	for k, v := range stateTrackers.trackers {
+		trackers[k] = v
+	}
+	return trackers
+}
+
+// MetricsHandler returns HTTP handler that serves metrics in Prometheus text format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// StartMetricsServer starts HTTP server that exposes /metrics endpoint. The operation is non-blocking.
+// If metrics and status API share the same address , metrics are served by status server instead (see StatusServer).
+func StartMetricsServer(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	go func() {
+		log.Infof("Starting metrics server on %s", address)
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Errorf("Metrics server failed. Err : %s", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,334 @@
+// Package schedule computes capture times from cron expressions , polling intervals , active time windows and random jitter.
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Config configures capture schedule of a camera. Captures are triggered by Cron expression or by the polling interval ,
+// only inside Windows (if configured). Jitter adds random delay to each capture to distribute load.
+type Config struct {
+	Cron      string   // standard 5 field cron expression , for example "*/5 6-18 * * mon-fri" . Polling interval is used if empty
+	Windows   []Window // active time windows , captures run only inside the windows. Always active if empty
+	Jitter    int      // max random delay in seconds added to each capture
+	Timezone  string   // IANA time zone name of Cron and Windows , for example "Europe/Oslo" . Local time zone is used if empty
+	Latitude  float64  // camera location in degrees , positive to north , required by sunrise and sunset windows
+	Longitude float64  // camera location in degrees , positive to east , required by sunrise and sunset windows
+}
+
+// IsEmpty returns true if nothing is configured , the plain polling interval is used in this case.
+func (c *Config) IsEmpty() bool {
+	return c.Cron == "" && len(c.Windows) == 0 && c.Jitter == 0 && c.Timezone == ""
+}
+
+// IsEqual compares Config with another Config
+func (c *Config) IsEqual(other *Config) bool {
+	if len(c.Windows) != len(other.Windows) {
+		return false
+	}
+	for i := range c.Windows {
+		if !c.Windows[i].IsEqual(&other.Windows[i]) {
+			return false
+		}
+	}
+	return c.Cron == other.Cron &&
+		c.Jitter == other.Jitter &&
+		c.Timezone == other.Timezone &&
+		c.Latitude == other.Latitude &&
+		c.Longitude == other.Longitude
+}
+
+// Window is a daily active time window. Window ends next day if End is before Start , for example 22:00-06:00 or sunset-sunrise.
+type Window struct {
+	Days  []string // week days when the window starts , for example ["mon","tue"] . Every day if empty
+	Start string   // HH:MM , sunrise or sunset with optional offset , for example sunrise+30m or sunset-1h15m
+	End   string   // HH:MM , sunrise or sunset with optional offset
+}
+
+// IsEqual compares Window with another Window
+func (w *Window) IsEqual(other *Window) bool {
+	if len(w.Days) != len(other.Days) {
+		return false
+	}
+	for i := range w.Days {
+		if w.Days[i] != other.Days[i] {
+			return false
+		}
+	}
+	return w.Start == other.Start && w.End == other.End
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// windowSearchHorizon limits search of cron time inside of windows , it covers all week days and seasons of sun based windows.
+const windowSearchHorizon = 400 * 24 * time.Hour
+
+// Sun anchors of window times
+const (
+	anchorSunrise = "sunrise"
+	anchorSunset  = "sunset"
+)
+
+// timeOfDay is parsed window time , offset is from midnight for clock time and from anchor for sunrise and sunset.
+type timeOfDay struct {
+	anchor string
+	offset time.Duration
+}
+
+// nominal returns approximate offset from midnight , it's used to decide if the window ends next day.
+func (t timeOfDay) nominal() time.Duration {
+	switch t.anchor {
+	case anchorSunrise:
+		return 6*time.Hour + t.offset
+	case anchorSunset:
+		return 18*time.Hour + t.offset
+	}
+	return t.offset
+}
+
+// window is parsed Window.
+type window struct {
+	days            [7]bool
+	start           timeOfDay
+	end             timeOfDay
+	crossesMidnight bool
+	isSunBased      bool
+}
+
+// Schedule computes capture times.
+type Schedule struct {
+	cron      *Cron
+	interval  time.Duration
+	windows   []window
+	jitter    time.Duration
+	location  *time.Location
+	latitude  float64
+	longitude float64
+}
+
+// New validates the config and returns new schedule. interval is used if Cron is not set.
+func New(config Config, interval time.Duration) (*Schedule, error) {
+	s := &Schedule{
+		interval:  interval,
+		jitter:    time.Duration(config.Jitter) * time.Second,
+		location:  time.Local,
+		latitude:  config.Latitude,
+		longitude: config.Longitude,
+	}
+	if config.Jitter < 0 {
+		return nil, fmt.Errorf("schedule Jitter must not be negative")
+	}
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule Timezone %q : %w", config.Timezone, err)
+		}
+		s.location = location
+	}
+	if config.Cron != "" {
+		cron, err := ParseCron(config.Cron)
+		if err != nil {
+			return nil, err
+		}
+		s.cron = cron
+	} else if interval <= 0 {
+		return nil, fmt.Errorf("schedule requires Cron expression or positive polling interval")
+	}
+	if config.Latitude < -90 || config.Latitude > 90 || config.Longitude < -180 || config.Longitude > 180 {
+		return nil, fmt.Errorf("schedule Latitude must be between -90 and 90 and Longitude between -180 and 180")
+	}
+	for i, w := range config.Windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %d : %w", i, err)
+		}
+		if parsed.isSunBased && config.Latitude == 0 && config.Longitude == 0 {
+			return nil, fmt.Errorf("schedule window %d : Latitude and Longitude are required by sunrise and sunset windows", i)
+		}
+		s.windows = append(s.windows, parsed)
+	}
+	return s, nil
+}
+
+func parseWindow(w Window) (window, error) {
+	var parsed window
+	if len(w.Days) == 0 {
+		for i := range parsed.days {
+			parsed.days[i] = true
+		}
+	}
+	for _, day := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return parsed, fmt.Errorf("invalid day %q , supported days : mon, tue, wed, thu, fri, sat, sun", day)
+		}
+		parsed.days[weekday] = true
+	}
+	var err error
+	if parsed.start, err = parseTimeOfDay(w.Start); err != nil {
+		return parsed, fmt.Errorf("invalid Start : %w", err)
+	}
+	if parsed.end, err = parseTimeOfDay(w.End); err != nil {
+		return parsed, fmt.Errorf("invalid End : %w", err)
+	}
+	if parsed.start == parsed.end {
+		return parsed, fmt.Errorf("Start and End must be different")
+	}
+	parsed.crossesMidnight = parsed.end.nominal() <= parsed.start.nominal()
+	parsed.isSunBased = parsed.start.anchor != "" || parsed.end.anchor != ""
+	return parsed, nil
+}
+
+// parseTimeOfDay parses HH:MM or sunrise / sunset with optional offset , for example sunset-15m.
+func parseTimeOfDay(value string) (timeOfDay, error) {
+	for _, anchor := range []string{anchorSunrise, anchorSunset} {
+		if !strings.HasPrefix(strings.ToLower(value), anchor) {
+			continue
+		}
+		offset := strings.TrimSpace(value[len(anchor):])
+		if offset == "" {
+			return timeOfDay{anchor: anchor}, nil
+		}
+		d, err := time.ParseDuration(offset)
+		if err != nil || (offset[0] != '+' && offset[0] != '-') {
+			return timeOfDay{}, fmt.Errorf("invalid offset of %q , expected for example %s+30m or %s-1h", value, anchor, anchor)
+		}
+		return timeOfDay{anchor: anchor, offset: d}, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return timeOfDay{}, fmt.Errorf("%q is not in HH:MM format or sunrise / sunset with offset", value)
+	}
+	return timeOfDay{offset: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}, nil
+}
+
+// resolve returns time t of the day of date.
+func (s *Schedule) resolve(t timeOfDay, date time.Time) time.Time {
+	if t.anchor == "" {
+		return atTimeOfDay(date, t.offset)
+	}
+	sunrise, sunset := SunTimes(date, s.latitude, s.longitude)
+	if t.anchor == anchorSunrise {
+		return sunrise.Add(t.offset)
+	}
+	return sunset.Add(t.offset)
+}
+
+// bounds returns start and end of the window started at the day of date. Returns false if the window is empty at this day ,
+// for example daylight window during polar night.
+func (s *Schedule) bounds(w window, date time.Time) (time.Time, time.Time, bool) {
+	start := s.resolve(w.start, date)
+	endDate := date
+	if w.crossesMidnight {
+		endDate = date.AddDate(0, 0, 1)
+	}
+	end := s.resolve(w.end, endDate)
+	return start, end, end.After(start)
+}
+
+// atTimeOfDay returns wall clock time of the day of date , so windows are not shifted on daylight saving time changes.
+func atTimeOfDay(date time.Time, offset time.Duration) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, int(offset.Minutes()), 0, 0, date.Location())
+}
+
+// midnight returns start of the day of t.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// IsActive returns true if t is inside of any window or if there are no windows.
+func (s *Schedule) IsActive(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+	t = t.In(s.location)
+	for _, date := range []time.Time{midnight(t).AddDate(0, 0, -1), midnight(t)} {
+		for _, w := range s.windows {
+			if !w.days[date.Weekday()] {
+				continue
+			}
+			start, end, ok := s.bounds(w, date)
+			if ok && !t.Before(start) && t.Before(end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextActive returns t if it's inside of a window , otherwise start of the next window. Returns zero time if no window starts within a year.
+func (s *Schedule) nextActive(t time.Time) time.Time {
+	if s.IsActive(t) {
+		return t
+	}
+	t = t.In(s.location)
+	var next time.Time
+	for i := 0; i <= 366; i++ {
+		date := midnight(t).AddDate(0, 0, i)
+		for _, w := range s.windows {
+			if !w.days[date.Weekday()] {
+				continue
+			}
+			start, _, ok := s.bounds(w, date)
+			if ok && start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
+
+// next returns the next capture time without jitter. If isFirst is true , the capture runs immediately when polling
+// interval is used and now is inside of a window.
+func (s *Schedule) next(now time.Time, isFirst bool) time.Time {
+	if s.cron == nil {
+		t := now
+		if !isFirst {
+			t = now.Add(s.interval)
+		}
+		return s.nextActive(t)
+	}
+	t := now.In(s.location)
+	// cron times outside of windows are skipped by continuing the search from start of the next window ,
+	// the search is limited to windowSearchHorizon for windows that never match the cron expression
+	limit := t.Add(windowSearchHorizon)
+	for {
+		if t = s.cron.Next(t); t.IsZero() || s.IsActive(t) {
+			return t
+		}
+		if t = s.nextActive(t); t.IsZero() || t.After(limit) {
+			return time.Time{}
+		}
+		t = t.Add(-time.Nanosecond)
+	}
+}
+
+// First returns delay before the first capture after start.
+func (s *Schedule) First(now time.Time) time.Duration {
+	return s.delay(now, s.next(now, true))
+}
+
+// Next returns delay before the next capture after a capture finished at now.
+func (s *Schedule) Next(now time.Time) time.Duration {
+	return s.delay(now, s.next(now, false))
+}
+
+// delay returns time until t plus random jitter. Returns negative duration if there is no next capture.
+func (s *Schedule) delay(now, t time.Time) time.Duration {
+	if t.IsZero() {
+		return -1
+	}
+	d := t.Sub(now)
+	if s.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(s.jitter)))
+	}
+	return max(d, 0)
+}
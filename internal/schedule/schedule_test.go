@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func loadTestLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available : %s", name, err)
+	}
+	return location
+}
+
+func TestScheduleNext(t *testing.T) {
+	oslo := loadTestLocation(t, "Europe/Oslo")
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, oslo)
+	}
+	officeHours := []Window{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "16:00"}}
+	night := []Window{{Start: "22:00", End: "06:00"}}
+
+	tests := []struct {
+		name     string
+		cron     string
+		interval time.Duration
+		windows  []Window
+		now      time.Time
+		expected time.Time // zero if there is no next capture
+	}{
+		// 2026-03-02 is Monday
+		{name: "every 15 minutes", cron: "*/15 * * * *", now: date(3, 2, 10, 7), expected: date(3, 2, 10, 15)},
+		{name: "next is after now", cron: "*/15 * * * *", now: date(3, 2, 10, 15), expected: date(3, 2, 10, 30)},
+		{name: "day of month or day of week , day of week first", cron: "0 12 13 * fri", now: date(3, 2, 10, 0), expected: date(3, 6, 12, 0)},
+		{name: "day of month or day of week , day of month first", cron: "0 12 3 * fri", now: date(3, 2, 10, 0), expected: date(3, 3, 12, 0)},
+		{name: "day of month only", cron: "0 12 13 * *", now: date(3, 2, 10, 0), expected: date(3, 13, 12, 0)},
+		{name: "day of week only", cron: "0 12 * * fri", now: date(3, 7, 10, 0), expected: date(3, 13, 12, 0)},
+		{name: "day of week 7 is sunday", cron: "0 8 * * 7", now: date(3, 2, 10, 0), expected: date(3, 8, 8, 0)},
+		{name: "day of week range up to 7", cron: "0 8 * * 6-7", now: date(3, 2, 10, 0), expected: date(3, 7, 8, 0)},
+		{name: "month names", cron: "0 0 1 jun,dec *", now: date(7, 1, 0, 0), expected: date(12, 1, 0, 0)},
+		{name: "30th of february never matches", cron: "0 0 30 2 *", now: date(1, 1, 0, 0)},
+		{name: "time in DST gap is skipped", cron: "30 2 * * *", now: date(3, 28, 12, 0), expected: date(3, 30, 2, 30)},
+		{name: "hourly across DST gap", cron: "0 * * * *", now: date(3, 29, 1, 30), expected: date(3, 29, 3, 0)},
+		{name: "repeated hour at DST end runs once", cron: "30 2 * * *", now: date(10, 25, 0, 0), expected: time.Date(2026, 10, 25, 2, 30, 0, 0, time.FixedZone("CET", 3600))},
+		{name: "cron inside of window", cron: "*/15 * * * *", windows: officeHours, now: date(3, 2, 10, 7), expected: date(3, 2, 10, 15)},
+		{name: "cron skips to next window", cron: "*/15 * * * *", windows: officeHours, now: date(3, 6, 16, 0), expected: date(3, 9, 8, 0)},
+		{name: "window crossing midnight", cron: "0 * * * *", windows: night, now: date(3, 2, 12, 0), expected: date(3, 2, 22, 0)},
+		{name: "window crossing midnight after midnight", cron: "0 * * * *", windows: night, now: date(3, 3, 4, 10), expected: date(3, 3, 5, 0)},
+		{name: "cron never inside of window", cron: "0 12 * * *", windows: night, now: date(3, 2, 0, 0)},
+		{name: "cron on days without window", cron: "0 10 * * sat,sun", windows: officeHours, now: date(3, 2, 0, 0)},
+		{name: "interval", interval: 10 * time.Minute, now: date(3, 2, 10, 7), expected: date(3, 2, 10, 17)},
+		{name: "interval inside of window", interval: 10 * time.Minute, windows: officeHours, now: date(3, 2, 15, 0), expected: date(3, 2, 15, 10)},
+		{name: "interval skips to next window", interval: 10 * time.Minute, windows: officeHours, now: date(3, 2, 15, 55), expected: date(3, 3, 8, 0)},
+		{name: "window keeps wall clock on DST change", interval: time.Hour, windows: []Window{{Start: "08:00", End: "09:00"}}, now: date(3, 28, 8, 30), expected: date(3, 29, 8, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(Config{Cron: tt.cron, Windows: tt.windows, Timezone: "Europe/Oslo"}, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := s.Next(tt.now)
+			if tt.expected.IsZero() {
+				if d >= 0 {
+					t.Fatalf("expected no next capture , got %s", tt.now.Add(d))
+				}
+				return
+			}
+			if next := tt.now.Add(d); !next.Equal(tt.expected) {
+				t.Fatalf("expected %s , got %s", tt.expected, next.In(oslo))
+			}
+		})
+	}
+}
+
+func TestScheduleFirst(t *testing.T) {
+	oslo := loadTestLocation(t, "Europe/Oslo")
+	now := time.Date(2026, 3, 2, 10, 7, 0, 0, oslo)
+	s, err := New(Config{Windows: []Window{{Start: "08:00", End: "16:00"}}, Timezone: "Europe/Oslo"}, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s.First(now); d != 0 {
+		t.Fatalf("expected immediate first capture inside of window , got %s", d)
+	}
+	if d := s.First(now.Add(8 * time.Hour)); d != 14*time.Hour-7*time.Minute {
+		t.Fatalf("expected first capture at start of next window , got %s", d)
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 7, 0, 0, time.UTC)
+	s, err := New(Config{Cron: "*/15 * * * *", Jitter: 60, Timezone: "UTC"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := 8 * time.Minute
+	var minDelay, maxDelay time.Duration = time.Hour, 0
+	for i := 0; i < 1000; i++ {
+		d := s.Next(now)
+		if d < base || d >= base+time.Minute {
+			t.Fatalf("delay %s is outside of jitter range [%s , %s)", d, base, base+time.Minute)
+		}
+		minDelay, maxDelay = min(minDelay, d), max(maxDelay, d)
+	}
+	if maxDelay-minDelay < 30*time.Second {
+		t.Fatalf("jitter isn't random , delays are within %s", maxDelay-minDelay)
+	}
+
+	// jitter isn't added when there is no next capture
+	never, err := New(Config{Cron: "0 0 30 2 *", Jitter: 60}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := never.Next(now); d >= 0 {
+		t.Fatalf("expected no next capture , got delay %s", d)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	valid := []string{"* * * * *", "0 0 * * *", "*/5 6-18 * * mon-fri", "0,30 8-17/2 1,15 jan-mar 0-7", "@hourly", "@Weekly", "0 12 ? * 1"}
+	for _, expr := range valid {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("expected %q to be valid , got %s", expr, err)
+		}
+	}
+	invalid := []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every 5m"}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		interval time.Duration
+	}{
+		{name: "no cron and no interval", config: Config{Jitter: 10}},
+		{name: "negative jitter", config: Config{Cron: "* * * * *", Jitter: -1}},
+		{name: "unknown timezone", config: Config{Cron: "* * * * *", Timezone: "Mars/Olympus"}},
+		{name: "invalid window day", config: Config{Windows: []Window{{Days: []string{"monday"}, Start: "08:00", End: "16:00"}}}, interval: time.Minute},
+		{name: "invalid window time", config: Config{Windows: []Window{{Start: "8am", End: "16:00"}}}, interval: time.Minute},
+		{name: "offset without sign", config: Config{Windows: []Window{{Start: "sunrise30m", End: "16:00"}}, Latitude: 59.9, Longitude: 10.7}, interval: time.Minute},
+		{name: "empty window", config: Config{Windows: []Window{{Start: "08:00", End: "08:00"}}}, interval: time.Minute},
+		{name: "sun window without location", config: Config{Windows: []Window{{Start: "sunrise", End: "sunset"}}}, interval: time.Minute},
+		{name: "latitude out of range", config: Config{Cron: "* * * * *", Latitude: 91}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config, tt.interval); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+// sunZenith is the zenith angle of the sun at sunrise and sunset in degrees , it includes atmospheric refraction and the sun radius.
+const sunZenith = 90.833
+
+// SunTimes returns sunrise and sunset of the day of date at given coordinates (degrees , positive to north and east).
+// Times are computed offline using NOAA solar calculator equations , precision is about one minute.
+// During polar day sunrise is midnight at the start of the day and sunset is midnight at the end of the day ,
+// during polar night both are solar noon , so daylight windows are empty.
+func SunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time) {
+	loc := date.Location()
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	noon, hourAngle, isPolarDay, isPolarNight := sunEvent(day, latitude, longitude, 720-4*longitude, 0)
+	if isPolarDay {
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1)
+	}
+	if isPolarNight {
+		t := day.Add(minutesToDuration(noon)).In(loc)
+		return t, t
+	}
+	// refine each event using position of the sun at the event time
+	riseMinutes, setMinutes := noon-4*hourAngle, noon+4*hourAngle
+	if minutes, _, isPolarDay, isPolarNight := sunEvent(day, latitude, longitude, riseMinutes, -1); !isPolarDay && !isPolarNight {
+		riseMinutes = minutes
+	}
+	if minutes, _, isPolarDay, isPolarNight := sunEvent(day, latitude, longitude, setMinutes, 1); !isPolarDay && !isPolarNight {
+		setMinutes = minutes
+	}
+	return day.Add(minutesToDuration(riseMinutes)).In(loc), day.Add(minutesToDuration(setMinutes)).In(loc)
+}
+
+// sunEvent computes position of the sun at given time (minutes from midnight UTC of day) and returns time of solar noon
+// if direction is 0 , sunrise if -1 and sunset if 1 , in minutes from midnight UTC , and hour angle of sunrise in degrees.
+func sunEvent(day time.Time, latitude, longitude, minutes, direction float64) (float64, float64, bool, bool) {
+	julianDay := float64(day.Unix())/86400 + 2440587.5 + minutes/1440
+	t := (julianDay - 2451545) / 36525
+
+	meanLongitude := math.Mod(280.46646+t*(36000.76983+t*0.0003032), 360)
+	meanAnomaly := 357.52911 + t*(35999.05029-0.0001537*t)
+	eccentricity := 0.016708634 - t*(0.000042037+0.0000001267*t)
+	center := math.Sin(rad(meanAnomaly))*(1.914602-t*(0.004817+0.000014*t)) +
+		math.Sin(rad(2*meanAnomaly))*(0.019993-0.000101*t) +
+		math.Sin(rad(3*meanAnomaly))*0.000289
+	omega := 125.04 - 1934.136*t
+	apparentLongitude := meanLongitude + center - 0.00569 - 0.00478*math.Sin(rad(omega))
+	meanObliquity := 23 + (26+(21.448-t*(46.815+t*(0.00059-t*0.001813)))/60)/60
+	obliquity := meanObliquity + 0.00256*math.Cos(rad(omega))
+	declination := math.Asin(math.Sin(rad(obliquity)) * math.Sin(rad(apparentLongitude)))
+
+	y := math.Pow(math.Tan(rad(obliquity/2)), 2)
+	equationOfTime := 4 * deg(y*math.Sin(2*rad(meanLongitude))-
+		2*eccentricity*math.Sin(rad(meanAnomaly))+
+		4*eccentricity*y*math.Sin(rad(meanAnomaly))*math.Cos(2*rad(meanLongitude))-
+		0.5*y*y*math.Sin(4*rad(meanLongitude))-
+		1.25*eccentricity*eccentricity*math.Sin(2*rad(meanAnomaly)))
+
+	cosHourAngle := math.Cos(rad(sunZenith))/(math.Cos(rad(latitude))*math.Cos(declination)) - math.Tan(rad(latitude))*math.Tan(declination)
+	solarNoon := 720 - 4*longitude - equationOfTime
+	if cosHourAngle < -1 {
+		return solarNoon, 0, true, false
+	}
+	if cosHourAngle > 1 {
+		return solarNoon, 0, false, true
+	}
+	hourAngle := deg(math.Acos(cosHourAngle))
+	return solarNoon + direction*4*hourAngle, hourAngle, false, false
+}
+
+func minutesToDuration(minutes float64) time.Duration {
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+func rad(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func deg(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
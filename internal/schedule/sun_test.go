@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	osloLatitude          = 59.9139
+	osloLongitude         = 10.7522
+	sydneyLatitude        = -33.8688
+	sydneyLongitude       = 151.2093
+	longyearbyenLatitude  = 78.2232
+	longyearbyenLongitude = 15.6267
+)
+
+func TestSunTimes(t *testing.T) {
+	oslo := loadTestLocation(t, "Europe/Oslo")
+	sydney := loadTestLocation(t, "Australia/Sydney")
+	// reference times are from NOAA solar calculator
+	tests := []struct {
+		name      string
+		date      time.Time
+		latitude  float64
+		longitude float64
+		sunrise   string
+		sunset    string
+	}{
+		{name: "Oslo summer solstice", date: time.Date(2026, 6, 21, 12, 0, 0, 0, oslo), latitude: osloLatitude, longitude: osloLongitude, sunrise: "03:54", sunset: "22:44"},
+		{name: "Oslo winter solstice", date: time.Date(2026, 12, 21, 12, 0, 0, 0, oslo), latitude: osloLatitude, longitude: osloLongitude, sunrise: "09:18", sunset: "15:12"},
+		{name: "Sydney summer solstice", date: time.Date(2026, 12, 21, 12, 0, 0, 0, sydney), latitude: sydneyLatitude, longitude: sydneyLongitude, sunrise: "05:41", sunset: "20:05"},
+		{name: "Sydney winter solstice", date: time.Date(2026, 6, 21, 12, 0, 0, 0, sydney), latitude: sydneyLatitude, longitude: sydneyLongitude, sunrise: "07:00", sunset: "16:54"},
+		{name: "date at midnight", date: time.Date(2026, 6, 21, 0, 0, 0, 0, oslo), latitude: osloLatitude, longitude: osloLongitude, sunrise: "03:54", sunset: "22:44"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset := SunTimes(tt.date, tt.latitude, tt.longitude)
+			assertSunTime(t, "sunrise", sunrise, tt.date, tt.sunrise)
+			assertSunTime(t, "sunset", sunset, tt.date, tt.sunset)
+		})
+	}
+}
+
+// assertSunTime checks that actual is at expected HH:MM of the day of date , within 2 minutes.
+func assertSunTime(t *testing.T, name string, actual, date time.Time, expected string) {
+	t.Helper()
+	clock, err := time.Parse("15:04", expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTime := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location())
+	if diff := actual.Sub(expectedTime).Abs(); diff > 2*time.Minute {
+		t.Errorf("expected %s at %s , got %s", name, expectedTime, actual)
+	}
+	if actual.Location() != date.Location() {
+		t.Errorf("%s is in %s , expected location of date %s", name, actual.Location(), date.Location())
+	}
+}
+
+func TestSunTimesPolar(t *testing.T) {
+	longyearbyen := loadTestLocation(t, "Arctic/Longyearbyen")
+
+	// polar day , the sun is up from the start to the end of the day
+	date := time.Date(2026, 6, 21, 12, 0, 0, 0, longyearbyen)
+	sunrise, sunset := SunTimes(date, longyearbyenLatitude, longyearbyenLongitude)
+	startOfDay := time.Date(2026, 6, 21, 0, 0, 0, 0, longyearbyen)
+	if !sunrise.Equal(startOfDay) || !sunset.Equal(startOfDay.AddDate(0, 0, 1)) {
+		t.Errorf("expected polar day from %s to %s , got %s - %s", startOfDay, startOfDay.AddDate(0, 0, 1), sunrise, sunset)
+	}
+
+	// polar night , there is no sunrise , both times are solar noon
+	date = time.Date(2026, 12, 21, 12, 0, 0, 0, longyearbyen)
+	sunrise, sunset = SunTimes(date, longyearbyenLatitude, longyearbyenLongitude)
+	if !sunrise.Equal(sunset) {
+		t.Fatalf("expected no daylight during polar night , got %s - %s", sunrise, sunset)
+	}
+	assertSunTime(t, "solar noon", sunrise, date, "11:56")
+}
+
+func TestSunWindowsPolar(t *testing.T) {
+	longyearbyen := loadTestLocation(t, "Arctic/Longyearbyen")
+	newSchedule := func(start, end string) *Schedule {
+		s, err := New(Config{Windows: []Window{{Start: start, End: end}}, Timezone: "Arctic/Longyearbyen", Latitude: longyearbyenLatitude, Longitude: longyearbyenLongitude}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	daylight := newSchedule("sunrise", "sunset")
+	darkness := newSchedule("sunset", "sunrise")
+
+	for hour := 0; hour < 24; hour++ {
+		polarDay := time.Date(2026, 6, 21, hour, 30, 0, 0, longyearbyen)
+		if !daylight.IsActive(polarDay) || darkness.IsActive(polarDay) {
+			t.Errorf("%s : daylight window must be active and darkness window inactive during polar day", polarDay)
+		}
+		polarNight := time.Date(2026, 12, 21, hour, 30, 0, 0, longyearbyen)
+		if daylight.IsActive(polarNight) || !darkness.IsActive(polarNight) {
+			t.Errorf("%s : daylight window must be inactive and darkness window active during polar night", polarNight)
+		}
+	}
+
+	// daylight captures resume at the first sunrise after polar night
+	now := time.Date(2026, 12, 21, 12, 0, 0, 0, longyearbyen)
+	next := now.Add(daylight.Next(now))
+	if next.Month() != time.February {
+		t.Fatalf("expected the first sunrise after polar night in February , got %s", next)
+	}
+	sunrise, sunset := SunTimes(next, longyearbyenLatitude, longyearbyenLongitude)
+	if !next.Equal(sunrise) || !sunset.After(sunrise) {
+		t.Fatalf("expected capture at sunrise %s , got %s", sunrise, next)
+	}
+	previousSunrise, previousSunset := SunTimes(next.AddDate(0, 0, -1), longyearbyenLatitude, longyearbyenLongitude)
+	if previousSunset.After(previousSunrise) {
+		t.Fatalf("the sun rises already at %s", previousSunrise)
+	}
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	spoolMetaExt       = ".json"
+	spoolBodyExt       = ".bin"
+	spoolTmpExt        = ".tmp"
+	spoolDeadLetterDir = "dead-letter"
+)
+
+// SpoolItem holds file metadata of spooled file. Body is stored in a separate file next to metadata file.
+type SpoolItem struct {
+	ID         string // unique and sortable ID , items are drained in ID order
+	Source     string // producer of the item , for example camera name
+	ExternalId string
+	Name       string
+	MimeType   string
+	AssetId    uint64
+	Metadata   map[string]string
+	CreatedAt  int64 // unix timestamp in milliseconds
+}
+
+// Spool is a durable on-disk FIFO queue for store-and-forward uploads. Each item is stored as 2 files : <ID>.bin (body) and <ID>.json (metadata).
+// Metadata file is written last , so items without metadata file are incomplete and removed on startup.
+// The spool is bounded by total size and item age , the oldest items are dropped first. Dead-letter items are counted in the bounds too
+// and dropped before queued items. The directory is scanned only once when the spool
+// is opened , then the queue is tracked by in-memory index , so operations don't depend on the queue depth.
+type Spool struct {
+	dir          string
+	maxSizeBytes int64
+	maxAge       time.Duration
+	seq          uint64
+	items        []spoolEntry // complete items sorted from the oldest to the newest
+	totalSize    int64        // total size of items
+	deadLetters  []spoolEntry // dead-letter items sorted from the oldest to the newest
+	deadSize     int64        // total size of dead-letter items
+	mux          sync.Mutex
+	notify       chan struct{}
+}
+
+// spoolEntry is index entry of spooled item.
+type spoolEntry struct {
+	id   string
+	size int64 // size of body and metadata files
+}
+
+// NewSpool opens (or creates) spool in dir. maxSizeBytes and maxAge equal to 0 disable corresponding bound.
+func NewSpool(dir string, maxSizeBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory %s : %w", dir, err)
+	}
+	s := &Spool{dir: dir, maxSizeBytes: maxSizeBytes, maxAge: maxAge, notify: make(chan struct{}, 1)}
+	s.removeIncompleteItems()
+	s.mux.Lock()
+	s.items, s.totalSize = loadSpoolIndex(dir)
+	s.deadLetters, s.deadSize = loadSpoolIndex(s.DeadLetterDir())
+	s.enforceBounds()
+	s.mux.Unlock()
+	log.Infof("Spool %s has been opened. Queue depth = %d", dir, s.Len())
+	return s, nil
+}
+
+// Dir returns spool directory.
+func (s *Spool) Dir() string {
+	return s.dir
+}
+
+// Put writes item to the spool. ID and CreatedAt are set by the spool.
+func (s *Spool) Put(item SpoolItem, body []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	s.seq++
+	item.ID = fmt.Sprintf("%020d_%06d", now.UnixNano(), s.seq%1000000)
+	item.CreatedAt = now.UnixMilli()
+	meta, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(filepath.Join(s.dir, item.ID+spoolBodyExt), body); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(filepath.Join(s.dir, item.ID+spoolMetaExt), meta); err != nil {
+		os.Remove(filepath.Join(s.dir, item.ID+spoolBodyExt))
+		return err
+	}
+	size := int64(len(body) + len(meta))
+	s.items = append(s.items, spoolEntry{id: item.ID, size: size})
+	s.totalSize += size
+	s.enforceBounds()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Peek returns the oldest item and its body without removing it from the spool. Returns nil if the spool is empty.
+func (s *Spool) Peek() (*SpoolItem, []byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.removeExpiredItems()
+	for len(s.items) > 0 {
+		id := s.items[0].id
+		meta, err := os.ReadFile(filepath.Join(s.dir, id+spoolMetaExt))
+		if err != nil {
+			log.Errorf("Spool item %s can't be read and will be removed. Err : %s", id, err.Error())
+			s.removeItem(id)
+			continue
+		}
+		var item SpoolItem
+		if err := json.Unmarshal(meta, &item); err != nil {
+			log.Errorf("Spool item %s is corrupted and will be removed. Err : %s", id, err.Error())
+			s.removeItem(id)
+			continue
+		}
+		body, err := os.ReadFile(filepath.Join(s.dir, id+spoolBodyExt))
+		if err != nil {
+			log.Errorf("Spool item %s has no body and will be removed. Err : %s", id, err.Error())
+			s.removeItem(id)
+			continue
+		}
+		return &item, body, nil
+	}
+	return nil, nil, nil
+}
+
+// Remove deletes item from the spool , normally after successful upload.
+func (s *Spool) Remove(item *SpoolItem) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.removeItem(item.ID)
+}
+
+// DeadLetter moves item that can't be uploaded into dead-letter subdirectory of the spool , so it doesn't block newer items.
+// Dead-letter items are not uploaded , they can be inspected and removed manually. They are counted in spool bounds and dropped
+// before queued items , so permanently failing uploads can't fill the disk.
+func (s *Spool) DeadLetter(item *SpoolItem) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	dir := filepath.Join(s.dir, spoolDeadLetterDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		s.removeItem(item.ID)
+		return fmt.Errorf("failed to create dead-letter directory , the item has been dropped : %w", err)
+	}
+	if err := os.Rename(filepath.Join(s.dir, item.ID+spoolBodyExt), filepath.Join(dir, item.ID+spoolBodyExt)); err != nil {
+		s.removeItem(item.ID)
+		return fmt.Errorf("failed to move item into dead-letter directory , the item has been dropped : %w", err)
+	}
+	if err := os.Rename(filepath.Join(s.dir, item.ID+spoolMetaExt), filepath.Join(dir, item.ID+spoolMetaExt)); err != nil {
+		s.removeItem(item.ID)
+		return fmt.Errorf("failed to move item into dead-letter directory , the item has been dropped : %w", err)
+	}
+	if entry, ok := s.removeIndexEntry(item.ID); ok {
+		s.deadLetters = append(s.deadLetters, entry)
+		s.deadSize += entry.size
+	}
+	s.enforceBounds()
+	return nil
+}
+
+// DeadLetterDir returns directory of items that can't be uploaded.
+func (s *Spool) DeadLetterDir() string {
+	return filepath.Join(s.dir, spoolDeadLetterDir)
+}
+
+// Len returns number of items in the spool (queue depth).
+func (s *Spool) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.items)
+}
+
+// Notify returns channel that receives a signal when a new item is added to the spool.
+func (s *Spool) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// loadSpoolIndex returns complete items in dir sorted from the oldest to the newest and their total size.
+func loadSpoolIndex(dir string) ([]spoolEntry, int64) {
+	entries, err := os.ReadDir(dir) // entries are sorted by file name
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("Failed to read spool directory. Err : ", err.Error())
+		}
+		return nil, 0
+	}
+	var items []spoolEntry
+	var totalSize int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolMetaExt) {
+			continue
+		}
+		item := spoolEntry{id: strings.TrimSuffix(entry.Name(), spoolMetaExt)}
+		for _, ext := range []string{spoolMetaExt, spoolBodyExt} {
+			if info, err := os.Stat(filepath.Join(dir, item.id+ext)); err == nil {
+				item.size += info.Size()
+			}
+		}
+		items = append(items, item)
+		totalSize += item.size
+	}
+	return items, totalSize
+}
+
+// removeItem deletes metadata first , so partially removed item is never returned by Peek. Must be called with mux locked.
+func (s *Spool) removeItem(id string) {
+	os.Remove(filepath.Join(s.dir, id+spoolMetaExt))
+	os.Remove(filepath.Join(s.dir, id+spoolBodyExt))
+	s.removeIndexEntry(id)
+}
+
+// removeIndexEntry removes item from the index and returns its entry. Items are removed from the head of the queue in most cases.
+// Must be called with mux locked.
+func (s *Spool) removeIndexEntry(id string) (spoolEntry, bool) {
+	for i, entry := range s.items {
+		if entry.id == id {
+			s.totalSize -= entry.size
+			if i == 0 {
+				s.items = s.items[1:]
+			} else {
+				s.items = append(s.items[:i], s.items[i+1:]...)
+			}
+			return entry, true
+		}
+	}
+	return spoolEntry{}, false
+}
+
+// removeOldestDeadLetter deletes the oldest dead-letter item. Must be called with mux locked.
+func (s *Spool) removeOldestDeadLetter() {
+	entry := s.deadLetters[0]
+	dir := s.DeadLetterDir()
+	os.Remove(filepath.Join(dir, entry.id+spoolMetaExt))
+	os.Remove(filepath.Join(dir, entry.id+spoolBodyExt))
+	s.deadLetters = s.deadLetters[1:]
+	s.deadSize -= entry.size
+}
+
+// removeIncompleteItems deletes temporary files and bodies without metadata left after crash or power loss.
+func (s *Spool) removeIncompleteItems() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, spoolTmpExt):
+			os.Remove(filepath.Join(s.dir, name))
+		case strings.HasSuffix(name, spoolBodyExt):
+			id := strings.TrimSuffix(name, spoolBodyExt)
+			if _, err := os.Stat(filepath.Join(s.dir, id+spoolMetaExt)); os.IsNotExist(err) {
+				os.Remove(filepath.Join(s.dir, name))
+			}
+		}
+	}
+}
+
+// removeExpiredItems drops queued and dead-letter items older than maxAge. Must be called with mux locked.
+func (s *Spool) removeExpiredItems() {
+	if s.maxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-s.maxAge).UnixNano()
+	for len(s.deadLetters) > 0 && spoolItemCreatedAt(s.deadLetters[0].id) < cutoff {
+		log.Warnf("Dead-letter spool item %s is older than %s and has been dropped", s.deadLetters[0].id, s.maxAge)
+		s.removeOldestDeadLetter()
+	}
+	for len(s.items) > 0 {
+		id := s.items[0].id
+		if spoolItemCreatedAt(id) >= cutoff {
+			break
+		}
+		log.Warnf("Spool item %s is older than %s and has been dropped", id, s.maxAge)
+		s.removeItem(id)
+	}
+}
+
+// spoolItemCreatedAt returns creation time of item in unix nanoseconds , it's the first part of item ID.
+func spoolItemCreatedAt(id string) int64 {
+	var createdAt int64
+	fmt.Sscanf(id, "%d_", &createdAt)
+	return createdAt
+}
+
+// enforceBounds drops expired items , then the oldest dead-letter items and the oldest queued items until total size of the spool
+// fits into maxSizeBytes. Must be called with mux locked.
+func (s *Spool) enforceBounds() {
+	s.removeExpiredItems()
+	if s.maxSizeBytes <= 0 {
+		return
+	}
+	for s.totalSize+s.deadSize > s.maxSizeBytes && len(s.deadLetters) > 0 {
+		log.Warnf("Spool size limit of %d bytes is reached , the oldest dead-letter item %s has been dropped", s.maxSizeBytes, s.deadLetters[0].id)
+		s.removeOldestDeadLetter()
+	}
+	// the newest item is kept even if it's bigger than the limit
+	for s.totalSize > s.maxSizeBytes && len(s.items) > 1 {
+		id := s.items[0].id
+		log.Warnf("Spool size limit of %d bytes is reached , the oldest item %s has been dropped", s.maxSizeBytes, id)
+		s.removeItem(id)
+	}
+}
+
+// WriteFileAtomic writes data into temporary file and renames it , so readers never see partially written files.
+func WriteFileAtomic(path string, data []byte) error {
+	return CopyFileAtomic(path, bytes.NewReader(data))
+}
+
+// CopyFileAtomic streams reader into temporary file and renames it , so readers never see partially written files.
+func CopyFileAtomic(path string, reader io.Reader) error {
+	tmpPath := path + spoolTmpExt
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
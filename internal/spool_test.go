@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func putTestItems(t *testing.T, s *Spool, count, bodySize int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		body := make([]byte, bodySize)
+		if err := s.Put(SpoolItem{Source: "cam", ExternalId: fmt.Sprintf("item-%d", i)}, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drainExternalIds removes all items from the spool and returns their external ids in drain order.
+func drainExternalIds(t *testing.T, s *Spool) []string {
+	t.Helper()
+	var ids []string
+	for {
+		item, _, err := s.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item == nil {
+			return ids
+		}
+		ids = append(ids, item.ExternalId)
+		s.Remove(item)
+	}
+}
+
+func TestSpoolOrderAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, s, 3, 10)
+	if s.Len() != 3 {
+		t.Fatalf("expected 3 items , got %d", s.Len())
+	}
+	item, body, err := s.Peek()
+	if err != nil || item.ExternalId != "item-0" || len(body) != 10 {
+		t.Fatalf("unexpected head item %+v , body %d bytes , err %v", item, len(body), err)
+	}
+
+	// index is restored from the directory , incomplete items are removed
+	os.WriteFile(filepath.Join(dir, "00000000000000000001_000001"+spoolBodyExt), []byte("orphan"), 0600)
+	reopened, err := NewSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := drainExternalIds(t, reopened); fmt.Sprint(ids) != "[item-0 item-1 item-2]" {
+		t.Fatalf("unexpected drain order %v", ids)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("spool directory isn't empty after drain : %d entries", len(entries))
+	}
+}
+
+func TestSpoolBounds(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, s, 5, 1000)
+	itemSize := s.totalSize / 5
+
+	// size bound drops the oldest items
+	bounded, err := NewSpool(dir, 3*itemSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounded.Len() != 3 || bounded.totalSize != 3*itemSize {
+		t.Fatalf("expected 3 items of %d bytes , got %d items of %d bytes", itemSize, bounded.Len(), bounded.totalSize)
+	}
+	putTestItems(t, bounded, 1, 1000)
+	if ids := drainExternalIds(t, bounded); fmt.Sprint(ids) != "[item-3 item-4 item-0]" {
+		t.Fatalf("unexpected items after size bound %v", ids)
+	}
+
+	// age bound drops expired items
+	aged, err := NewSpool(t.TempDir(), 0, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, aged, 2, 10)
+	time.Sleep(100 * time.Millisecond)
+	if item, _, _ := aged.Peek(); item != nil {
+		t.Fatalf("expired item %s is returned", item.ExternalId)
+	}
+	if aged.Len() != 0 || aged.totalSize != 0 {
+		t.Fatalf("expired items are still indexed : %d items , %d bytes", aged.Len(), aged.totalSize)
+	}
+}
+
+func TestSpoolRemovedFileIsSkipped(t *testing.T) {
+	s, err := NewSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, s, 2, 10)
+	head, _, _ := s.Peek()
+	// item removed manually from the directory
+	os.Remove(filepath.Join(s.Dir(), head.ID+spoolMetaExt))
+	if ids := drainExternalIds(t, s); fmt.Sprint(ids) != "[item-1]" {
+		t.Fatalf("unexpected items %v", ids)
+	}
+}
+
+func TestSpoolDeadLetterBounds(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, s, 4, 1000)
+	itemSize := s.totalSize / 4
+	for i := 0; i < 2; i++ {
+		item, _, _ := s.Peek()
+		if err := s.DeadLetter(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 2 || s.deadSize != 2*itemSize {
+		t.Fatalf("expected 2 queued and 2 dead-letter items , got %d queued , %d dead-letter bytes", s.Len(), s.deadSize)
+	}
+
+	// dead-letter items are restored on reopen and dropped before queued items
+	bounded, err := NewSpool(dir, 3*itemSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounded.Len() != 2 || len(bounded.deadLetters) != 1 || bounded.deadLetters[0].id == "" {
+		t.Fatalf("expected 2 queued and 1 dead-letter item , got %d and %d", bounded.Len(), len(bounded.deadLetters))
+	}
+	entries, _ := os.ReadDir(bounded.DeadLetterDir())
+	if len(entries) != 2 {
+		t.Fatalf("expected files of 1 dead-letter item , got %d files", len(entries))
+	}
+	putTestItems(t, bounded, 1, 1000)
+	if len(bounded.deadLetters) != 0 || bounded.Len() != 3 {
+		t.Fatalf("dead-letter item wasn't dropped before queued items : %d dead-letter , %d queued", len(bounded.deadLetters), bounded.Len())
+	}
+	if entries, _ := os.ReadDir(bounded.DeadLetterDir()); len(entries) != 0 {
+		t.Fatalf("dead-letter directory isn't empty : %d files", len(entries))
+	}
+
+	// age bound applies to dead-letter items
+	aged, err := NewSpool(t.TempDir(), 0, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestItems(t, aged, 1, 10)
+	item, _, _ := aged.Peek()
+	aged.DeadLetter(item)
+	time.Sleep(100 * time.Millisecond)
+	aged.Peek()
+	if len(aged.deadLetters) != 0 || aged.deadSize != 0 {
+		t.Fatal("expired dead-letter item wasn't dropped")
+	}
+}
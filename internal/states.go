@@ -14,9 +14,13 @@ const (
 )
 
 type ProcessorState struct {
-	ID           uint64
-	CurrentState string
-	TargetState  string
+	ID              uint64
+	Name            string
+	CurrentState    string
+	TargetState     string
+	LastSuccessTime time.Time // time of the last successful capture
+	LastError       string
+	LastErrorTime   time.Time
 }
 
 // StateTracker keep track of current and target states for all processors
@@ -51,6 +55,40 @@ func (intgr *StateTracker) SetProcessorCurrentState(procId uint64, state string)
 	}
 }
 
+// SetProcessorName sets human readable processor name , for example camera name.
+func (intgr *StateTracker) SetProcessorName(procId uint64, name string) {
+	intgr.mux.Lock()
+	defer intgr.mux.Unlock()
+	intgr.getOrAddProcessorState(procId).Name = name
+}
+
+// ReportProcessorSuccess records time of the last successful processor run.
+func (intgr *StateTracker) ReportProcessorSuccess(procId uint64) {
+	intgr.mux.Lock()
+	defer intgr.mux.Unlock()
+	intgr.getOrAddProcessorState(procId).LastSuccessTime = time.Now()
+}
+
+// ReportProcessorError records the last processor error and its time.
+func (intgr *StateTracker) ReportProcessorError(procId uint64, err error) {
+	intgr.mux.Lock()
+	defer intgr.mux.Unlock()
+	st := intgr.getOrAddProcessorState(procId)
+	st.LastError = err.Error()
+	st.LastErrorTime = time.Now()
+}
+
+// getOrAddProcessorState returns processor state and adds new state if processor is not tracked yet. Must be called with mux locked.
+func (intgr *StateTracker) getOrAddProcessorState(procId uint64) *ProcessorState {
+	for i := range intgr.procStates {
+		if intgr.procStates[i].ID == procId {
+			return &intgr.procStates[i]
+		}
+	}
+	intgr.procStates = append(intgr.procStates, ProcessorState{ID: procId})
+	return &intgr.procStates[len(intgr.procStates)-1]
+}
+
 // getProcessorState returns process state , the method is for internal use only
 func (intgr *StateTracker) getProcessorState(procId uint64) *ProcessorState {
 	for i := range intgr.procStates {
@@ -68,6 +106,15 @@ func (intgr *StateTracker) GetProcessorState(procId uint64) *ProcessorState {
 	return intgr.getProcessorState(procId)
 }
 
+// GetAllProcessorStates returns copy of states of all processors.
+func (intgr *StateTracker) GetAllProcessorStates() []ProcessorState {
+	intgr.mux.RLock()
+	defer intgr.mux.RUnlock()
+	states := make([]ProcessorState, len(intgr.procStates))
+	copy(states, intgr.procStates)
+	return states
+}
+
 // WaitForProcessorTargetState blocks execution untill processor reaches target or wait operation times out .
 func (intgr *StateTracker) WaitForProcessorTargetState(procId uint64, timeout time.Duration) bool {
 	endTime := time.Now().Add(timeout)
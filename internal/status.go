@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProcessorStatus is a status of single processor (for instance camera) reported by status API.
+type ProcessorStatus struct {
+	ID              uint64
+	Name            string
+	CurrentState    string
+	TargetState     string
+	LastSuccessTime *time.Time `json:",omitempty"`
+	LastError       string     `json:",omitempty"`
+	LastErrorTime   *time.Time `json:",omitempty"`
+}
+
+type IntegrationStatus struct {
+	Name       string
+	Processors []ProcessorStatus
+}
+
+// AppStatus is a status of micro-app loaded by app manager.
+type AppStatus struct {
+	InstanceID string
+	AppName    string
+}
+
+// ExtractorStatus is the response of /status endpoint.
+type ExtractorStatus struct {
+	ExtractorID    string
+	Version        string
+	StartedAt      time.Time
+	Ready          bool
+	ConfigRevision int // -1 if remote config is not used
+	Integrations   []IntegrationStatus
+	Apps           []AppStatus
+}
+
+// StatusServer is embedded HTTP server used for local diagnostics. It exposes following endpoints :
+// /healthz - liveness probe , always returns 200 while the service is running
+// /readyz - readiness probe , returns 200 after the extractor has been started and 503 otherwise
+// /status - current and target states of all processors , last successful capture , last error , config revision and loaded micro-apps
+type StatusServer struct {
+	extractorID    string
+	version        string
+	startedAt      time.Time
+	isReady        bool
+	configObserver *CdfConfigObserver
+	appsProvider   func() []AppStatus
+	mux            sync.RWMutex
+}
+
+func NewStatusServer(extractorID, version string) *StatusServer {
+	return &StatusServer{extractorID: extractorID, version: version, startedAt: time.Now()}
+}
+
+// SetReady changes readiness state reported by /readyz endpoint.
+func (srv *StatusServer) SetReady(isReady bool) {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	srv.isReady = isReady
+}
+
+// SetConfigObserver sets config observer used to report active config revision.
+func (srv *StatusServer) SetConfigObserver(configObserver *CdfConfigObserver) {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	srv.configObserver = configObserver
+}
+
+// SetAppsProvider sets function that returns the list of loaded micro-apps.
+func (srv *StatusServer) SetAppsProvider(provider func() []AppStatus) {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	srv.appsProvider = provider
+}
+
+// GetStatus collects current status of the extractor.
+func (srv *StatusServer) GetStatus() ExtractorStatus {
+	srv.mux.RLock()
+	defer srv.mux.RUnlock()
+	status := ExtractorStatus{
+		ExtractorID:    srv.extractorID,
+		Version:        srv.version,
+		StartedAt:      srv.startedAt,
+		Ready:          srv.isReady,
+		ConfigRevision: -1,
+		Integrations:   []IntegrationStatus{},
+		Apps:           []AppStatus{},
+	}
+	if srv.configObserver != nil {
+		status.ConfigRevision = srv.configObserver.GetConfigRevision()
+	}
+	for name, tracker := range getStateTrackers() {
+		integration := IntegrationStatus{Name: name, Processors: []ProcessorStatus{}}
+		for _, st := range tracker.GetAllProcessorStates() {
+			procStatus := ProcessorStatus{ID: st.ID, Name: st.Name, CurrentState: st.CurrentState, TargetState: st.TargetState, LastError: st.LastError}
+			if !st.LastSuccessTime.IsZero() {
+				procStatus.LastSuccessTime = &st.LastSuccessTime
+			}
+			if !st.LastErrorTime.IsZero() {
+				procStatus.LastErrorTime = &st.LastErrorTime
+			}
+			integration.Processors = append(integration.Processors, procStatus)
+		}
+		sort.Slice(integration.Processors, func(i, j int) bool { return integration.Processors[i].ID < integration.Processors[j].ID })
+		status.Integrations = append(status.Integrations, integration)
+	}
+	sort.Slice(status.Integrations, func(i, j int) bool { return status.Integrations[i].Name < status.Integrations[j].Name })
+	if srv.appsProvider != nil {
+		status.Apps = append(status.Apps, srv.appsProvider()...)
+	}
+	return status
+}
+
+// Handler returns HTTP handler with all status endpoints. If withMetrics is true , /metrics endpoint is served as well.
+func (srv *StatusServer) Handler(withMetrics bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		srv.mux.RLock()
+		isReady := srv.isReady
+		srv.mux.RUnlock()
+		if !isReady {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(srv.GetStatus()); err != nil {
+			log.Error("Failed to encode status. Err : ", err.Error())
+		}
+	})
+	if withMetrics {
+		mux.Handle("/metrics", MetricsHandler())
+	}
+	return mux
+}
+
+// Start starts HTTP server on address. The operation is non-blocking.
+func (srv *StatusServer) Start(address string, withMetrics bool) {
+	handler := srv.Handler(withMetrics)
+	go func() {
+		log.Infof("Starting status API server on %s", address)
+		if err := http.ListenAndServe(address, handler); err != nil {
+			log.Errorf("Status API server failed. Err : %s", err.Error())
+		}
+	}()
+}
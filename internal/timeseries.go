@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cognitedata/cognite-sdk-go/pkg/cognite/dto/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// DatapointsWriter buffers datapoints in memory and writes them to CDF in batches.
+// Time series are registered upfront and created (or updated) on the next flush , datapoints of time series
+// that haven't been created yet stay in the buffer. Each time series buffer is bounded , the oldest datapoints are dropped first.
+type DatapointsWriter struct {
+	cdf                *CdfClient
+	maxBufferedPerTs   int
+	mux                sync.Mutex
+	buffers            map[string][]core.Datapoint
+	pendingTimeSeries  map[string]core.TimeSerie
+	existingTimeSeries map[string]bool
+}
+
+// maxDatapointsPerRequest is CDF limit of datapoints in single insert request
+const maxDatapointsPerRequest = 100000
+
+// NewDatapointsWriter creates datapoints writer. maxBufferedPerTs limits number of buffered datapoints per time series , default 10000 , max 100000.
+func NewDatapointsWriter(cdf *CdfClient, maxBufferedPerTs int) *DatapointsWriter {
+	if maxBufferedPerTs <= 0 {
+		maxBufferedPerTs = 10000
+	}
+	if maxBufferedPerTs > maxDatapointsPerRequest {
+		maxBufferedPerTs = maxDatapointsPerRequest
+	}
+	return &DatapointsWriter{
+		cdf:                cdf,
+		maxBufferedPerTs:   maxBufferedPerTs,
+		buffers:            make(map[string][]core.Datapoint),
+		pendingTimeSeries:  make(map[string]core.TimeSerie),
+		existingTimeSeries: make(map[string]bool),
+	}
+}
+
+// RegisterTimeSeries schedules creation or update of time series. External id must be set.
+func (w *DatapointsWriter) RegisterTimeSeries(timeSeries ...core.TimeSerie) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for _, ts := range timeSeries {
+		w.pendingTimeSeries[ts.ExternalID] = ts
+	}
+}
+
+// HasTimeSeries returns true if time series has been registered.
+func (w *DatapointsWriter) HasTimeSeries(externalId string) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	_, isPending := w.pendingTimeSeries[externalId]
+	return isPending || w.existingTimeSeries[externalId]
+}
+
+// Add appends datapoint to the buffer of time series.
+func (w *DatapointsWriter) Add(externalId string, timestamp time.Time, value float64) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	buffer := append(w.buffers[externalId], core.Datapoint{Timestamp: timestamp.UnixMilli(), Value: value})
+	if len(buffer) > w.maxBufferedPerTs {
+		log.Debugf("Datapoints buffer of time series %s is full , the oldest datapoints are dropped", externalId)
+		buffer = buffer[len(buffer)-w.maxBufferedPerTs:]
+	}
+	w.buffers[externalId] = buffer
+}
+
+// Len returns total number of buffered datapoints.
+func (w *DatapointsWriter) Len() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	count := 0
+	for _, buffer := range w.buffers {
+		count += len(buffer)
+	}
+	return count
+}
+
+// Flush creates pending time series and writes buffered datapoints. Datapoints that failed to be written are returned to the buffer.
+func (w *DatapointsWriter) Flush() error {
+	w.mux.Lock()
+	pending := make([]core.TimeSerie, 0, len(w.pendingTimeSeries))
+	for _, ts := range w.pendingTimeSeries {
+		pending = append(pending, ts)
+	}
+	w.mux.Unlock()
+
+	var errs []error
+	if len(pending) > 0 {
+		if err := w.cdf.CreateOrUpdateTimeSeries(pending); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create time series : %w", err))
+		} else {
+			w.mux.Lock()
+			for _, ts := range pending {
+				delete(w.pendingTimeSeries, ts.ExternalID)
+				w.existingTimeSeries[ts.ExternalID] = true
+			}
+			w.mux.Unlock()
+		}
+	}
+
+	w.mux.Lock()
+	batches := make(map[string][]core.Datapoint)
+	for externalId, buffer := range w.buffers {
+		if !w.existingTimeSeries[externalId] || len(buffer) == 0 {
+			continue
+		}
+		batches[externalId] = buffer
+		delete(w.buffers, externalId)
+	}
+	w.mux.Unlock()
+
+	for externalId, datapoints := range batches {
+		if err := w.cdf.InsertDatapoints(externalId, datapoints); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %d datapoints to time series %s : %w", len(datapoints), externalId, err))
+			w.requeue(externalId, datapoints)
+			continue
+		}
+		log.Debugf("%d datapoints have been written to time series %s", len(datapoints), externalId)
+	}
+	return errors.Join(errs...)
+}
+
+// requeue puts datapoints back in front of the buffer.
+func (w *DatapointsWriter) requeue(externalId string, datapoints []core.Datapoint) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	buffer := append(datapoints, w.buffers[externalId]...)
+	if len(buffer) > w.maxBufferedPerTs {
+		buffer = buffer[len(buffer)-w.maxBufferedPerTs:]
+	}
+	w.buffers[externalId] = buffer
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -11,6 +12,8 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -115,3 +118,38 @@ func DecryptString(key, text string) (string, error) {
 
 	return string(plaintext), nil
 }
+
+// SleepWithContext pauses the current goroutine for duration d or until ctx is cancelled. Returns false if ctx has been cancelled.
+func SleepWithContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ExpandTemplate replaces {name} placeholders in template with values returned by lookup function.
+func ExpandTemplate(template string, lookup func(name string) string) string {
+	var result strings.Builder
+	for {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			break
+		}
+		result.WriteString(template[:start])
+		result.WriteString(lookup(template[start+1 : start+end]))
+		template = template[start+end+1:]
+	}
+	result.WriteString(template)
+	return result.String()
+}
@@ -1,7 +1,6 @@
 package ffmpeg
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -63,15 +62,12 @@ func (camera *Camera) SetFrameBuffer(buffer []byte) {
 // Reads the next frame from the webcam and stores in the framebuffer.
 func (camera *Camera) Read() bool {
 
-	total := 0
-	for total < camera.width*camera.height*camera.depth {
-		if camera.pipe == nil {
-			fmt.Println("Pipe is nil")
-			return false
-		}
-		n, _ := (*camera.pipe).Read(camera.framebuffer[total:])
-		total += n
-		fmt.Println("Read ", n, " bytes")
+	if camera.pipe == nil {
+		return false
+	}
+	// Read returns false when ffmpeg process exits or the stream is closed.
+	if _, err := io.ReadFull(*camera.pipe, camera.framebuffer[:camera.width*camera.height*camera.depth]); err != nil {
+		return false
 	}
 	return true
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"io"
 	"os"
 	"strings"
 
@@ -86,3 +87,23 @@ func Write(filename string, width, height int, buffer []byte) error {
 	}
 	return nil
 }
+
+// EncodeJPEG encodes RGB24 frame buffer as JPEG. Quality is in range 1-100 , 0 means default quality.
+func EncodeJPEG(w io.Writer, width, height int, buffer []byte, quality int) error {
+	size := width * height * 3
+	if len(buffer) < size {
+		return fmt.Errorf("buffer size (%d) is smaller than image size (%d)", len(buffer), size)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, j := 0, 0; i < size; i, j = i+3, j+4 {
+		img.Pix[j] = buffer[i]
+		img.Pix[j+1] = buffer[i+1]
+		img.Pix[j+2] = buffer[i+2]
+		img.Pix[j+3] = 255
+	}
+	var options *jpeg.Options
+	if quality > 0 {
+		options = &jpeg.Options{Quality: quality}
+	}
+	return jpeg.Encode(w, img, options)
+}
@@ -1,8 +1,16 @@
 package ffmpeg
 
 import (
+	"context"
+	"errors"
 	"io"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 type RTSPCamera struct {
@@ -10,22 +18,50 @@ type RTSPCamera struct {
 	username  string
 	password  string
 	streamUri string
+	options   RTSPOptions
+	inputFile string     // ffconcat file that holds stream url with credentials
+	inputMux  sync.Mutex // guards inputFile , it's removed by both Read and Close
 	camera    *Camera
+	closeOnce sync.Once
+}
+
+// RTSPOptions controls how ffmpeg connects to the stream.
+type RTSPOptions struct {
+	Transport string        // udp or tcp. Default udp.
+	FrameRate string        // output frame rate , for example "1" (1 frame per second) or "0.1". Default 0.1 .
+	Timeout   time.Duration // max duration of stream probe , ffmpeg is killed if the stream isn't probed within the timeout. Default 20s .
 }
 
 // Creates a new camera struct that can read from the device with the given stream index.
 func NewRtspCamera(id, username, password, streamUri string) (*RTSPCamera, error) {
+	return NewRtspCameraWithOptions(id, username, password, streamUri, RTSPOptions{})
+}
+
+// NewRtspCameraWithOptions creates a new RTSP camera with custom transport and frame rate.
+// streamUri can be provided with or without rtsp:// scheme , for example "192.168.86.230:554/Streaming/Channels/1/"
+func NewRtspCameraWithOptions(id, username, password, streamUri string, options RTSPOptions) (*RTSPCamera, error) {
+	return NewRtspCameraWithContext(context.Background(), id, username, password, streamUri, options)
+}
+
+// NewRtspCameraWithContext creates a new RTSP camera and probes the stream. The probe is killed when ctx is cancelled.
+func NewRtspCameraWithContext(ctx context.Context, id, username, password, streamUri string, options RTSPOptions) (*RTSPCamera, error) {
 	// Check if ffmpeg is installed on the users machine.
 	if err := checkExists("ffmpeg"); err != nil {
 		return nil, err
 	}
+	if options.Transport == "" {
+		options.Transport = "udp"
+	}
+	if options.FrameRate == "" {
+		options.FrameRate = "0.1"
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 20 * time.Second
+	}
 
 	camera := Camera{name: "rtsp", depth: 3, framerate: "30"}
-	// if err := getCameraData(device, &camera); err != nil {
-	// 	return nil, err
-	// }
-	rtspCamera := &RTSPCamera{id: id, camera: &camera, username: username, password: password, streamUri: streamUri}
-	err := rtspCamera.GetCameraData()
+	rtspCamera := &RTSPCamera{id: id, camera: &camera, username: username, password: password, streamUri: streamUri, options: options}
+	err := rtspCamera.GetCameraDataContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -37,36 +73,99 @@ func (cam *RTSPCamera) Camera() *Camera {
 	return cam.camera
 }
 
+// streamURL returns full stream url with credentials.
+func (cam *RTSPCamera) streamURL() (string, error) {
+	uri := cam.streamUri
+	if !strings.Contains(uri, "://") {
+		uri = "rtsp://" + uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if cam.username != "" {
+		u.User = url.UserPassword(cam.username, cam.password)
+	}
+	return u.String(), nil
+}
+
+// inputArgs returns ffmpeg input arguments. Stream url is written into private ffconcat file instead of command line ,
+// so credentials are not exposed in process listings.
+func (cam *RTSPCamera) inputArgs() ([]string, error) {
+	streamURL, err := cam.streamURL()
+	if err != nil {
+		return nil, err
+	}
+	cam.inputMux.Lock()
+	defer cam.inputMux.Unlock()
+	cam.removeInputFileLocked()
+	dir, err := os.MkdirTemp("", "edge-extractor-rtsp-")
+	if err != nil {
+		return nil, err
+	}
+	inputFile := filepath.Join(dir, "input.ffconcat")
+	content := "ffconcat version 1.0\n" +
+		"file '" + strings.ReplaceAll(streamURL, "'", `'\''`) + "'\n" +
+		"option rtsp_transport " + cam.options.Transport + "\n"
+	if err := os.WriteFile(inputFile, []byte(content), 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	cam.inputFile = inputFile
+	return []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-protocol_whitelist", "file,rtsp,rtsps,rtp,udp,tcp,tls",
+		"-i", inputFile,
+	}, nil
+}
+
+// removeInputFile deletes ffconcat file. ffmpeg reads it only once during startup.
+func (cam *RTSPCamera) removeInputFile() {
+	cam.inputMux.Lock()
+	cam.removeInputFileLocked()
+	cam.inputMux.Unlock()
+}
+
+func (cam *RTSPCamera) removeInputFileLocked() {
+	if cam.inputFile != "" {
+		os.RemoveAll(filepath.Dir(cam.inputFile))
+		cam.inputFile = ""
+	}
+}
+
 // Once the user calls Read() for the first time on a Camera struct,
 // the ffmpeg command which is used to read the camera device is started.
 func (cam *RTSPCamera) InitCamera() error {
-	// If user exits with Ctrl+C, stop ffmpeg process.
-	cam.camera.cleanup()
-
-	// Use ffmpeg to pipe webcam to stdout.
-	cmd := exec.Command(
-		"ffmpeg",
+	inputArgs, err := cam.inputArgs()
+	if err != nil {
+		return err
+	}
+	args := []string{
 		"-hide_banner",
 		"-loglevel", "quiet",
 		"-max_delay", "500000",
-		"-rtsp_transport", "udp",
-		"-i", "rtsp://"+cam.username+":"+cam.password+"@"+cam.streamUri,
+	}
+	args = append(args, inputArgs...)
+	args = append(args,
 		"-f", "image2pipe",
-		"-r", "0.1", //  1 = 1HZ or frame per second.
+		"-r", cam.options.FrameRate, //  1 = 1HZ or frame per second.
 		"-pix_fmt", "rgb24",
 		"-vcodec", "rawvideo", "-",
 	)
+	// Use ffmpeg to pipe stream to stdout.
+	cmd := exec.Command("ffmpeg", args...)
 
 	cam.camera.cmd = cmd
 	pipe, err := cmd.StdoutPipe()
 	if err != nil {
-		pipe.Close()
+		cam.removeInputFile()
 		return err
 	}
 
 	cam.camera.pipe = &pipe
 	if err := cmd.Start(); err != nil {
-		cmd.Process.Kill()
+		cam.removeInputFile()
 		return err
 	}
 
@@ -75,39 +174,62 @@ func (cam *RTSPCamera) InitCamera() error {
 	return nil
 }
 
+// Read reads the next frame from the stream into camera framebuffer.
+func (cam *RTSPCamera) Read() bool {
+	ok := cam.camera.Read()
+	cam.removeInputFile()
+	return ok
+}
+
+// Close stops ffmpeg process and removes temporary files. It is safe to call Close multiple times.
+func (cam *RTSPCamera) Close() {
+	cam.closeOnce.Do(func() {
+		cam.camera.Close()
+		if cam.camera.cmd != nil {
+			cam.camera.cmd.Wait()
+		}
+		cam.removeInputFile()
+	})
+}
+
 // Get camera meta data such as width, height, fps and codec.
 func (cam *RTSPCamera) GetCameraData() error {
+	return cam.GetCameraDataContext(context.Background())
+}
+
+// GetCameraDataContext probes the stream , ffmpeg is killed if ctx is cancelled or the probe takes longer than
+// Timeout option , so a silent or half-open server can't block the caller.
+func (cam *RTSPCamera) GetCameraDataContext(ctx context.Context) error {
+	inputArgs, err := cam.inputArgs()
+	if err != nil {
+		return err
+	}
+	defer cam.removeInputFile()
+	ctx, cancel := context.WithTimeout(ctx, cam.options.Timeout)
+	defer cancel()
 	// Run command to get camera data.
-	cmd := exec.Command(
-		"ffmpeg",
-		"-hide_banner",
-		"-i", "rtsp://"+cam.username+":"+cam.password+"@"+cam.streamUri,
-	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-hide_banner"}, inputArgs...)...)
 	// The command will fail since we do not give a file to write to, therefore
 	// it will write the meta data to Stderr.
 	pipe, err := cmd.StderrPipe()
 	if err != nil {
-		pipe.Close()
 		return err
 	}
 	// Start the command.
 	if err := cmd.Start(); err != nil {
-		cmd.Process.Kill()
 		return err
 	}
-	// Read ffmpeg output from Stdout.
-	buffer := make([]byte, 2<<11)
-	total := 0
-	for {
-		n, err := pipe.Read(buffer[total:])
-		total += n
-		if err == io.EOF {
-			break
-		}
-	}
+	// Read ffmpeg output from Stderr.
+	buffer, _ := io.ReadAll(pipe)
 	// Wait for the command to finish.
 	cmd.Wait()
+	if ctx.Err() != nil {
+		return errors.New("failed to probe stream of camera " + cam.id + " : " + ctx.Err().Error())
+	}
 
-	parseWebcamData(buffer[:total], cam.camera)
+	parseWebcamData(buffer, cam.camera)
+	if cam.camera.width == 0 || cam.camera.height == 0 {
+		return errors.New("no video stream found for camera " + cam.id)
+	}
 	return nil
 }
@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Image hash algorithms
+const (
+	HashExact = "exact" // SHA-256 of image bytes
+	HashDHash = "dhash" // difference hash , 64 bit
+	HashPHash = "phash" // DCT based perceptual hash , 64 bit
+)
+
+// ImageHash is either exact hash or 64 bit perceptual hash of the image.
+type ImageHash struct {
+	Algorithm  string
+	Exact      string // hex encoded SHA-256 , set for HashExact
+	Perceptual uint64 // set for HashDHash and HashPHash
+}
+
+// Distance returns Hamming distance between perceptual hashes or 0 / 64 for equal / different exact hashes.
+// Hashes produced by different algorithms are always different.
+func (h ImageHash) Distance(other ImageHash) int {
+	if h.Algorithm != other.Algorithm {
+		return 64
+	}
+	if h.Algorithm == HashExact {
+		if h.Exact == other.Exact {
+			return 0
+		}
+		return 64
+	}
+	return HammingDistance(h.Perceptual, other.Perceptual)
+}
+
+func (h ImageHash) String() string {
+	if h.Algorithm == HashExact {
+		return h.Exact
+	}
+	return fmt.Sprintf("%016x", h.Perceptual)
+}
+
+// HashImage computes hash of encoded image (JPEG or PNG for perceptual hashes) using given algorithm.
+func HashImage(body []byte, algorithm string) (ImageHash, error) {
+	if algorithm == HashExact {
+		sum := sha256.Sum256(body)
+		return ImageHash{Algorithm: algorithm, Exact: hex.EncodeToString(sum[:])}, nil
+	}
+	if algorithm != HashDHash && algorithm != HashPHash {
+		return ImageHash{}, fmt.Errorf("unsupported hash algorithm %s", algorithm)
+	}
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return ImageHash{}, fmt.Errorf("failed to decode image : %w", err)
+	}
+	if algorithm == HashDHash {
+		return ImageHash{Algorithm: algorithm, Perceptual: DHash(img)}, nil
+	}
+	return ImageHash{Algorithm: algorithm, Perceptual: PHash(img)}, nil
+}
+
+// DHash computes difference hash. The image is downscaled to 9x8 luma frame , each bit is set if the pixel is brighter than its right neighbour.
+func DHash(img image.Image) uint64 {
+	frame := resizeLuma(img, 9, 8)
+	if len(frame.Pix) == 0 {
+		return 0
+	}
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if frame.Pix[y*9+x] > frame.Pix[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// PHash computes perceptual hash. The image is downscaled to 32x32 luma frame , each bit of the hash is set if the corresponding
+// coefficient of the 8x8 lowest frequencies of DCT is greater than the median (DC coefficient is excluded from median).
+func PHash(img image.Image) uint64 {
+	const size, hashSize = 32, 8
+	frame := resizeLuma(img, size, size)
+	if len(frame.Pix) == 0 {
+		return 0
+	}
+	var cosines [hashSize][size]float64
+	for u := 0; u < hashSize; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+	coefficients := make([]float64, 0, hashSize*hashSize)
+	for v := 0; v < hashSize; v++ {
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					sum += float64(frame.Pix[y*size+x]) * cosines[u][x] * cosines[v][y]
+				}
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	var hash uint64
+	for _, c := range coefficients {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns number of different bits.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,162 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// newGradientFrame returns frame with horizontal gradient , brightness increases to the right if isAscending is true.
+func newGradientFrame(isAscending bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, testFrameWidth, testFrameHeight))
+	for y := 0; y < testFrameHeight; y++ {
+		for x := 0; x < testFrameWidth; x++ {
+			v := x * 4
+			if !isAscending {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+// newSceneFrame returns frame with diagonal gradient , bright and dark rectangles. The scene is mirrored if isMirrored is true.
+func newSceneFrame(isMirrored bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, testFrameWidth, testFrameHeight))
+	for y := 0; y < testFrameHeight; y++ {
+		for x := 0; x < testFrameWidth; x++ {
+			v := 30 + x*2 + y
+			switch {
+			case x >= 10 && x < 30 && y >= 8 && y < 20:
+				v = 240
+			case x >= 40 && x < 56 && y >= 28 && y < 42:
+				v = 10
+			}
+			if isMirrored {
+				img.SetGray(testFrameWidth-1-x, y, color.Gray{Y: uint8(v)})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: uint8(v)})
+			}
+		}
+	}
+	return img
+}
+
+// withNoise returns copy of the image with brightness of every step-th pixel changed by delta.
+func withNoise(img *image.Gray, delta, step int) *image.Gray {
+	noisy := image.NewGray(img.Bounds())
+	copy(noisy.Pix, img.Pix)
+	for i := 0; i < len(noisy.Pix); i += step {
+		noisy.Pix[i] = uint8(max(0, min(255, int(noisy.Pix[i])+delta)))
+	}
+	return noisy
+}
+
+func TestDHash(t *testing.T) {
+	if hash := DHash(newGradientFrame(true)); hash != 0 {
+		t.Errorf("expected 0 for ascending gradient , got %016x", hash)
+	}
+	if hash := DHash(newGradientFrame(false)); hash != ^uint64(0) {
+		t.Errorf("expected all bits for descending gradient , got %016x", hash)
+	}
+	if hash := DHash(image.NewGray(image.Rectangle{})); hash != 0 {
+		t.Errorf("expected 0 for empty image , got %016x", hash)
+	}
+}
+
+func TestPerceptualHashDistance(t *testing.T) {
+	scene := newSceneFrame(false)
+	tests := []struct {
+		name        string
+		a           image.Image
+		b           image.Image
+		maxDistance int // inclusive
+		minDistance int // inclusive
+	}{
+		{name: "same image", a: scene, b: scene, maxDistance: 0},
+		{name: "sensor noise", a: scene, b: withNoise(scene, 10, 7), maxDistance: 4},
+		{name: "brightness change", a: scene, b: withNoise(scene, 15, 1), maxDistance: 4},
+		{name: "different scene", a: scene, b: newSceneFrame(true), minDistance: 10, maxDistance: 64},
+		{name: "inverted scene", a: newGradientFrame(true), b: newGradientFrame(false), minDistance: 20, maxDistance: 64},
+	}
+	for _, algorithm := range []string{HashDHash, HashPHash} {
+		for _, tt := range tests {
+			t.Run(algorithm+" "+tt.name, func(t *testing.T) {
+				a, err := HashImage(encodePNG(t, tt.a), algorithm)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := HashImage(encodePNG(t, tt.b), algorithm)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if a.Algorithm != algorithm || b.Algorithm != algorithm {
+					t.Fatalf("unexpected algorithm %s , %s", a.Algorithm, b.Algorithm)
+				}
+				distance := a.Distance(b)
+				if distance < tt.minDistance || distance > tt.maxDistance {
+					t.Fatalf("expected distance %d-%d , got %d (%s , %s)", tt.minDistance, tt.maxDistance, distance, a, b)
+				}
+				if distance != b.Distance(a) {
+					t.Fatal("distance isn't symmetric")
+				}
+			})
+		}
+	}
+}
+
+func TestExactHash(t *testing.T) {
+	a, err := HashImage([]byte("image"), HashExact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SHA-256 of "image"
+	if a.String() != "6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d" {
+		t.Errorf("unexpected hash %s", a)
+	}
+	same, _ := HashImage([]byte("image"), HashExact)
+	other, _ := HashImage([]byte("image2"), HashExact)
+	if d := a.Distance(same); d != 0 {
+		t.Errorf("expected distance 0 of equal images , got %d", d)
+	}
+	if d := a.Distance(other); d != 64 {
+		t.Errorf("expected distance 64 of different images , got %d", d)
+	}
+	// exact hash doesn't decode the image
+	if _, err := HashImage([]byte("not an image"), HashExact); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestHashImageErrors(t *testing.T) {
+	if _, err := HashImage([]byte("not an image"), HashDHash); err == nil {
+		t.Error("expected decode error")
+	}
+	if _, err := HashImage(encodePNG(t, newSceneFrame(false)), "md5"); err == nil {
+		t.Error("expected unsupported algorithm error")
+	}
+	dhash, _ := HashImage(encodePNG(t, newSceneFrame(false)), HashDHash)
+	phash, _ := HashImage(encodePNG(t, newSceneFrame(false)), HashPHash)
+	if d := dhash.Distance(phash); d != 64 {
+		t.Errorf("expected distance 64 of hashes of different algorithms , got %d", d)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b     uint64
+		expected int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0b1011, 0b0110, 3},
+		{0, ^uint64(0), 64},
+		{0x8000000000000000, 1, 2},
+	}
+	for _, tt := range tests {
+		if d := HammingDistance(tt.a, tt.b); d != tt.expected {
+			t.Errorf("HammingDistance(%x , %x) : expected %d , got %d", tt.a, tt.b, tt.expected, d)
+		}
+	}
+}
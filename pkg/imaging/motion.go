@@ -0,0 +1,198 @@
+// Package imaging contains image processing helpers that don't require external tools , for example software motion detection.
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
+)
+
+const (
+	DefaultMotionFrameWidth     = 64  // width of downscaled frame used for comparison
+	DefaultMotionPixelThreshold = 25  // min luma difference (0-255) of changed pixel
+	DefaultMotionThreshold      = 1.0 // min percentage of changed pixels
+)
+
+// LumaFrame is a downscaled grayscale (luma) frame.
+type LumaFrame struct {
+	Width  int
+	Height int
+	Pix    []uint8
+}
+
+// Region is a rectangle in normalized coordinates (0..1) , X and Y are coordinates of the top left corner.
+type Region struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Contains returns true if the center of pixel (x,y) of frame with given size is inside the region.
+func (r Region) Contains(x, y, width, height int) bool {
+	cx := (float64(x) + 0.5) / float64(width)
+	cy := (float64(y) + 0.5) / float64(height)
+	return cx >= r.X && cx < r.X+r.Width && cy >= r.Y && cy < r.Y+r.Height
+}
+
+// MotionDetectorConfig configures MotionDetector , zero values are replaced by defaults.
+type MotionDetectorConfig struct {
+	Width          int      // width of downscaled frame , default 64
+	PixelThreshold int      // min luma difference (0-255) of changed pixel , default 25
+	Threshold      float64  // min percentage of changed pixels that is reported as motion , default 1
+	IncludeRegions []Region // only pixels inside the regions are compared , the whole frame is compared if empty
+	ExcludeRegions []Region // pixels inside the regions are ignored , for example trees or roads
+}
+
+// MotionResult is the result of comparison of two frames.
+type MotionResult struct {
+	Score    float64 // percentage of changed pixels
+	IsMotion bool    // Score is greater than or equal to threshold
+}
+
+// MotionDetector compares each frame with the previous one using downscaled luma difference. Global brightness changes
+// (auto exposure , clouds) are compensated by comparing pixels relative to mean brightness of the frame.
+type MotionDetector struct {
+	config   MotionDetectorConfig
+	mux      sync.Mutex
+	previous *LumaFrame
+	mask     []bool // pixels that are compared , built for the size of the previous frame
+}
+
+func NewMotionDetector(config MotionDetectorConfig) *MotionDetector {
+	if config.Width <= 0 {
+		config.Width = DefaultMotionFrameWidth
+	}
+	if config.PixelThreshold <= 0 {
+		config.PixelThreshold = DefaultMotionPixelThreshold
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultMotionThreshold
+	}
+	return &MotionDetector{config: config}
+}
+
+// Detect decodes the image (JPEG or PNG) , compares it with the previous image and stores it as the reference for the next call.
+// The first image and images with different size than the previous one are never reported as motion.
+func (d *MotionDetector) Detect(body []byte) (MotionResult, error) {
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return MotionResult{}, fmt.Errorf("failed to decode image : %w", err)
+	}
+	frame := NewLumaFrame(img, d.config.Width)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	previous := d.previous
+	d.previous = frame
+	if previous == nil || previous.Width != frame.Width || previous.Height != frame.Height {
+		d.mask = buildMask(frame.Width, frame.Height, d.config.IncludeRegions, d.config.ExcludeRegions)
+		return MotionResult{}, nil
+	}
+	score := diffScore(previous, frame, d.mask, d.config.PixelThreshold)
+	return MotionResult{Score: score, IsMotion: score >= d.config.Threshold}, nil
+}
+
+// Reset drops the reference frame , the next image is not compared.
+func (d *MotionDetector) Reset() {
+	d.mux.Lock()
+	d.previous = nil
+	d.mux.Unlock()
+}
+
+// NewLumaFrame downscales the image to given width (aspect ratio is kept) using box averaging of luma.
+// The image isn't upscaled if it's narrower than width.
+func NewLumaFrame(img image.Image, width int) *LumaFrame {
+	bounds := img.Bounds()
+	if width <= 0 || width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := 0
+	if bounds.Dx() > 0 {
+		height = max(1, bounds.Dy()*width/bounds.Dx())
+	}
+	return resizeLuma(img, width, height)
+}
+
+// resizeLuma downscales the image to width x height luma frame using box averaging , the aspect ratio isn't kept.
+func resizeLuma(img image.Image, width, height int) *LumaFrame {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return &LumaFrame{}
+	}
+	frame := &LumaFrame{Width: width, Height: height, Pix: make([]uint8, width*height)}
+	ycbcr, isYCbCr := img.(*image.YCbCr)
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			sum, count := 0, 0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					if isYCbCr {
+						// JPEG images , luma is read directly from Y plane
+						sum += int(ycbcr.Y[ycbcr.YOffset(sx, sy)])
+					} else {
+						sum += int(color.GrayModel.Convert(img.At(sx, sy)).(color.Gray).Y)
+					}
+					count++
+				}
+			}
+			frame.Pix[y*width+x] = uint8(sum / count)
+		}
+	}
+	return frame
+}
+
+func buildMask(width, height int, includeRegions, excludeRegions []Region) []bool {
+	mask := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			isIncluded := len(includeRegions) == 0
+			for _, r := range includeRegions {
+				isIncluded = isIncluded || r.Contains(x, y, width, height)
+			}
+			for _, r := range excludeRegions {
+				isIncluded = isIncluded && !r.Contains(x, y, width, height)
+			}
+			mask[y*width+x] = isIncluded
+		}
+	}
+	return mask
+}
+
+// diffScore returns percentage of masked pixels which luma relative to mean luma of the frame changed more than pixelThreshold.
+func diffScore(a, b *LumaFrame, mask []bool, pixelThreshold int) float64 {
+	var sumA, sumB, count int
+	for i := range a.Pix {
+		if mask[i] {
+			sumA += int(a.Pix[i])
+			sumB += int(b.Pix[i])
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	brightnessShift := (sumB - sumA) / count
+	changed := 0
+	for i := range a.Pix {
+		if !mask[i] {
+			continue
+		}
+		diff := int(b.Pix[i]) - int(a.Pix[i]) - brightnessShift
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > pixelThreshold {
+			changed++
+		}
+	}
+	return float64(changed) * 100 / float64(count)
+}
@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+)
+
+const (
+	testFrameWidth  = 64
+	testFrameHeight = 48
+)
+
+// newTestFrame returns gray frame with optional white block at (x,y) of given size.
+func newTestFrame(background uint8, x, y, size int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, testFrameWidth, testFrameHeight))
+	for i := range img.Pix {
+		img.Pix[i] = background
+	}
+	for by := y; by < y+size; by++ {
+		for bx := x; bx < x+size; bx++ {
+			img.SetGray(bx, by, color.Gray{Y: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMotionDetector(t *testing.T) {
+	still := newTestFrame(128, 0, 0, 0)
+	block := newTestFrame(128, 0, 0, 16) // 16x16 block in the top left corner , 256 of 3072 pixels
+	blockRegion := Region{X: 0, Y: 0, Width: 0.25, Height: 0.34}
+	blockScore := 256 * 100.0 / (testFrameWidth * testFrameHeight)
+
+	tests := []struct {
+		name     string
+		config   MotionDetectorConfig
+		previous []byte
+		current  []byte
+		isMotion bool
+		score    float64
+	}{
+		{name: "no change", previous: encodePNG(t, still), current: encodePNG(t, still)},
+		{name: "change over threshold", previous: encodePNG(t, still), current: encodePNG(t, block), isMotion: true, score: blockScore},
+		{name: "change under threshold", config: MotionDetectorConfig{Threshold: 10}, previous: encodePNG(t, still), current: encodePNG(t, block), score: blockScore},
+		{name: "small change", previous: encodePNG(t, still), current: encodePNG(t, newTestFrame(128, 30, 20, 2)), score: 4 * 100.0 / (testFrameWidth * testFrameHeight)},
+		{name: "change under pixel threshold", previous: encodePNG(t, still), current: encodePNG(t, newTestFrame(140, 0, 0, 0))},
+		{name: "global brightness change is compensated", config: MotionDetectorConfig{PixelThreshold: 10}, previous: encodePNG(t, still), current: encodePNG(t, newTestFrame(200, 0, 0, 0))},
+		{name: "change in excluded region", config: MotionDetectorConfig{ExcludeRegions: []Region{blockRegion}}, previous: encodePNG(t, still), current: encodePNG(t, block)},
+		{name: "change outside of included region", config: MotionDetectorConfig{IncludeRegions: []Region{{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5}}}, previous: encodePNG(t, still), current: encodePNG(t, block)},
+		{name: "change in included region", config: MotionDetectorConfig{IncludeRegions: []Region{{X: 0, Y: 0, Width: 0.5, Height: 1}}}, previous: encodePNG(t, still), current: encodePNG(t, block), isMotion: true, score: 256 * 100.0 / (32 * testFrameHeight)},
+		{name: "jpeg change over threshold", previous: encodeJPEG(t, still), current: encodeJPEG(t, block), isMotion: true, score: blockScore},
+		{name: "downscaled change over threshold", config: MotionDetectorConfig{Width: 16}, previous: encodePNG(t, still), current: encodePNG(t, block), isMotion: true, score: 16 * 100.0 / (16 * 12)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewMotionDetector(tt.config)
+			first, err := detector.Detect(tt.previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.IsMotion || first.Score != 0 {
+				t.Fatalf("first frame is reported as motion : %+v", first)
+			}
+			result, err := detector.Detect(tt.current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.IsMotion != tt.isMotion || math.Abs(result.Score-tt.score) > 0.01 {
+				t.Fatalf("expected motion %v with score %.2f , got %+v", tt.isMotion, tt.score, result)
+			}
+		})
+	}
+}
+
+func TestMotionDetectorReference(t *testing.T) {
+	detector := NewMotionDetector(MotionDetectorConfig{})
+	still, block := encodePNG(t, newTestFrame(128, 0, 0, 0)), encodePNG(t, newTestFrame(128, 0, 0, 16))
+	for i, frame := range [][]byte{still, block, block, still} {
+		result, err := detector.Detect(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// each frame is compared with the previous one , the change is reported when the block appears and disappears
+		if isMotion := i == 1 || i == 3; result.IsMotion != isMotion {
+			t.Fatalf("frame %d : expected motion %v , got %+v", i, isMotion, result)
+		}
+	}
+
+	detector.Reset()
+	if result, _ := detector.Detect(block); result.IsMotion {
+		t.Fatal("frame after reset is reported as motion")
+	}
+	// frame of different size replaces the reference
+	if result, _ := detector.Detect(encodePNG(t, image.NewGray(image.Rect(0, 0, 32, 32)))); result.IsMotion {
+		t.Fatal("frame of different size is reported as motion")
+	}
+	if _, err := detector.Detect([]byte("not an image")); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
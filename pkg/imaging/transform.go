@@ -0,0 +1,376 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"strings"
+)
+
+// Image transform types
+const (
+	TransformResize    = "resize"    // downscale to fit into Width x Height , aspect ratio is kept
+	TransformCrop      = "crop"      // crop to Region
+	TransformRotate    = "rotate"    // rotate clockwise by Angle (90 , 180 , 270)
+	TransformQuality   = "quality"   // JPEG quality of the output image
+	TransformMask      = "mask"      // privacy masking of Polygons
+	TransformWatermark = "watermark" // text watermark
+)
+
+// Privacy mask styles
+const (
+	MaskStyleBlack = "black"
+	MaskStyleBlur  = "blur"
+)
+
+// Watermark positions
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+)
+
+const (
+	DefaultTransformQuality = 90
+	defaultBlurRadius       = 16
+	defaultWatermarkScale   = 2
+)
+
+// Point is a point in normalized coordinates (0..1).
+type Point struct {
+	X float64
+	Y float64
+}
+
+// TransformConfig configures single step of transform pipeline , fields are used depending on Type.
+type TransformConfig struct {
+	Type       string
+	Width      int       // resize : max width in pixels , 0 means unlimited
+	Height     int       // resize : max height in pixels , 0 means unlimited
+	Region     Region    // crop : region of interest in normalized coordinates
+	Angle      int       // rotate : 90 , 180 or 270 degrees clockwise
+	Quality    int       // quality : JPEG quality 1-100 , default 90
+	Polygons   [][]Point // mask : polygons in normalized coordinates of the image at this step
+	Style      string    // mask : black (default) or blur
+	BlurRadius int       // mask : blur radius in pixels , default 16
+	Text       string    // watermark : text template , {name} is replaced by variable name , for example {cameraName} {timestamp}
+	Position   string    // watermark : top-left (default) , top-right , bottom-left or bottom-right
+	Scale      int       // watermark : font scale , each font pixel is Scale x Scale image pixels , default 2
+}
+
+// Pipeline applies chain of transforms to image and encodes the result as JPEG.
+type Pipeline struct {
+	steps   []TransformConfig
+	quality int
+}
+
+// NewPipeline validates transforms and returns new pipeline.
+func NewPipeline(configs []TransformConfig) (*Pipeline, error) {
+	pipeline := &Pipeline{quality: DefaultTransformQuality}
+	for i, config := range configs {
+		if err := validateTransform(config); err != nil {
+			return nil, fmt.Errorf("transform %d (%s) : %w", i, config.Type, err)
+		}
+		if config.Type == TransformQuality {
+			pipeline.quality = config.Quality
+			continue
+		}
+		pipeline.steps = append(pipeline.steps, config)
+	}
+	return pipeline, nil
+}
+
+func validateTransform(config TransformConfig) error {
+	switch config.Type {
+	case TransformResize:
+		if config.Width < 0 || config.Height < 0 || (config.Width == 0 && config.Height == 0) {
+			return fmt.Errorf("Width or Height must be positive")
+		}
+	case TransformCrop:
+		r := config.Region
+		if r.Width <= 0 || r.Height <= 0 || r.X < 0 || r.Y < 0 || r.X+r.Width > 1 || r.Y+r.Height > 1 {
+			return fmt.Errorf("Region must be inside the image (normalized coordinates 0..1)")
+		}
+	case TransformRotate:
+		if config.Angle != 90 && config.Angle != 180 && config.Angle != 270 {
+			return fmt.Errorf("Angle must be 90 , 180 or 270")
+		}
+	case TransformQuality:
+		if config.Quality < 1 || config.Quality > 100 {
+			return fmt.Errorf("Quality must be between 1 and 100")
+		}
+	case TransformMask:
+		if len(config.Polygons) == 0 {
+			return fmt.Errorf("at least one polygon is required")
+		}
+		for _, polygon := range config.Polygons {
+			if len(polygon) < 3 {
+				return fmt.Errorf("polygon must have at least 3 points")
+			}
+		}
+		if config.Style != "" && config.Style != MaskStyleBlack && config.Style != MaskStyleBlur {
+			return fmt.Errorf("unsupported Style %s , supported styles : black, blur", config.Style)
+		}
+	case TransformWatermark:
+		if config.Text == "" {
+			return fmt.Errorf("Text is required")
+		}
+		switch config.Position {
+		case "", PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight:
+		default:
+			return fmt.Errorf("unsupported Position %s", config.Position)
+		}
+	default:
+		return fmt.Errorf("unsupported transform type , supported types : resize, crop, rotate, quality, mask, watermark")
+	}
+	return nil
+}
+
+// Apply decodes the image (JPEG or PNG) , applies transforms in configured order and encodes the result as JPEG.
+// vars are substituted into watermark text.
+func (p *Pipeline) Apply(body []byte, vars map[string]string) ([]byte, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image : %w", err)
+	}
+	img := toRGBA(decoded)
+	for _, step := range p.steps {
+		switch step.Type {
+		case TransformResize:
+			img = resize(img, step.Width, step.Height)
+		case TransformCrop:
+			img = crop(img, step.Region)
+		case TransformRotate:
+			img = rotate(img, step.Angle)
+		case TransformMask:
+			mask(img, step)
+		case TransformWatermark:
+			watermark(img, step, vars)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image : %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+	return img
+}
+
+// resize downscales the image to fit into maxWidth x maxHeight using box averaging , images are never upscaled.
+func resize(src *image.RGBA, maxWidth, maxHeight int) *image.RGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && float64(height)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(height)
+	}
+	if scale >= 1 {
+		return src
+	}
+	dstWidth, dstHeight := max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := y * height / dstHeight
+		y1 := max(y0+1, (y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := x * width / dstWidth
+			x1 := max(x0+1, (x+1)*width/dstWidth)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+					offset += 4
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
+}
+
+func crop(src *image.RGBA, region Region) *image.RGBA {
+	rect := regionToRect(region, src.Rect.Dx(), src.Rect.Dy())
+	if rect.Empty() {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+func regionToRect(region Region, width, height int) image.Rectangle {
+	return image.Rect(
+		int(region.X*float64(width)), int(region.Y*float64(height)),
+		int((region.X+region.Width)*float64(width)), int((region.Y+region.Height)*float64(height)),
+	).Intersect(image.Rect(0, 0, width, height))
+}
+
+// rotate rotates the image clockwise by 90 , 180 or 270 degrees.
+func rotate(src *image.RGBA, angle int) *image.RGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	var dst *image.RGBA
+	if angle == 180 {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, height, width))
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch angle {
+			case 90:
+				dx, dy = height-1-y, x
+			case 180:
+				dx, dy = width-1-x, height-1-y
+			case 270:
+				dx, dy = y, width-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// mask covers polygons with black color or blurred content. Blur is applied to the bounding box of each polygon and
+// only pixels inside the polygon are replaced.
+func mask(img *image.RGBA, config TransformConfig) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for _, normalized := range config.Polygons {
+		polygon := make([]Point, len(normalized))
+		bbox := image.Rectangle{Min: image.Pt(width, height)}
+		for i, p := range normalized {
+			polygon[i] = Point{X: p.X * float64(width), Y: p.Y * float64(height)}
+			bbox.Min.X = min(bbox.Min.X, int(polygon[i].X))
+			bbox.Min.Y = min(bbox.Min.Y, int(polygon[i].Y))
+			bbox.Max.X = max(bbox.Max.X, int(math.Ceil(polygon[i].X)))
+			bbox.Max.Y = max(bbox.Max.Y, int(math.Ceil(polygon[i].Y)))
+		}
+		bbox = bbox.Intersect(img.Rect)
+		if bbox.Empty() {
+			continue
+		}
+		var blurred *image.RGBA
+		if config.Style == MaskStyleBlur {
+			radius := config.BlurRadius
+			if radius <= 0 {
+				radius = defaultBlurRadius
+			}
+			blurred = boxBlur(img, bbox, radius)
+		}
+		for y := bbox.Min.Y; y < bbox.Max.Y; y++ {
+			for x := bbox.Min.X; x < bbox.Max.X; x++ {
+				if !isInsidePolygon(polygon, float64(x)+0.5, float64(y)+0.5) {
+					continue
+				}
+				offset := img.PixOffset(x, y)
+				if blurred != nil {
+					copy(img.Pix[offset:offset+4], blurred.Pix[blurred.PixOffset(x, y):blurred.PixOffset(x, y)+4])
+				} else {
+					copy(img.Pix[offset:offset+4], []uint8{0, 0, 0, 255})
+				}
+			}
+		}
+	}
+}
+
+// isInsidePolygon implements even-odd ray casting test.
+func isInsidePolygon(polygon []Point, x, y float64) bool {
+	isInside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			isInside = !isInside
+		}
+	}
+	return isInside
+}
+
+// boxBlur returns copy of the rect area blurred by 3 passes of separable box blur , which approximates gaussian blur.
+// Pixels outside of rect are not used , so content outside of the masked area doesn't leak into it.
+func boxBlur(src *image.RGBA, rect image.Rectangle, radius int) *image.RGBA {
+	dst := image.NewRGBA(rect)
+	draw.Draw(dst, rect, src, rect.Min, draw.Src)
+	tmp := image.NewRGBA(rect)
+	for pass := 0; pass < 3; pass++ {
+		blurLine(dst, tmp, rect, radius, true)
+		blurLine(tmp, dst, rect, radius, false)
+	}
+	return dst
+}
+
+func blurLine(src, dst *image.RGBA, rect image.Rectangle, radius int, isHorizontal bool) {
+	outer, inner := rect.Dy(), rect.Dx()
+	if !isHorizontal {
+		outer, inner = rect.Dx(), rect.Dy()
+	}
+	pixel := func(o, i int) int {
+		if isHorizontal {
+			return src.PixOffset(rect.Min.X+i, rect.Min.Y+o)
+		}
+		return src.PixOffset(rect.Min.X+o, rect.Min.Y+i)
+	}
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			var sum [4]int
+			count := 0
+			for k := max(0, i-radius); k <= min(inner-1, i+radius); k++ {
+				offset := pixel(o, k)
+				for c := 0; c < 4; c++ {
+					sum[c] += int(src.Pix[offset+c])
+				}
+				count++
+			}
+			offset := pixel(o, i)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+}
+
+// watermark draws text on semi-transparent background in the configured corner.
+func watermark(img *image.RGBA, config TransformConfig, vars map[string]string) {
+	text := config.Text
+	for name, value := range vars {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	scale := config.Scale
+	if scale <= 0 {
+		scale = defaultWatermarkScale
+	}
+	textWidth, textHeight := textSize(text, scale)
+	padding := 2 * scale
+	boxWidth, boxHeight := textWidth+2*padding, textHeight+2*padding
+	x, y := 0, 0
+	switch config.Position {
+	case PositionTopRight:
+		x = img.Rect.Dx() - boxWidth
+	case PositionBottomLeft:
+		y = img.Rect.Dy() - boxHeight
+	case PositionBottomRight:
+		x, y = img.Rect.Dx()-boxWidth, img.Rect.Dy()-boxHeight
+	}
+	background := image.NewUniform(color.RGBA{0, 0, 0, 160})
+	draw.Draw(img, image.Rect(x, y, x+boxWidth, y+boxHeight), background, image.Point{}, draw.Over)
+	drawText(img, x+padding, y+padding, text, scale, color.White)
+}
@@ -0,0 +1,262 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// newIndexedImage returns image where each pixel has unique color , red is 40*x and green is 40*y.
+func newIndexedImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, indexedColor(x, y))
+		}
+	}
+	return img
+}
+
+func indexedColor(x, y int) color.RGBA {
+	return color.RGBA{R: uint8(40 * x), G: uint8(40 * y), B: 7, A: 255}
+}
+
+func newUniformImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+var (
+	white = color.RGBA{255, 255, 255, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+func assertSize(t *testing.T, img image.Image, width, height int) {
+	t.Helper()
+	if img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+		t.Fatalf("expected %dx%d image , got %dx%d", width, height, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestRotate(t *testing.T) {
+	// 3x2 source image
+	tests := []struct {
+		angle         int
+		width, height int
+		mapping       map[image.Point]image.Point // source pixel -> destination pixel
+	}{
+		{angle: 90, width: 2, height: 3, mapping: map[image.Point]image.Point{{0, 0}: {1, 0}, {2, 0}: {1, 2}, {0, 1}: {0, 0}, {2, 1}: {0, 2}, {1, 1}: {0, 1}}},
+		{angle: 180, width: 3, height: 2, mapping: map[image.Point]image.Point{{0, 0}: {2, 1}, {2, 0}: {0, 1}, {0, 1}: {2, 0}, {2, 1}: {0, 0}, {1, 1}: {1, 0}}},
+		{angle: 270, width: 2, height: 3, mapping: map[image.Point]image.Point{{0, 0}: {0, 2}, {2, 0}: {0, 0}, {0, 1}: {1, 2}, {2, 1}: {1, 0}, {1, 1}: {1, 1}}},
+	}
+	for _, tt := range tests {
+		dst := rotate(newIndexedImage(3, 2), tt.angle)
+		assertSize(t, dst, tt.width, tt.height)
+		for src, expected := range tt.mapping {
+			if c := dst.RGBAAt(expected.X, expected.Y); c != indexedColor(src.X, src.Y) {
+				t.Errorf("rotate %d : pixel %v must be at %v , got %v", tt.angle, src, expected, c)
+			}
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	dst := crop(newIndexedImage(4, 4), Region{X: 0.5, Y: 0.25, Width: 0.5, Height: 0.5})
+	assertSize(t, dst, 2, 2)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if c := dst.RGBAAt(x, y); c != indexedColor(x+2, y+1) {
+				t.Errorf("pixel %d,%d : expected %v , got %v", x, y, indexedColor(x+2, y+1), c)
+			}
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// 4x2 image , left half is black and right half is white
+	src := newUniformImage(4, 2, black)
+	for y := 0; y < 2; y++ {
+		src.SetRGBA(2, y, white)
+		src.SetRGBA(3, y, white)
+	}
+	dst := resize(src, 2, 0)
+	assertSize(t, dst, 2, 1)
+	if dst.RGBAAt(0, 0) != black || dst.RGBAAt(1, 0) != white {
+		t.Errorf("unexpected pixels %v , %v", dst.RGBAAt(0, 0), dst.RGBAAt(1, 0))
+	}
+	// pixels are averaged
+	dst = resize(src, 1, 0)
+	if c := dst.RGBAAt(0, 0); c.R != 127 || c.A != 255 {
+		t.Errorf("expected gray pixel , got %v", c)
+	}
+	// the aspect ratio is kept , height limit is applied
+	assertSize(t, resize(newIndexedImage(40, 20), 100, 5), 10, 5)
+	// images are never upscaled
+	if resize(src, 8, 8) != src {
+		t.Error("image is upscaled")
+	}
+}
+
+func TestMask(t *testing.T) {
+	leftHalf := [][]Point{{{X: 0, Y: 0}, {X: 0.5, Y: 0}, {X: 0.5, Y: 1}, {X: 0, Y: 1}}}
+	triangle := [][]Point{{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}}}
+
+	t.Run("black polygon", func(t *testing.T) {
+		img := newUniformImage(10, 10, white)
+		mask(img, TransformConfig{Polygons: leftHalf})
+		if c := img.RGBAAt(2, 5); c != black {
+			t.Errorf("masked pixel isn't black : %v", c)
+		}
+		if c := img.RGBAAt(7, 5); c != white {
+			t.Errorf("pixel outside of polygon is changed : %v", c)
+		}
+	})
+
+	t.Run("black triangle", func(t *testing.T) {
+		img := newUniformImage(10, 10, white)
+		mask(img, TransformConfig{Polygons: triangle})
+		if c := img.RGBAAt(1, 1); c != black {
+			t.Errorf("pixel inside of triangle isn't black : %v", c)
+		}
+		// pixel inside of the bounding box , but outside of the triangle
+		if c := img.RGBAAt(8, 8); c != white {
+			t.Errorf("pixel outside of triangle is changed : %v", c)
+		}
+	})
+
+	t.Run("blur", func(t *testing.T) {
+		img := newIndexedImage(6, 6)
+		original := newIndexedImage(6, 6)
+		mask(img, TransformConfig{Polygons: leftHalf, Style: MaskStyleBlur, BlurRadius: 2})
+		if img.RGBAAt(0, 0) == original.RGBAAt(0, 0) {
+			t.Error("masked pixel isn't blurred")
+		}
+		for y := 0; y < 6; y++ {
+			for x := 3; x < 6; x++ {
+				if img.RGBAAt(x, y) != original.RGBAAt(x, y) {
+					t.Errorf("pixel %d,%d outside of polygon is changed", x, y)
+				}
+			}
+		}
+	})
+
+	t.Run("blur doesn't use pixels outside of polygon", func(t *testing.T) {
+		img := newUniformImage(10, 10, white)
+		for y := 0; y < 10; y++ {
+			for x := 5; x < 10; x++ {
+				img.SetRGBA(x, y, black)
+			}
+		}
+		mask(img, TransformConfig{Polygons: leftHalf, Style: MaskStyleBlur})
+		if c := img.RGBAAt(4, 5); c != white {
+			t.Errorf("content outside of polygon leaked into masked area : %v", c)
+		}
+	})
+}
+
+func TestWatermark(t *testing.T) {
+	const width, height, scale = 120, 40, 2
+	vars := map[string]string{"cameraName": "gate"}
+	textWidth, textHeight := textSize("cam gate", scale)
+	boxWidth, boxHeight := textWidth+4*scale, textHeight+4*scale
+	tests := []struct {
+		position string
+		box      image.Rectangle
+	}{
+		{position: "", box: image.Rect(0, 0, boxWidth, boxHeight)},
+		{position: PositionTopRight, box: image.Rect(width-boxWidth, 0, width, boxHeight)},
+		{position: PositionBottomLeft, box: image.Rect(0, height-boxHeight, boxWidth, height)},
+		{position: PositionBottomRight, box: image.Rect(width-boxWidth, height-boxHeight, width, height)},
+	}
+	for _, tt := range tests {
+		img := newUniformImage(width, height, white)
+		watermark(img, TransformConfig{Text: "cam {cameraName}", Position: tt.position, Scale: scale}, vars)
+		// the corner of the box has background color , it's darker than the image
+		if c := img.RGBAAt(tt.box.Min.X, tt.box.Min.Y); c.R >= 200 {
+			t.Errorf("%s : box corner isn't darkened : %v", tt.position, c)
+		}
+		hasText := false
+		for y := tt.box.Min.Y; y < tt.box.Max.Y; y++ {
+			for x := tt.box.Min.X; x < tt.box.Max.X; x++ {
+				hasText = hasText || img.RGBAAt(x, y) == white
+			}
+		}
+		if !hasText {
+			t.Errorf("%s : text isn't drawn", tt.position)
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if !image.Pt(x, y).In(tt.box) && img.RGBAAt(x, y) != white {
+					t.Fatalf("%s : pixel %d,%d outside of watermark box %v is changed", tt.position, x, y, tt.box)
+				}
+			}
+		}
+	}
+}
+
+func TestPipelineApply(t *testing.T) {
+	pipeline, err := NewPipeline([]TransformConfig{
+		{Type: TransformResize, Width: 32},
+		{Type: TransformRotate, Angle: 90},
+		{Type: TransformMask, Polygons: [][]Point{{{X: 0, Y: 0}, {X: 0.5, Y: 0}, {X: 0.5, Y: 0.5}, {X: 0, Y: 0.5}}}},
+		{Type: TransformQuality, Quality: 95},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := pipeline.Apply(encodePNG(t, newUniformImage(64, 32, white)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 64x32 is resized to 32x16 and rotated to 16x32
+	assertSize(t, img, 16, 32)
+	if r, _, _, _ := img.At(2, 2).RGBA(); r>>8 > 16 {
+		t.Errorf("masked pixel isn't black : %v", img.At(2, 2))
+	}
+	if r, _, _, _ := img.At(12, 24).RGBA(); r>>8 < 240 {
+		t.Errorf("pixel outside of mask isn't white : %v", img.At(12, 24))
+	}
+
+	if _, err := pipeline.Apply([]byte("not an image"), nil); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestNewPipelineValidation(t *testing.T) {
+	triangle := []Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}}
+	tests := []struct {
+		name   string
+		config TransformConfig
+	}{
+		{name: "unknown type", config: TransformConfig{Type: "flip"}},
+		{name: "resize without size", config: TransformConfig{Type: TransformResize}},
+		{name: "negative resize", config: TransformConfig{Type: TransformResize, Width: -1, Height: 10}},
+		{name: "crop outside of image", config: TransformConfig{Type: TransformCrop, Region: Region{X: 0.5, Y: 0, Width: 0.6, Height: 1}}},
+		{name: "empty crop", config: TransformConfig{Type: TransformCrop}},
+		{name: "rotate 45", config: TransformConfig{Type: TransformRotate, Angle: 45}},
+		{name: "quality 0", config: TransformConfig{Type: TransformQuality}},
+		{name: "mask without polygons", config: TransformConfig{Type: TransformMask}},
+		{name: "mask with 2 points", config: TransformConfig{Type: TransformMask, Polygons: [][]Point{triangle[:2]}}},
+		{name: "unknown mask style", config: TransformConfig{Type: TransformMask, Polygons: [][]Point{triangle}, Style: "pixelate"}},
+		{name: "watermark without text", config: TransformConfig{Type: TransformWatermark}},
+		{name: "unknown watermark position", config: TransformConfig{Type: TransformWatermark, Text: "x", Position: "center"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPipeline([]TransformConfig{tt.config}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}